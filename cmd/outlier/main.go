@@ -2,14 +2,17 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 	"github.com/wingnut128/outlier-go/internal/calculator"
 	"github.com/wingnut128/outlier-go/internal/config"
+	"github.com/wingnut128/outlier-go/internal/logging"
 	"github.com/wingnut128/outlier-go/internal/parser"
 	"github.com/wingnut128/outlier-go/internal/server"
 	"github.com/wingnut128/outlier-go/internal/telemetry"
@@ -31,31 +34,92 @@ import (
 // @host localhost:3000
 // @BasePath /
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key, when authentication is enabled
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer " followed by a JWT or API key, when authentication is enabled
+
 var (
 	serveMode  bool
 	configPath string
 	port       int
 	percentile float64
-	filePath   string
+	filePaths  []string
 	valuesStr  string
+
+	maxDecompressedSize int64
+	column              string
+	unit                string
+	parseMode           string
+	maxErrors           int
+	nonFinite           string
+	locale              string
+	decimalSeparator    string
+	groupingSeparator   string
+	stripSymbols        bool
+	metric              string
+	groupBy             string
+	accessLog           parser.AccessLogOptions
+	har                 parser.HAROptions
+	otlp                parser.OTLPOptions
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "outlier",
 	Short: "Outlier - Percentile calculator with CLI and HTTP API",
 	Long: `Outlier is a percentile calculator that supports both CLI and server modes.
-It can calculate percentiles from direct values, JSON files, or CSV files.`,
+It can calculate percentiles from direct values, standard input, or JSON, CSV,
+Parquet, plain-text, Go benchmark, JUnit XML, HAR, OTLP trace and web-server
+access log files, including gzip, zstd and bzip2 compressed inputs.`,
 	Version: version.GetFullVersion(),
 	RunE:    runMain,
 }
 
+var formatsCmd = &cobra.Command{
+	Use:   "formats",
+	Short: "List the supported input formats",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		printFormats(cmd.OutOrStdout())
+	},
+}
+
 func init() {
+	rootCmd.AddCommand(formatsCmd)
 	rootCmd.Flags().BoolVar(&serveMode, "serve", false, "Start HTTP API server")
 	rootCmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file")
 	rootCmd.Flags().IntVar(&port, "port", 0, "Override server port")
 	rootCmd.Flags().Float64VarP(&percentile, "percentile", "p", 95.0, "Percentile to calculate (0-100)")
-	rootCmd.Flags().StringVarP(&filePath, "file", "f", "", "Input file path (JSON or CSV)")
+	rootCmd.Flags().StringArrayVarP(&filePaths, "file", "f", nil, "Input file, glob or directory (JSON, NDJSON, CSV, Parquet, Go benchmark output, JUnit XML, HAR, OTLP traces or plain numbers, optionally .gz/.zst/.bz2 compressed; - for stdin); repeat for several inputs")
 	rootCmd.Flags().StringVarP(&valuesStr, "values", "v", "", "Comma-separated values")
+	rootCmd.Flags().StringVar(&unit, "unit", "", "Convert values with duration or size suffixes (e.g. 120ms, 1.5s, 3KiB) into this unit: ns, us, ms, s, m, h, B, KB, MB, GB, KiB, MiB, GiB, ...")
+	rootCmd.Flags().StringVar(&parseMode, "mode", parser.ModeStrict, "Invalid record handling: strict fails on the first one, lenient skips and reports them")
+	rootCmd.Flags().IntVar(&maxErrors, "max-errors", parser.DefaultMaxErrors, "Maximum number of skipped records to report in lenient mode (negative reports all)")
+	rootCmd.Flags().StringVar(&nonFinite, "non-finite", string(parser.NonFiniteReject), "NaN and ±Inf handling: reject, drop, or clamp (treat ±Inf as the largest/smallest finite value)")
+	rootCmd.Flags().StringVar(&locale, "locale", "", "Number format of the input, e.g. de (1.234,56), fr (1 234,56) or de-CH (1'234.56); --values are then separated by ';'")
+	rootCmd.Flags().StringVar(&decimalSeparator, "decimal-separator", "", "Decimal separator, '.' or ',' (overrides --locale)")
+	rootCmd.Flags().StringVar(&groupingSeparator, "grouping-separator", "", "Thousands separator to ignore (overrides --locale)")
+	rootCmd.Flags().BoolVar(&stripSymbols, "strip-symbols", false, "Strip currency symbols and percent signs from values")
+	rootCmd.Flags().StringVar(&metric, "metric", parser.DefaultMetric, "Go benchmark metric to read: ns/op, B/op, allocs/op, MB/s or a custom metric")
+	rootCmd.Flags().StringVar(&groupBy, "group-by", "", "Group labelled inputs: suite, class or name for JUnit reports (default suite), host or mime for HAR files (default host), span, service or name for OTLP traces (default span), name for Go benchmarks; none disables grouping")
+	rootCmd.Flags().StringVar(&column, "column", parser.DefaultColumn, "CSV or Parquet column holding the values (dot-separated path for nested Parquet columns)")
+	rootCmd.Flags().StringVar(&accessLog.Format, "log-format", "", "Parse the input as an access log: nginx, apache, or a custom $variable/%directive format string")
+	rootCmd.Flags().StringVar(&accessLog.Pattern, "log-pattern", "", "Parse the input as a log using a regex with a named 'value' group (optional 'status' and 'path' groups)")
+	rootCmd.Flags().StringVar(&accessLog.Field, "log-field", "", "Log format variable holding the value (default: request_time for nginx, D for apache)")
+	rootCmd.Flags().StringSliceVar(&accessLog.Statuses, "log-status", nil, "Only include log lines with these status codes or classes (e.g. 200,5xx)")
+	rootCmd.Flags().StringVar(&accessLog.PathPrefix, "log-path-prefix", "", "Only include log lines whose request path has this prefix")
+	rootCmd.Flags().StringVar(&har.Phase, "har-phase", parser.PhaseTotal, "HAR timing phase to read: blocked, dns, connect, ssl, send, wait, receive or total")
+	rootCmd.Flags().StringVar(&har.URL, "har-url", "", "Only include HAR entries whose URL contains this string")
+	rootCmd.Flags().StringVar(&har.Host, "har-host", "", "Only include HAR entries for this host or its subdomains")
+	rootCmd.Flags().StringVar(&har.MIME, "har-mime", "", "Only include HAR entries whose response MIME type has this prefix (e.g. image/)")
+	rootCmd.Flags().StringArrayVar(&otlp.Attributes, "span-attr", nil, "Only include OTLP spans with this span or resource attribute, as key=value or key; repeat to require several")
+	rootCmd.Flags().StringVar(&otlp.Status, "span-status", "", "Only include OTLP spans with this status: unset, ok or error")
+	rootCmd.Flags().Int64Var(&maxDecompressedSize, "max-decompressed-size", parser.DefaultMaxDecompressedSize, "Maximum decompressed input size in bytes (negative disables the limit)")
 }
 
 func main() {
@@ -70,22 +134,34 @@ func runMain(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	// Load configuration
+	cfg, err := config.LoadConfigWithPriority(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Initialize logging. CLI results go to stdout, so CLI logs go to
+	// stderr instead.
+	if !serveMode && strings.EqualFold(cfg.Logging.Output, logging.OutputStdout) {
+		cfg.Logging.Output = logging.OutputStderr
+	}
+	logger, logFile, err := logging.New(cfg.Logging, telemetry.LogHandler())
+	if err != nil {
+		return fmt.Errorf("failed to initialize logging: %w", err)
+	}
+	defer logFile.Close()
+	slog.SetDefault(logger)
+
 	// Initialize telemetry
-	if err := telemetry.InitTelemetry(); err != nil {
-		log.Printf("Warning: failed to initialize telemetry: %v\n", err)
+	if err := telemetry.InitTelemetry(cfg.Telemetry); err != nil {
+		slog.Warn("Failed to initialize telemetry", "error", err)
 	}
 	defer func() {
 		if err := telemetry.ShutdownTelemetry(); err != nil {
-			log.Printf("Warning: failed to shutdown telemetry: %v\n", err)
+			slog.Warn("Failed to shutdown telemetry", "error", err)
 		}
 	}()
 
-	// Load configuration
-	cfg, err := config.LoadConfigWithPriority(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
-	}
-
 	// Override port if specified
 	if port > 0 {
 		cfg.Server.Port = port
@@ -101,44 +177,219 @@ func runMain(cmd *cobra.Command, args []string) error {
 }
 
 func runServer(cfg *config.Config) error {
-	srv := server.NewServer(cfg)
+	srv, err := server.NewServer(cfg)
+	if err != nil {
+		return err
+	}
 	return srv.Start()
 }
 
 func runCLI() error {
-	var values []float64
-	var err error
+	policy, err := parser.ParseNonFinitePolicy(nonFinite)
+	if err != nil {
+		return err
+	}
+	loc, err := parser.NewLocale(locale, decimalSeparator, groupingSeparator, stripSymbols)
+	if err != nil {
+		return err
+	}
 
 	// Determine input source
+	var parsed *parser.Result
+	var sources []*parser.Result
 	switch {
-	case filePath != "":
-		values, err = parser.ReadValuesFromFile(filePath)
+	case len(filePaths) > 0:
+		sources, err = readValuesFromFiles(filePaths, policy, loc)
 		if err != nil {
 			return err
 		}
+		parsed = sources[0]
+		if len(sources) > 1 {
+			if parsed, err = parser.Merge(sources, maxErrors); err != nil {
+				return err
+			}
+		}
 	case valuesStr != "":
-		values, err = parseValuesFromString(valuesStr)
+		vp, err := parser.NewValueParser(unit)
+		if err != nil {
+			return err
+		}
+		values, err := parseValuesFromString(valuesStr, vp.WithLocale(loc))
 		if err != nil {
 			return fmt.Errorf("parsing values: %w", err)
 		}
+		values, stats, err := parser.ApplyNonFinitePolicy(values, policy)
+		if err != nil {
+			return fmt.Errorf("parsing values: %w", err)
+		}
+		parsed = &parser.Result{Values: values, Unit: vp.Unit(), NonFinite: stats}
 	default:
 		return fmt.Errorf("must provide either --file or --values")
 	}
 
 	// Calculate percentile
-	result, err := calculator.CalculatePercentile(values, percentile)
+	result, err := calculator.CalculatePercentile(parsed.Values, percentile)
 	if err != nil {
 		return err
 	}
 
 	// Output result
-	fmt.Printf("Number of values: %d\n", len(values))
-	fmt.Printf("Percentile (P%.0f): %.2f\n", percentile, result)
+	fmt.Printf("Number of values: %d\n", len(parsed.Values))
+	if parsed.Skipped > 0 {
+		printSkipped(parsed.Skipped, parsed.Errors)
+	}
+	if parsed.NonFinite.Dropped > 0 {
+		fmt.Printf("Dropped non-finite values: %d\n", parsed.NonFinite.Dropped)
+	}
+	if parsed.NonFinite.Clamped > 0 {
+		fmt.Printf("Clamped infinite values: %d\n", parsed.NonFinite.Clamped)
+	}
+	fmt.Printf("Percentile (P%.0f): %s\n", percentile, formatValue(result, parsed.Unit))
+	if len(parsed.Groups) > 0 {
+		printGroups(parsed.Groups, parsed.Unit)
+	}
+	if len(sources) > 1 {
+		printSources(sources, parsed.Unit)
+	}
 	return nil
 }
 
-func parseValuesFromString(s string) ([]float64, error) {
-	parts := strings.Split(s, ",")
+// printGroups reports the percentile and outliers of each labelled series,
+// such as the benchmarks of Go benchmark output
+func printGroups(groups []parser.Group, valueUnit string) {
+	fmt.Printf("Groups: %d\n", len(groups))
+	for _, g := range groups {
+		count := fmt.Sprintf("%d values", len(g.Values))
+		if g.NonFinite.Dropped > 0 {
+			count += fmt.Sprintf(" (%d non-finite dropped)", g.NonFinite.Dropped)
+		}
+		if g.NonFinite.Clamped > 0 {
+			count += fmt.Sprintf(" (%d infinite clamped)", g.NonFinite.Clamped)
+		}
+		result, err := calculator.CalculatePercentile(g.Values, percentile)
+		if err != nil {
+			fmt.Printf("  %s: %s, %v\n", g.Name, count, err)
+			continue
+		}
+		line := fmt.Sprintf("  %s: %s, P%.0f: %s", g.Name, count, percentile, formatValue(result, valueUnit))
+		o, err := calculator.DetectOutliers(g.Values)
+		if err != nil || o.Count() == 0 {
+			fmt.Println(line)
+			continue
+		}
+		fmt.Printf("%s, outliers: %d (outside %s to %s)\n", line, o.Count(), formatValue(o.LowerFence, valueUnit), formatValue(o.UpperFence, valueUnit))
+		printOutliers(g, o, valueUnit)
+	}
+}
+
+// maxListedOutliers caps the named outliers printed per group
+const maxListedOutliers = 10
+
+// printOutliers lists the named outliers of a group, largest first
+func printOutliers(g parser.Group, o calculator.Outliers, valueUnit string) {
+	if g.Names == nil {
+		return
+	}
+	var idx []int
+	for i, v := range g.Values {
+		if o.IsOutlier(v) {
+			idx = append(idx, i)
+		}
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return g.Values[idx[a]] > g.Values[idx[b]] })
+	for n, i := range idx {
+		if n == maxListedOutliers {
+			fmt.Printf("    ... and %d more\n", len(idx)-n)
+			break
+		}
+		fmt.Printf("    %s: %s\n", g.Names[i], formatValue(g.Values[i], valueUnit))
+	}
+}
+
+// printSources reports the percentile of each input file
+func printSources(sources []*parser.Result, valueUnit string) {
+	fmt.Printf("Sources: %d\n", len(sources))
+	for _, src := range sources {
+		result, err := calculator.CalculatePercentile(src.Values, percentile)
+		if err != nil {
+			fmt.Printf("  %s: %d values, %v\n", src.Source, len(src.Values), err)
+			continue
+		}
+		fmt.Printf("  %s: %d values, P%.0f: %s\n", src.Source, len(src.Values), percentile, formatValue(result, valueUnit))
+	}
+}
+
+// printSkipped reports the records skipped in lenient mode
+func printSkipped(skipped int, errs []parser.RecordError) {
+	fmt.Printf("Skipped records: %d\n", skipped)
+	for i := range errs {
+		fmt.Printf("  %s\n", errs[i].Error())
+	}
+	if skipped > len(errs) {
+		fmt.Printf("  ... and %d more\n", skipped-len(errs))
+	}
+}
+
+// formatValue formats a result with its unit, if any
+func formatValue(value float64, unit string) string {
+	if unit == "" {
+		return fmt.Sprintf("%.2f", value)
+	}
+	return fmt.Sprintf("%.2f %s", value, unit)
+}
+
+// readValuesFromFiles expands file arguments (repeats, globs and directories;
+// "-" for standard input) and decodes the files concurrently
+func readValuesFromFiles(patterns []string, policy parser.NonFinitePolicy, loc parser.Locale) ([]*parser.Result, error) {
+	opts := parser.Options{
+		MaxDecompressedSize: maxDecompressedSize,
+		Column:              column,
+		Unit:                unit,
+		Mode:                parseMode,
+		MaxErrors:           maxErrors,
+		NonFinite:           policy,
+		Locale:              loc,
+		Metric:              metric,
+		GroupBy:             groupBy,
+		AccessLog:           accessLog,
+		HAR:                 har,
+		OTLP:                otlp,
+	}
+
+	paths, err := parser.ExpandPaths(patterns, opts)
+	if err != nil {
+		return nil, err
+	}
+	return parser.DecodeFiles(paths, opts)
+}
+
+// printFormats lists the registered input formats with the extensions and MIME
+// types that select them
+func printFormats(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tEXTENSIONS\tMIME TYPES")
+	for _, f := range parser.Formats() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Name(), listOrDash(f.Extensions()), listOrDash(f.MIMETypes()))
+	}
+	tw.Flush()
+}
+
+func listOrDash(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	return strings.Join(items, ", ")
+}
+
+// parseValuesFromString parses comma-separated values, which may carry units
+// such as "120ms" that vp converts into its target unit. Values written with a
+// comma decimal separator are separated by semicolons instead.
+func parseValuesFromString(s string, vp *parser.ValueParser) ([]float64, error) {
+	sep := ","
+	if vp.Locale().Decimal == "," {
+		sep = ";"
+	}
+	parts := strings.Split(s, sep)
 	values := make([]float64, 0, len(parts))
 
 	for _, part := range parts {
@@ -147,9 +398,9 @@ func parseValuesFromString(s string) ([]float64, error) {
 			continue
 		}
 
-		value, err := strconv.ParseFloat(part, 64)
+		value, err := vp.Parse(part)
 		if err != nil {
-			return nil, fmt.Errorf("invalid number: %s", part)
+			return nil, err
 		}
 
 		values = append(values, value)
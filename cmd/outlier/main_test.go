@@ -1,8 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
+
+	"github.com/wingnut128/outlier-go/internal/parser"
 )
 
 func TestParseValuesFromString(t *testing.T) {
@@ -96,7 +102,7 @@ func TestParseValuesFromString(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseValuesFromString(tt.input)
+			got, err := parseValuesFromString(tt.input, &parser.ValueParser{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseValuesFromString() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -109,3 +115,159 @@ func TestParseValuesFromString(t *testing.T) {
 		})
 	}
 }
+
+func TestReadValuesFromFiles_Stdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	if _, err := w.WriteString("# seq output\n1\n2\n3 4\n"); err != nil {
+		t.Fatalf("failed to write to pipe: %v", err)
+	}
+	w.Close()
+
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	results, err := readValuesFromFiles([]string{"-"}, parser.NonFiniteReject, parser.Locale{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := results[0]
+	if !slices.Equal(got.Values, []float64{1, 2, 3, 4}) {
+		t.Errorf("expected [1 2 3 4], got %v", got.Values)
+	}
+}
+
+func TestReadValuesFromFiles_Lenient(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte("value\n1\nN/A\n3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readValuesFromFiles([]string{path}, parser.NonFiniteReject, parser.Locale{}); err == nil {
+		t.Error("expected error in strict mode, got nil")
+	}
+
+	parseMode = parser.ModeLenient
+	defer func() { parseMode = parser.ModeStrict }()
+
+	results, err := readValuesFromFiles([]string{path}, parser.NonFiniteReject, parser.Locale{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := results[0]
+	if !slices.Equal(got.Values, []float64{1, 3}) {
+		t.Errorf("expected [1 3], got %v", got.Values)
+	}
+	if got.Skipped != 1 || len(got.Errors) != 1 || got.Errors[0].Line != 3 {
+		t.Errorf("expected one skipped record on line 3, got %d %+v", got.Skipped, got.Errors)
+	}
+}
+
+func TestReadValuesFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.csv":         "value\n1\n2\n",
+		"nested/b.json": "[3, 4]",
+		"nested/c.txt":  "5\n",
+		"README.md":     "# not data",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := readValuesFromFiles([]string{filepath.Join(dir, "*.csv"), filepath.Join(dir, "nested")}, parser.NonFiniteReject, parser.Locale{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 sources, got %d", len(results))
+	}
+	merged, err := parser.Merge(results, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(merged.Values, []float64{1, 2, 3, 4, 5}) {
+		t.Errorf("expected [1 2 3 4 5], got %v", merged.Values)
+	}
+}
+
+func TestParseValuesFromString_Units(t *testing.T) {
+	vp, err := parser.NewValueParser("ms")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := parseValuesFromString("120ms, 1.5s, 80, 250us", vp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []float64{120, 1500, 80, 0.25}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if _, err := parseValuesFromString("1s, 2KB", vp); err == nil {
+		t.Error("expected error for mismatched units, got nil")
+	}
+}
+
+func TestParseValuesFromString_Kilobytes(t *testing.T) {
+	vp, err := parser.NewValueParser("B")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := parseValuesFromString("1kB,2kB,3KB", vp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []float64{1000, 2000, 3000}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestParseValuesFromString_Locale(t *testing.T) {
+	loc, err := parser.NewLocale("de", "", "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := parseValuesFromString("1,5; 1.234,5;2", (&parser.ValueParser{}).WithLocale(loc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []float64{1.5, 1234.5, 2}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	if got := formatValue(12.345, ""); got != "12.35" {
+		t.Errorf("expected 12.35, got %q", got)
+	}
+	if got := formatValue(120, "ms"); got != "120.00 ms" {
+		t.Errorf("expected \"120.00 ms\", got %q", got)
+	}
+}
+
+func TestPrintFormats(t *testing.T) {
+	var buf bytes.Buffer
+	printFormats(&buf)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) < 7 || !strings.HasPrefix(lines[0], "NAME") {
+		t.Fatalf("expected a header and a line per format, got:\n%s", buf.String())
+	}
+	for _, want := range []string{"csv", ".csv", "text/csv", "parquet", "accesslog"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in output:\n%s", want, buf.String())
+		}
+	}
+}
@@ -60,13 +60,57 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "408": {
+                        "description": "Request Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
         },
         "/calculate/file": {
             "post": {
-                "description": "Upload a JSON or CSV file and calculate percentile",
+                "description": "Upload a JSON, NDJSON, CSV, Parquet, Go benchmark, JUnit XML, HAR, OTLP trace or plain-text file (optionally gzip, zstd or bzip2 compressed) and calculate percentile. The format is detected from the filename, the part's Content-Type or the content itself. Repeat the file field to pool several files; per-file results are then reported in sources.",
                 "consumes": [
                     "multipart/form-data"
                 ],
@@ -80,7 +124,7 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "file",
-                        "description": "Data file (JSON or CSV)",
+                        "description": "Data file (JSON, NDJSON, CSV, Parquet or newline-separated numbers); may be repeated",
                         "name": "file",
                         "in": "formData",
                         "required": true
@@ -90,6 +134,132 @@ const docTemplate = `{
                         "description": "Percentile to calculate (default: 95)",
                         "name": "percentile",
                         "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "CSV or Parquet column holding the values (default: value)",
+                        "name": "column",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Convert values with duration or size suffixes (e.g. 120ms, 3KiB) into this unit",
+                        "name": "unit",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "strict (default) fails on the first invalid record; lenient skips and reports them",
+                        "name": "mode",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "NaN and ±Inf handling: reject, drop or clamp (default from server config)",
+                        "name": "non_finite",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Number format of the file, e.g. de (1.234,56) or fr (1 234,56)",
+                        "name": "locale",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Decimal separator, '.' or ',' (overrides locale)",
+                        "name": "decimal_separator",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Thousands separator to ignore (overrides locale)",
+                        "name": "grouping_separator",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Strip currency symbols and percent signs from values",
+                        "name": "strip_symbols",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Go benchmark metric to read (default: ns/op)",
+                        "name": "metric",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Grouping of labelled inputs: suite, class or name for JUnit reports, host or mime for HAR files, span, service or name for OTLP traces, name for Go benchmarks, or none",
+                        "name": "group_by",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "HAR timing phase: blocked, dns, connect, ssl, send, wait, receive or total (default)",
+                        "name": "har_phase",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include HAR entries whose URL contains this string",
+                        "name": "har_url",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include HAR entries for this host or its subdomains",
+                        "name": "har_host",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include HAR entries whose response MIME type has this prefix",
+                        "name": "har_mime",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include OTLP spans with this span or resource attribute, as key=value or key; may be repeated",
+                        "name": "span_attr",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include OTLP spans with this status: unset, ok or error",
+                        "name": "span_status",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Parse as an access log: nginx, apache, or a custom format string",
+                        "name": "log_format",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Parse as a log using a regex with a named 'value' group",
+                        "name": "log_pattern",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Log format variable holding the value",
+                        "name": "log_field",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated status codes or classes to include (e.g. 200,5xx)",
+                        "name": "log_status",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include log lines whose request path has this prefix",
+                        "name": "log_path_prefix",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
@@ -104,8 +274,52 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "408": {
+                        "description": "Request Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
         },
         "/health": {
@@ -139,6 +353,10 @@ const docTemplate = `{
                 "percentile": {
                     "type": "number"
                 },
+                "unit": {
+                    "description": "unit the values are expressed in, echoed in the response",
+                    "type": "string"
+                },
                 "values": {
                     "type": "array",
                     "items": {
@@ -150,14 +368,55 @@ const docTemplate = `{
         "api.CalculateResponse": {
             "type": "object",
             "properties": {
+                "clamped": {
+                    "description": "±Inf values replaced by the largest/smallest finite value",
+                    "type": "integer"
+                },
                 "count": {
                     "type": "integer"
                 },
+                "dropped": {
+                    "description": "NaN and ±Inf values removed by the non-finite policy",
+                    "type": "integer"
+                },
+                "errors": {
+                    "description": "sample of the skipped records",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.RecordError"
+                    }
+                },
+                "format": {
+                    "description": "detected input format for file uploads",
+                    "type": "string"
+                },
+                "groups": {
+                    "description": "per-series results, e.g. per benchmark",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.GroupResult"
+                    }
+                },
                 "percentile": {
                     "type": "number"
                 },
                 "result": {
                     "type": "number"
+                },
+                "skipped": {
+                    "description": "invalid records skipped in lenient mode",
+                    "type": "integer"
+                },
+                "sources": {
+                    "description": "per-file results when several files are uploaded",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SourceResult"
+                    }
+                },
+                "unit": {
+                    "description": "unit of the values and result",
+                    "type": "string"
                 }
             }
         },
@@ -169,6 +428,46 @@ const docTemplate = `{
                 }
             }
         },
+        "api.GroupResult": {
+            "type": "object",
+            "properties": {
+                "clamped": {
+                    "type": "integer"
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "dropped": {
+                    "type": "integer"
+                },
+                "error": {
+                    "description": "why the percentile could not be calculated",
+                    "type": "string"
+                },
+                "lower_fence": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "outlier_values": {
+                    "description": "named outliers, largest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.NamedValue"
+                    }
+                },
+                "outliers": {
+                    "type": "integer"
+                },
+                "result": {
+                    "type": "number"
+                },
+                "upper_fence": {
+                    "type": "number"
+                }
+            }
+        },
         "api.HealthResponse": {
             "type": "object",
             "properties": {
@@ -182,6 +481,82 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "api.NamedValue": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "number"
+                }
+            }
+        },
+        "api.RecordError": {
+            "type": "object",
+            "properties": {
+                "column": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "source": {
+                    "description": "uploaded file the record came from",
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.SourceResult": {
+            "type": "object",
+            "properties": {
+                "clamped": {
+                    "type": "integer"
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "dropped": {
+                    "type": "integer"
+                },
+                "error": {
+                    "description": "why the percentile could not be calculated",
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "result": {
+                    "type": "number"
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "source": {
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "API key, when authentication is enabled",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "\"Bearer \" followed by a JWT or API key, when authentication is enabled",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
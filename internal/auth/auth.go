@@ -0,0 +1,294 @@
+// Package auth authenticates API clients by API key, JWT bearer token or
+// mutual TLS client certificate and authorizes them by scope.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wingnut128/outlier-go/internal/config"
+)
+
+// Scopes granted to clients, each covering a group of endpoints
+const (
+	ScopeCalculate = "calculate"
+	ScopeMetrics   = "metrics"
+	ScopeDocs      = "docs"
+	// ScopeAll grants every scope
+	ScopeAll = "*"
+)
+
+// Authentication methods
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+	MethodMTLS   = "mtls"
+)
+
+// APIKeyHeader carries an API key, as an alternative to a bearer token
+const APIKeyHeader = "X-API-Key"
+
+var scopes = []string{ScopeCalculate, ScopeMetrics, ScopeDocs, ScopeAll}
+
+// ErrNoCredentials is returned for requests without an API key or token
+var ErrNoCredentials = errors.New("missing API key or bearer token")
+
+// Principal is an authenticated client
+type Principal struct {
+	Name   string
+	Method string // api_key, jwt or mtls
+	Scopes []string
+}
+
+// HasScope reports whether the principal was granted a scope
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAll)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying an authenticated principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal of a context, or nil if the
+// request was not authenticated
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Authenticator verifies the credentials of requests
+type Authenticator struct {
+	// keys maps the SHA-256 hash of each API key to its principal, so that
+	// keys are compared by hash rather than in plain text
+	keys      map[[sha256.Size]byte]*Principal
+	jwtSecret []byte
+	parser    *jwt.Parser
+	// clients maps client certificate identities to their principal
+	clients map[string]*Principal
+}
+
+// New creates an authenticator from the auth configuration, reading the keys
+// file, the OUTLIER_API_KEYS and OUTLIER_JWT_SECRET environment variables
+// through getenv, and the JWT secret file
+func New(cfg config.AuthConfig, getenv func(string) string) (*Authenticator, error) {
+	a := &Authenticator{
+		keys:    make(map[[sha256.Size]byte]*Principal),
+		clients: make(map[string]*Principal),
+	}
+	for _, k := range cfg.Keys {
+		if err := a.addKey(k.Name, k.Hash, k.Scopes); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.KeysFile != "" {
+		data, err := os.ReadFile(cfg.KeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keys file: %w", err)
+		}
+		if err := a.addEntries(string(data)); err != nil {
+			return nil, fmt.Errorf("keys file %s: %w", cfg.KeysFile, err)
+		}
+	}
+	if err := a.addEntries(getenv("OUTLIER_API_KEYS")); err != nil {
+		return nil, fmt.Errorf("OUTLIER_API_KEYS: %w", err)
+	}
+
+	secret := getenv("OUTLIER_JWT_SECRET")
+	if cfg.JWT.SecretFile != "" {
+		data, err := os.ReadFile(cfg.JWT.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT secret file: %w", err)
+		}
+		secret = strings.TrimSpace(string(data))
+	}
+	if secret != "" {
+		a.jwtSecret = []byte(secret)
+		opts := []jwt.ParserOption{
+			jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(cfg.JWT.Leeway.Duration),
+		}
+		if cfg.JWT.Issuer != "" {
+			opts = append(opts, jwt.WithIssuer(cfg.JWT.Issuer))
+		}
+		if cfg.JWT.Audience != "" {
+			opts = append(opts, jwt.WithAudience(cfg.JWT.Audience))
+		}
+		a.parser = jwt.NewParser(opts...)
+	}
+
+	for _, c := range cfg.Clients {
+		if c.Name == "" {
+			return nil, errors.New("client certificate without a name")
+		}
+		if err := checkScopes("client certificate", c.Name, c.Scopes); err != nil {
+			return nil, err
+		}
+		if _, ok := a.clients[c.Name]; ok {
+			return nil, fmt.Errorf("client certificate %q is configured twice", c.Name)
+		}
+		a.clients[c.Name] = &Principal{Name: c.Name, Method: MethodMTLS, Scopes: c.Scopes}
+	}
+
+	if len(a.keys) == 0 && a.parser == nil && len(a.clients) == 0 {
+		return nil, errors.New("authentication is enabled but no API keys, JWT secret or client certificates are configured")
+	}
+	return a, nil
+}
+
+// HashKey returns the hex SHA-256 hash of an API key, as stored in the
+// configuration
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// addEntries adds keys given as name:hash[:scope,...] entries separated by
+// newlines, semicolons or spaces. Lines starting with # are comments.
+func (a *Authenticator) addEntries(s string) error {
+	for line := range strings.Lines(s) {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, entry := range strings.FieldsFunc(line, isSeparator) {
+			parts := strings.Split(entry, ":")
+			if len(parts) < 2 || len(parts) > 3 {
+				return fmt.Errorf("entry %q is not name:hash[:scopes]", entry)
+			}
+			var scopes []string
+			if len(parts) == 3 && parts[2] != "" {
+				scopes = strings.Split(parts[2], ",")
+			}
+			if err := a.addKey(parts[0], parts[1], scopes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isSeparator(r rune) bool {
+	return r == ';' || unicode.IsSpace(r)
+}
+
+func (a *Authenticator) addKey(name, hash string, keyScopes []string) error {
+	if name == "" {
+		return errors.New("API key without a name")
+	}
+	decoded, err := hex.DecodeString(strings.ToLower(hash))
+	if err != nil || len(decoded) != sha256.Size {
+		return fmt.Errorf("API key %q: hash must be 64 hex characters of SHA-256", name)
+	}
+	if err := checkScopes("API key", name, keyScopes); err != nil {
+		return err
+	}
+	sum := [sha256.Size]byte(decoded)
+	if existing, ok := a.keys[sum]; ok {
+		return fmt.Errorf("API key %q has the same hash as %q", name, existing.Name)
+	}
+	a.keys[sum] = &Principal{Name: name, Method: MethodAPIKey, Scopes: keyScopes}
+	return nil
+}
+
+func checkScopes(kind, name string, granted []string) error {
+	for _, scope := range granted {
+		if !slices.Contains(scopes, scope) {
+			return fmt.Errorf("%s %q: unknown scope %q (supported: %s)", kind, name, scope, strings.Join(scopes, ", "))
+		}
+	}
+	return nil
+}
+
+// Authenticate returns the principal of a request's X-API-Key header or
+// bearer token, or else of its verified client certificate. Bearer tokens in
+// JWT form are verified as tokens when a JWT secret is configured, and
+// otherwise looked up as API keys.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return a.lookupKey(key)
+	}
+	scheme, credential, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(credential) == "" {
+		if name := ClientCertName(r.TLS); name != "" {
+			return a.lookupClient(name)
+		}
+		return nil, ErrNoCredentials
+	}
+	credential = strings.TrimSpace(credential)
+	if a.parser != nil && strings.Count(credential, ".") == 2 {
+		return a.verifyToken(credential)
+	}
+	return a.lookupKey(credential)
+}
+
+func (a *Authenticator) lookupKey(key string) (*Principal, error) {
+	if p, ok := a.keys[sha256.Sum256([]byte(key))]; ok {
+		return p, nil
+	}
+	return nil, errors.New("invalid API key")
+}
+
+func (a *Authenticator) lookupClient(name string) (*Principal, error) {
+	if p, ok := a.clients[name]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("client certificate %q is not authorized", name)
+}
+
+// ClientCertName returns the identity of a connection's verified client
+// certificate: its subject common name, or else its first URI or DNS subject
+// alternative name. It returns "" without a verified certificate.
+func ClientCertName(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	cert := state.VerifiedChains[0][0]
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	}
+	return ""
+}
+
+// claims are the registered JWT claims plus the granted scopes, as an OAuth
+// space-separated scope string or an scp list
+type claims struct {
+	jwt.RegisteredClaims
+	Scope string   `json:"scope,omitempty"`
+	Scp   []string `json:"scp,omitempty"`
+}
+
+func (a *Authenticator) verifyToken(token string) (*Principal, error) {
+	var c claims
+	if _, err := a.parser.ParseWithClaims(token, &c, func(*jwt.Token) (any, error) {
+		return a.jwtSecret, nil
+	}); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	// The subject names the client in logs and keys its rate limits
+	if c.Subject == "" {
+		return nil, errors.New("invalid token: missing sub claim")
+	}
+	return &Principal{
+		Name:   c.Subject,
+		Method: MethodJWT,
+		Scopes: append(strings.Fields(c.Scope), c.Scp...),
+	}, nil
+}
@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wingnut128/outlier-go/internal/config"
+)
+
+const secret = "test-secret"
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func request(header, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return r
+}
+
+func TestNew_Keys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys")
+	content := "# CI pipelines\nci:" + HashKey("ci-key") + ":calculate\n\nops:" + HashKey("ops-key") + ":metrics,docs\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.AuthConfig{
+		Keys:     []config.APIKeyConfig{{Name: "admin", Hash: strings.ToUpper(HashKey("admin-key")), Scopes: []string{ScopeAll}}},
+		KeysFile: file,
+	}
+	a, err := New(cfg, env(map[string]string{"OUTLIER_API_KEYS": "bot:" + HashKey("bot-key") + "; batch:" + HashKey("batch-key") + ":calculate"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		name   string
+		scopes []string
+	}{
+		{key: "admin-key", name: "admin", scopes: []string{ScopeAll}},
+		{key: "ci-key", name: "ci", scopes: []string{ScopeCalculate}},
+		{key: "ops-key", name: "ops", scopes: []string{ScopeMetrics, ScopeDocs}},
+		{key: "bot-key", name: "bot"},
+		{key: "batch-key", name: "batch", scopes: []string{ScopeCalculate}},
+	}
+	for _, tt := range tests {
+		p, err := a.Authenticate(request(APIKeyHeader, tt.key))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.key, err)
+			continue
+		}
+		if p.Name != tt.name || p.Method != MethodAPIKey || !slices.Equal(p.Scopes, tt.scopes) {
+			t.Errorf("%s: expected %s with scopes %v, got %+v", tt.key, tt.name, tt.scopes, p)
+		}
+	}
+
+	if p, err := a.Authenticate(request("Authorization", "Bearer ci-key")); err != nil || p.Name != "ci" {
+		t.Errorf("expected a bearer API key to authenticate ci, got %+v, %v", p, err)
+	}
+	if _, err := a.Authenticate(request(APIKeyHeader, "wrong")); err == nil {
+		t.Error("expected an unknown key to be rejected")
+	}
+	if _, err := a.Authenticate(request("", "")); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+	if _, err := a.Authenticate(request("Authorization", "Basic dXNlcjpwYXNz")); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials for basic auth, got %v", err)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	hash := HashKey("key")
+	tests := []struct {
+		name string
+		cfg  config.AuthConfig
+		env  map[string]string
+	}{
+		{name: "nothing configured"},
+		{name: "key without name", cfg: config.AuthConfig{Keys: []config.APIKeyConfig{{Hash: hash}}}},
+		{name: "plain key instead of hash", cfg: config.AuthConfig{Keys: []config.APIKeyConfig{{Name: "a", Hash: "key"}}}},
+		{name: "unknown scope", cfg: config.AuthConfig{Keys: []config.APIKeyConfig{{Name: "a", Hash: hash, Scopes: []string{"admin"}}}}},
+		{name: "duplicate hash", cfg: config.AuthConfig{Keys: []config.APIKeyConfig{{Name: "a", Hash: hash}, {Name: "b", Hash: hash}}}},
+		{name: "missing keys file", cfg: config.AuthConfig{KeysFile: filepath.Join(t.TempDir(), "missing")}},
+		{name: "malformed environment entry", env: map[string]string{"OUTLIER_API_KEYS": "just-a-name"}},
+		{name: "client without name", cfg: config.AuthConfig{Clients: []config.ClientCertConfig{{Scopes: []string{ScopeCalculate}}}}},
+		{name: "client with unknown scope", cfg: config.AuthConfig{Clients: []config.ClientCertConfig{{Name: "a", Scopes: []string{"admin"}}}}},
+		{name: "duplicate client", cfg: config.AuthConfig{Clients: []config.ClientCertConfig{{Name: "a"}, {Name: "a"}}}},
+		{name: "missing secret file", cfg: config.AuthConfig{JWT: config.JWTConfig{SecretFile: filepath.Join(t.TempDir(), "missing")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg, env(tt.env)); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestAuthenticate_JWT(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte(secret+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.AuthConfig{JWT: config.JWTConfig{
+		SecretFile: secretFile,
+		Issuer:     "https://issuer.example.com",
+		Audience:   "outlier",
+		Leeway:     config.Duration{Duration: time.Minute},
+	}}
+	a, err := New(cfg, env(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":   "alice",
+			"iss":   "https://issuer.example.com",
+			"aud":   "outlier",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "calculate docs",
+		}
+	}
+
+	p, err := a.Authenticate(request("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, []byte(secret), valid())))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Name != "alice" || p.Method != MethodJWT || !p.HasScope(ScopeCalculate) || !p.HasScope(ScopeDocs) || p.HasScope(ScopeMetrics) {
+		t.Errorf("unexpected principal %+v", p)
+	}
+
+	scp := valid()
+	delete(scp, "scope")
+	scp["scp"] = []string{"metrics"}
+	if p, err := a.Authenticate(request("Authorization", "bearer "+sign(t, jwt.SigningMethodHS512, []byte(secret), scp))); err != nil || !p.HasScope(ScopeMetrics) {
+		t.Errorf("expected scp scopes, got %+v, %v", p, err)
+	}
+
+	skewed := valid()
+	skewed["exp"] = time.Now().Add(-30 * time.Second).Unix()
+	if _, err := a.Authenticate(request("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, []byte(secret), skewed))); err != nil {
+		t.Errorf("expected an expiry within the leeway to be accepted, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		token  func() string
+		reason string
+	}{
+		{name: "expired", reason: "expired", token: func() string {
+			c := valid()
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+			return sign(t, jwt.SigningMethodHS256, []byte(secret), c)
+		}},
+		{name: "no expiry", reason: "exp", token: func() string {
+			c := valid()
+			delete(c, "exp")
+			return sign(t, jwt.SigningMethodHS256, []byte(secret), c)
+		}},
+		{name: "no subject", reason: "sub", token: func() string {
+			c := valid()
+			delete(c, "sub")
+			return sign(t, jwt.SigningMethodHS256, []byte(secret), c)
+		}},
+		{name: "empty subject", reason: "sub", token: func() string {
+			c := valid()
+			c["sub"] = ""
+			return sign(t, jwt.SigningMethodHS256, []byte(secret), c)
+		}},
+		{name: "wrong issuer", reason: "iss", token: func() string {
+			c := valid()
+			c["iss"] = "https://evil.example.com"
+			return sign(t, jwt.SigningMethodHS256, []byte(secret), c)
+		}},
+		{name: "wrong audience", reason: "aud", token: func() string {
+			c := valid()
+			c["aud"] = "other"
+			return sign(t, jwt.SigningMethodHS256, []byte(secret), c)
+		}},
+		{name: "wrong secret", reason: "signature", token: func() string {
+			return sign(t, jwt.SigningMethodHS256, []byte("other-secret"), valid())
+		}},
+		{name: "unsigned", reason: "signing method", token: func() string {
+			return sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid())
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Authenticate(request("Authorization", "Bearer "+tt.token()))
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.reason) {
+				t.Errorf("expected an error about %s, got %v", tt.reason, err)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_SecretFromEnvironment(t *testing.T) {
+	a, err := New(config.AuthConfig{}, env(map[string]string{"OUTLIER_JWT_SECRET": secret}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	token := sign(t, jwt.SigningMethodHS256, []byte(secret), jwt.MapClaims{"sub": "bob", "exp": time.Now().Add(time.Minute).Unix()})
+	if p, err := a.Authenticate(request("Authorization", "Bearer "+token)); err != nil || p.Name != "bob" || len(p.Scopes) != 0 {
+		t.Errorf("expected bob without scopes, got %+v, %v", p, err)
+	}
+}
+
+// withClientCert returns a request over a connection whose client presented a
+// verified certificate
+func withClientCert(r *http.Request, cert *x509.Certificate) *http.Request {
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return r
+}
+
+func TestAuthenticate_ClientCertificate(t *testing.T) {
+	cfg := config.AuthConfig{
+		Keys: []config.APIKeyConfig{{Name: "ci", Hash: HashKey("ci-key"), Scopes: []string{ScopeMetrics}}},
+		Clients: []config.ClientCertConfig{
+			{Name: "billing", Scopes: []string{ScopeCalculate}},
+			{Name: "spiffe://example.org/reporting", Scopes: []string{ScopeDocs}},
+		},
+	}
+	a, err := New(cfg, env(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	billing := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}, DNSNames: []string{"billing.internal"}}
+	p, err := a.Authenticate(withClientCert(request("", ""), billing))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Name != "billing" || p.Method != MethodMTLS || !p.HasScope(ScopeCalculate) || p.HasScope(ScopeMetrics) {
+		t.Errorf("unexpected principal %+v", p)
+	}
+
+	spiffe, _ := url.Parse("spiffe://example.org/reporting")
+	if p, err := a.Authenticate(withClientCert(request("", ""), &x509.Certificate{URIs: []*url.URL{spiffe}})); err != nil || !p.HasScope(ScopeDocs) {
+		t.Errorf("expected the URI SAN to identify the client, got %+v, %v", p, err)
+	}
+
+	// Explicit credentials take precedence over the certificate
+	if p, err := a.Authenticate(withClientCert(request(APIKeyHeader, "ci-key"), billing)); err != nil || p.Name != "ci" {
+		t.Errorf("expected the API key to authenticate ci, got %+v, %v", p, err)
+	}
+
+	unknown := &x509.Certificate{Subject: pkix.Name{CommonName: "stranger"}}
+	if _, err := a.Authenticate(withClientCert(request("", ""), unknown)); err == nil || errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected an unknown client certificate to be rejected, got %v", err)
+	}
+
+	// Presented but unverified certificates do not identify the client
+	r := request("", "")
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{billing}}
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+}
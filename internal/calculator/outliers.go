@@ -0,0 +1,56 @@
+package calculator
+
+// TukeyK is the multiple of the interquartile range beyond the quartiles at
+// which values are considered outliers
+const TukeyK = 1.5
+
+// Outliers summarizes the values outside Tukey's fences
+type Outliers struct {
+	Q1         float64
+	Q3         float64
+	LowerFence float64 // Q1 - 1.5 IQR
+	UpperFence float64 // Q3 + 1.5 IQR
+	Low        int     // values below LowerFence
+	High       int     // values above UpperFence
+}
+
+// Count returns the number of outliers on either side
+func (o Outliers) Count() int {
+	return o.Low + o.High
+}
+
+// IsOutlier reports whether v lies outside the fences
+func (o Outliers) IsOutlier(v float64) bool {
+	return v < o.LowerFence || v > o.UpperFence
+}
+
+// DetectOutliers finds values outside Tukey's fences, 1.5 interquartile ranges
+// below the first or above the third quartile. It fails under the same
+// conditions as CalculatePercentile.
+func DetectOutliers(values []float64) (Outliers, error) {
+	q1, err := CalculatePercentile(values, 25)
+	if err != nil {
+		return Outliers{}, err
+	}
+	q3, err := CalculatePercentile(values, 75)
+	if err != nil {
+		return Outliers{}, err
+	}
+
+	iqr := q3 - q1
+	o := Outliers{
+		Q1:         q1,
+		Q3:         q3,
+		LowerFence: q1 - TukeyK*iqr,
+		UpperFence: q3 + TukeyK*iqr,
+	}
+	for _, v := range values {
+		switch {
+		case v < o.LowerFence:
+			o.Low++
+		case v > o.UpperFence:
+			o.High++
+		}
+	}
+	return o, nil
+}
@@ -0,0 +1,46 @@
+package calculator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDetectOutliers(t *testing.T) {
+	values := []float64{10, 11, 12, 12, 13, 13, 14, 15, 40, -20}
+
+	o, err := DetectOutliers(values)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(o.Q1, 11.25) || !almostEqual(o.Q3, 13.75) {
+		t.Errorf("expected quartiles 11.25 and 13.75, got %.4f and %.4f", o.Q1, o.Q3)
+	}
+	if !almostEqual(o.LowerFence, 7.5) || !almostEqual(o.UpperFence, 17.5) {
+		t.Errorf("expected fences 7.5 and 17.5, got %.4f and %.4f", o.LowerFence, o.UpperFence)
+	}
+	if o.Low != 1 || o.High != 1 || o.Count() != 2 {
+		t.Errorf("expected one low and one high outlier, got %+v", o)
+	}
+	if !o.IsOutlier(40) || !o.IsOutlier(-20) || o.IsOutlier(15) {
+		t.Errorf("expected 40 and -20 to be outliers and 15 not, got %+v", o)
+	}
+}
+
+func TestDetectOutliers_NoSpread(t *testing.T) {
+	o, err := DetectOutliers([]float64{5, 5, 5, 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if o.Count() != 0 {
+		t.Errorf("expected no outliers, got %+v", o)
+	}
+}
+
+func TestDetectOutliers_Errors(t *testing.T) {
+	if _, err := DetectOutliers(nil); err == nil {
+		t.Error("expected error for empty dataset, got nil")
+	}
+	if _, err := DetectOutliers([]float64{1, math.NaN()}); err == nil {
+		t.Error("expected error for NaN, got nil")
+	}
+}
@@ -2,21 +2,29 @@ package calculator
 
 import (
 	"fmt"
+	"math"
 	"sort"
 )
 
 // CalculatePercentile calculates the percentile value using linear interpolation.
 // The percentile should be between 0 and 100.
-// Returns an error if the values slice is empty or percentile is out of range.
+// Returns an error if the values slice is empty, percentile is out of range, or
+// any value is NaN or ±Inf.
 func CalculatePercentile(values []float64, percentile float64) (float64, error) {
 	if len(values) == 0 {
 		return 0, fmt.Errorf("cannot calculate percentile of empty dataset")
 	}
 
-	if percentile < 0 || percentile > 100 {
+	if percentile < 0 || percentile > 100 || math.IsNaN(percentile) {
 		return 0, fmt.Errorf("percentile must be between 0 and 100, got %.2f", percentile)
 	}
 
+	for i, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return 0, fmt.Errorf("non-finite value %v at index %d", v, i)
+		}
+	}
+
 	// Create a copy to avoid modifying the original slice
 	sorted := make([]float64, len(values))
 	copy(sorted, values)
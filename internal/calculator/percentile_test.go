@@ -154,3 +154,17 @@ func TestCalculatePercentile_DoesNotModifyOriginal(t *testing.T) {
 		}
 	}
 }
+
+func TestCalculatePercentile_NonFinite(t *testing.T) {
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := CalculatePercentile([]float64{1, v, 3}, 50); err == nil {
+			t.Errorf("expected error for %v, got nil", v)
+		}
+	}
+}
+
+func TestCalculatePercentile_NaNPercentile(t *testing.T) {
+	if _, err := CalculatePercentile([]float64{1, 2, 3}, math.NaN()); err == nil {
+		t.Error("expected error for NaN percentile, got nil")
+	}
+}
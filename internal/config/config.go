@@ -3,14 +3,42 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Logging LoggingConfig `toml:"logging"`
-	Server  ServerConfig  `toml:"server"`
+	Logging   LoggingConfig   `toml:"logging"`
+	Server    ServerConfig    `toml:"server"`
+	Metrics   MetricsConfig   `toml:"metrics"`
+	CORS      CORSConfig      `toml:"cors"`
+	Auth      AuthConfig      `toml:"auth"`
+	RateLimit RateLimitConfig `toml:"rate_limit"`
+	Telemetry TelemetryConfig `toml:"telemetry"`
+}
+
+// Duration is a time.Duration read from a string such as "30s" or "1m30s"
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses a duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalText formats the duration as a string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
 }
 
 // LoggingConfig represents logging configuration
@@ -18,25 +46,227 @@ type LoggingConfig struct {
 	Level  string `toml:"level"`  // trace, debug, info, warn, error
 	Output string `toml:"output"` // stdout, stderr, file
 	Format string `toml:"format"` // compact, pretty, json
+	// File is the log path when Output is file. It is rotated once it
+	// reaches MaxSizeMB, keeping MaxBackups old files for MaxAgeDays
+	// (zero keeps them all).
+	File       string `toml:"file"`
+	MaxSizeMB  int    `toml:"max_size_mb"`
+	MaxBackups int    `toml:"max_backups"`
+	MaxAgeDays int    `toml:"max_age_days"`
+	Compress   bool   `toml:"compress"` // gzip rotated files
 }
 
 // ServerConfig represents server configuration
 type ServerConfig struct {
 	BindIP string `toml:"bind_ip"`
 	Port   int    `toml:"port"`
+	// MaxDecompressedSize caps the expanded size of compressed uploads, and the
+	// Parquet input buffered in memory, in bytes
+	MaxDecompressedSize int64 `toml:"max_decompressed_size"`
+	// NonFinite is the default policy for NaN and ±Inf values in uploads:
+	// reject, drop or clamp
+	NonFinite string `toml:"non_finite"`
+	// TrustedProxies lists the proxy IPs and CIDRs whose X-Forwarded-For and
+	// X-Real-IP headers give the client IP; by default none are trusted and
+	// clients are identified by their connection's address
+	TrustedProxies []string `toml:"trusted_proxies"`
+
+	// MaxBodySize caps request bodies in bytes on every route; zero is
+	// unlimited. Uploads beyond MaxMultipartMemory are spooled to disk.
+	MaxBodySize        int64 `toml:"max_body_size"`
+	MaxMultipartMemory int64 `toml:"max_multipart_memory"`
+	// MaxValues caps the values of a calculation request; zero is unlimited
+	MaxValues int `toml:"max_values"`
+	// CalculationTimeout bounds the parsing and calculation of a request;
+	// zero is unlimited. Keep it below WriteTimeout so that the timeout
+	// response can be written.
+	CalculationTimeout Duration `toml:"calculation_timeout"`
+
+	// HTTP server timeouts, and the grace period in-flight requests get to
+	// finish on shutdown
+	ReadHeaderTimeout Duration `toml:"read_header_timeout"`
+	ReadTimeout       Duration `toml:"read_timeout"`
+	WriteTimeout      Duration `toml:"write_timeout"`
+	IdleTimeout       Duration `toml:"idle_timeout"`
+	ShutdownTimeout   Duration `toml:"shutdown_timeout"`
+
+	TLS TLSConfig `toml:"tls"`
+}
+
+// TLSConfig represents HTTPS serving. The certificate, key and client CA
+// bundle are reloaded when their files change.
+type TLSConfig struct {
+	Enabled  bool   `toml:"enabled"`
+	CertFile string `toml:"cert_file"` // PEM certificate chain
+	KeyFile  string `toml:"key_file"`  // PEM private key
+	// ClientAuth verifies client certificates against the ClientCAFile
+	// bundle: none, optional (verified when presented) or require
+	ClientAuth   string `toml:"client_auth"`
+	ClientCAFile string `toml:"client_ca_file"`
+	MinVersion   string `toml:"min_version"` // 1.2 or 1.3
+	// CipherSuites restricts the TLS 1.2 cipher suites, by Go name such as
+	// TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256; TLS 1.3 suites are fixed
+	CipherSuites []string `toml:"cipher_suites"`
+}
+
+// MetricsConfig represents the Prometheus metrics endpoint configuration
+type MetricsConfig struct {
+	Enabled bool   `toml:"enabled"`
+	Path    string `toml:"path"` // must start with "/"
+}
+
+// CORSConfig represents the cross-origin resource sharing policy. Browsers
+// may only call the API from the listed origins, or from any origin with
+// AllowAllOrigins.
+type CORSConfig struct {
+	// AllowedOrigins are exact origins such as "https://app.example.com" or
+	// wildcard subdomain patterns such as "https://*.example.com"
+	AllowedOrigins []string `toml:"allowed_origins"`
+	// AllowAllOrigins opts in to any origin; it excludes AllowCredentials
+	AllowAllOrigins  bool     `toml:"allow_all_origins"`
+	AllowedMethods   []string `toml:"allowed_methods"`
+	AllowedHeaders   []string `toml:"allowed_headers"`
+	ExposedHeaders   []string `toml:"exposed_headers"`
+	AllowCredentials bool     `toml:"allow_credentials"`
+	MaxAge           Duration `toml:"max_age"` // how long browsers cache preflight results
+}
+
+// AuthConfig represents API authentication. When enabled, requests need an
+// API key or a JWT bearer token granting the scope of the endpoint.
+type AuthConfig struct {
+	Enabled bool `toml:"enabled"`
+	// ExemptHealth leaves /health open for probes
+	ExemptHealth bool           `toml:"exempt_health"`
+	Keys         []APIKeyConfig `toml:"keys"`
+	// KeysFile holds further keys, one name:hash[:scope,...] entry per line;
+	// the OUTLIER_API_KEYS environment variable adds more
+	KeysFile string    `toml:"keys_file"`
+	JWT      JWTConfig `toml:"jwt"`
+	// Clients grants scopes to clients authenticated by a certificate
+	// verified with mutual TLS
+	Clients []ClientCertConfig `toml:"clients"`
+}
+
+// APIKeyConfig represents an API key, stored as the hex SHA-256 hash of the
+// key
+type APIKeyConfig struct {
+	Name   string   `toml:"name"`
+	Hash   string   `toml:"hash"`
+	Scopes []string `toml:"scopes"` // calculate, metrics, docs, or * for all
+}
+
+// ClientCertConfig represents a client certificate identity: the subject
+// common name, or else the first URI or DNS subject alternative name
+type ClientCertConfig struct {
+	Name   string   `toml:"name"`
+	Scopes []string `toml:"scopes"`
+}
+
+// JWTConfig represents the verification of HMAC-signed JWT bearer tokens.
+// The secret is read from SecretFile or the OUTLIER_JWT_SECRET environment
+// variable; tokens are only accepted when one is set.
+type JWTConfig struct {
+	SecretFile string   `toml:"secret_file"`
+	Issuer     string   `toml:"issuer"`   // required iss claim, if set
+	Audience   string   `toml:"audience"` // required aud claim, if set
+	Leeway     Duration `toml:"leeway"`   // clock skew allowed on exp and nbf
+}
+
+// RateLimitConfig represents per-client rate limits and the cap on concurrent
+// calculations. Clients are identified by their authenticated name, or else
+// their IP address.
+type RateLimitConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Rate is the requests per second each client may make to each route,
+	// in bursts of up to Burst; zero leaves routes unlimited
+	Rate   float64                `toml:"rate"`
+	Burst  int                    `toml:"burst"`
+	Routes []RouteRateLimitConfig `toml:"routes"` // per-route overrides
+	// MaxConcurrent caps the calculations in progress; further ones wait in a
+	// queue of up to MaxQueue for up to QueueTimeout. Zero leaves
+	// calculations unbounded.
+	MaxConcurrent int      `toml:"max_concurrent"`
+	MaxQueue      int      `toml:"max_queue"`
+	QueueTimeout  Duration `toml:"queue_timeout"`
+}
+
+// RouteRateLimitConfig overrides the rate limit of a route, such as
+// "/calculate/file"
+type RouteRateLimitConfig struct {
+	Route string  `toml:"route"`
+	Rate  float64 `toml:"rate"`
+	Burst int     `toml:"burst"`
+}
+
+// TelemetryConfig represents OpenTelemetry export configuration. The standard
+// OTEL_* environment variables override these settings.
+type TelemetryConfig struct {
+	Exporter    string            `toml:"exporter"` // otlp, stdout, file, none
+	Preset      string            `toml:"preset"`   // honeycomb
+	ServiceName string            `toml:"service_name"`
+	Endpoint    string            `toml:"endpoint"` // host:port or URL of the OTLP receiver
+	Protocol    string            `toml:"protocol"` // grpc, http/protobuf
+	Headers     map[string]string `toml:"headers"`
+	Insecure    bool              `toml:"insecure"`
+	Compression string            `toml:"compression"`  // gzip, none
+	SampleRatio float64           `toml:"sample_ratio"` // fraction of traces sampled, 0 to 1
+	File        string            `toml:"file"`         // output path of the file exporter
+	Signals     []string          `toml:"signals"`      // traces, metrics, logs
 }
 
 // DefaultConfig returns a configuration with default values
 func DefaultConfig() *Config {
 	return &Config{
 		Logging: LoggingConfig{
-			Level:  "info",
-			Output: "stdout",
-			Format: "compact",
+			Level:      "info",
+			Output:     "stdout",
+			Format:     "compact",
+			MaxSizeMB:  100,
+			MaxBackups: 5,
 		},
 		Server: ServerConfig{
-			Port:   3000,
-			BindIP: "0.0.0.0",
+			Port:                3000,
+			BindIP:              "0.0.0.0",
+			MaxDecompressedSize: 1 << 30, // 1 GiB
+			NonFinite:           "reject",
+			MaxBodySize:         100 << 20, // 100 MiB
+			MaxMultipartMemory:  100 << 20, // 100 MiB
+			MaxValues:           10_000_000,
+			CalculationTimeout:  Duration{Duration: 20 * time.Second},
+			ReadHeaderTimeout:   Duration{Duration: 10 * time.Second},
+			ReadTimeout:         Duration{Duration: 30 * time.Second},
+			WriteTimeout:        Duration{Duration: 30 * time.Second},
+			IdleTimeout:         Duration{Duration: 120 * time.Second},
+			ShutdownTimeout:     Duration{Duration: 10 * time.Second},
+			TLS: TLSConfig{
+				ClientAuth: "none",
+				MinVersion: "1.2",
+			},
+		},
+		Metrics: MetricsConfig{
+			Path: "/metrics",
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+			AllowedHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", "X-Request-ID"},
+			ExposedHeaders: []string{"X-Request-ID"},
+			MaxAge:         Duration{Duration: time.Hour},
+		},
+		Auth: AuthConfig{
+			ExemptHealth: true,
+		},
+		RateLimit: RateLimitConfig{
+			Rate:          10,
+			Burst:         20,
+			MaxConcurrent: 8,
+			MaxQueue:      64,
+			QueueTimeout:  Duration{Duration: 10 * time.Second},
+		},
+		Telemetry: TelemetryConfig{
+			ServiceName: "outlier",
+			Protocol:    "grpc",
+			SampleRatio: 1,
+			Signals:     []string{"traces", "metrics", "logs"},
 		},
 	}
 }
@@ -52,10 +282,75 @@ func LoadConfig(path string) (*Config, error) {
 	if err := toml.Unmarshal(data, config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
 
 	return config, nil
 }
 
+// Validate checks settings that cannot be used as given
+func (c *Config) Validate() error {
+	if c.Metrics.Enabled {
+		if err := c.Metrics.validate(); err != nil {
+			return err
+		}
+	}
+	return c.Server.validate()
+}
+
+// apiPaths are the routes of the API, which the metrics endpoint must not
+// shadow
+var apiPaths = []string{"/health", "/calculate", "/calculate/file"}
+
+// docsPrefix is the path under which the API documentation is served
+const docsPrefix = "/docs"
+
+// validate checks that the metrics path is absolute and served by no other
+// route
+func (m *MetricsConfig) validate() error {
+	switch {
+	case !strings.HasPrefix(m.Path, "/"):
+		return fmt.Errorf("metrics path %q must start with /", m.Path)
+	case strings.ContainsAny(m.Path, ":*"):
+		return fmt.Errorf("metrics path %q must not contain route parameters", m.Path)
+	case slices.Contains(apiPaths, strings.TrimSuffix(m.Path, "/")),
+		m.Path == docsPrefix || strings.HasPrefix(m.Path, docsPrefix+"/"):
+		return fmt.Errorf("metrics path %q is used by the API", m.Path)
+	}
+	return nil
+}
+
+// validate checks the server's limits, timeouts and non-finite policy
+func (s *ServerConfig) validate() error {
+	switch strings.ToLower(strings.TrimSpace(s.NonFinite)) {
+	case "", "reject", "drop", "clamp":
+	default:
+		return fmt.Errorf("unknown non_finite policy %q (supported: reject, drop, clamp)", s.NonFinite)
+	}
+	if s.MaxBodySize < 0 || s.MaxMultipartMemory < 0 || s.MaxValues < 0 {
+		return fmt.Errorf("max_body_size, max_multipart_memory and max_values must not be negative")
+	}
+	if s.CalculationTimeout.Duration < 0 {
+		return fmt.Errorf("calculation_timeout must not be negative")
+	}
+	for _, d := range []struct {
+		name  string
+		value Duration
+	}{
+		{"read_header_timeout", s.ReadHeaderTimeout},
+		{"read_timeout", s.ReadTimeout},
+		{"write_timeout", s.WriteTimeout},
+		{"idle_timeout", s.IdleTimeout},
+		{"shutdown_timeout", s.ShutdownTimeout},
+	} {
+		if d.value.Duration <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", d.name, d.value)
+		}
+	}
+	return nil
+}
+
 // LoadConfigWithPriority loads configuration with the following priority:
 // 1. Provided configPath (if not empty)
 // 2. CONFIG_FILE environment variable
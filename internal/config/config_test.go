@@ -1,9 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -24,6 +26,15 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.Server.BindIP != "0.0.0.0" {
 		t.Errorf("expected bind IP '0.0.0.0', got '%s'", cfg.Server.BindIP)
 	}
+	if cfg.Server.MaxDecompressedSize != 1<<30 {
+		t.Errorf("expected max decompressed size 1 GiB, got %d", cfg.Server.MaxDecompressedSize)
+	}
+	if cfg.Server.NonFinite != "reject" {
+		t.Errorf("expected non-finite policy 'reject', got %q", cfg.Server.NonFinite)
+	}
+	if cfg.Metrics.Enabled || cfg.Metrics.Path != "/metrics" {
+		t.Errorf("expected metrics disabled with path /metrics, got %+v", cfg.Metrics)
+	}
 }
 
 func TestLoadConfig(t *testing.T) {
@@ -66,6 +77,59 @@ bind_ip = "127.0.0.1"
 	}
 }
 
+func TestLoadConfig_ServerLimits(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	content := `[server]
+max_body_size = 1048576
+max_values = 1000
+calculation_timeout = "5s"
+write_timeout = "1m"
+shutdown_timeout = "30s"
+`
+	if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to create test config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Server.MaxBodySize != 1<<20 || cfg.Server.MaxValues != 1000 {
+		t.Errorf("expected the configured limits, got %d bytes and %d values", cfg.Server.MaxBodySize, cfg.Server.MaxValues)
+	}
+	if cfg.Server.CalculationTimeout.Duration != 5*time.Second || cfg.Server.WriteTimeout.Duration != time.Minute || cfg.Server.ShutdownTimeout.Duration != 30*time.Second {
+		t.Errorf("expected the configured timeouts, got %+v", cfg.Server)
+	}
+	// Unset settings keep their defaults
+	if cfg.Server.ReadHeaderTimeout.Duration != 10*time.Second || cfg.Server.MaxMultipartMemory != 100<<20 {
+		t.Errorf("expected default read header timeout and multipart memory, got %+v", cfg.Server)
+	}
+
+	for _, content := range []string{
+		"[server]\nread_timeout = \"30\"\n",
+		"[server]\nshutdown_timeout = \"0s\"\n",
+		"[server]\nread_header_timeout = \"-1s\"\n",
+		"[server]\ncalculation_timeout = \"-5s\"\n",
+		"[server]\nmax_values = -1\n",
+		"[server]\nnon_finite = \"ignore\"\n",
+	} {
+		if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(configFile); err == nil {
+			t.Errorf("expected %q to be rejected", content)
+		}
+	}
+
+	// A zero calculation timeout is unlimited
+	if err := os.WriteFile(configFile, []byte("[server]\ncalculation_timeout = \"0s\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(configFile); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestLoadConfig_InvalidFile(t *testing.T) {
 	_, err := LoadConfig("/nonexistent/config.toml")
 	if err == nil {
@@ -89,6 +153,99 @@ func TestLoadConfig_InvalidTOML(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_Metrics(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.toml")
+
+	content := `[metrics]
+enabled = true
+path = "/internal/metrics"
+`
+	if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to create test config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Metrics.Enabled || cfg.Metrics.Path != "/internal/metrics" {
+		t.Errorf("expected metrics enabled at /internal/metrics, got %+v", cfg.Metrics)
+	}
+
+	for _, path := range []string{"metrics", "/health", "/calculate", "/calculate/file/", "/docs", "/docs/metrics", "/metrics/:name"} {
+		content := fmt.Sprintf("[metrics]\nenabled = true\npath = %q\n", path)
+		if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to create test config file: %v", err)
+		}
+		if _, err := LoadConfig(configFile); err == nil {
+			t.Errorf("expected error for metrics path %q, got nil", path)
+		}
+	}
+
+	// Paths are only checked when metrics are enabled
+	if err := os.WriteFile(configFile, []byte("[metrics]\npath = \"/health\"\n"), 0o644); err != nil {
+		t.Fatalf("failed to create test config file: %v", err)
+	}
+	if _, err := LoadConfig(configFile); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadConfig_Auth(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.toml")
+
+	content := `[auth]
+enabled = true
+
+[[auth.keys]]
+name = "ci"
+hash = "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"
+scopes = ["calculate"]
+
+[auth.jwt]
+audience = "outlier"
+leeway = "1m30s"
+`
+	if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to create test config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Auth.Enabled || !cfg.Auth.ExemptHealth {
+		t.Errorf("expected auth enabled with /health exempt, got %+v", cfg.Auth)
+	}
+	if len(cfg.Auth.Keys) != 1 || cfg.Auth.Keys[0].Name != "ci" || cfg.Auth.Keys[0].Scopes[0] != "calculate" {
+		t.Errorf("unexpected keys %+v", cfg.Auth.Keys)
+	}
+	if cfg.Auth.JWT.Leeway.Duration != 90*time.Second {
+		t.Errorf("expected a leeway of 1m30s, got %v", cfg.Auth.JWT.Leeway)
+	}
+
+	if err := os.WriteFile(configFile, []byte("[auth.jwt]\nleeway = \"soon\"\n"), 0o644); err != nil {
+		t.Fatalf("failed to create test config file: %v", err)
+	}
+	if _, err := LoadConfig(configFile); err == nil {
+		t.Error("expected error for an invalid duration, got nil")
+	}
+}
+
+func TestLoadConfig_Examples(t *testing.T) {
+	paths, err := filepath.Glob("../../configs/*.toml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("expected example configurations, got %v, %v", paths, err)
+	}
+	for _, path := range paths {
+		if _, err := LoadConfig(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
 func TestLoadConfigWithPriority_ExplicitPath(t *testing.T) {
 	tmpDir := t.TempDir()
 	configFile := filepath.Join(tmpDir, "config.toml")
@@ -0,0 +1,142 @@
+// Package logging builds the application's slog logger from the logging
+// configuration.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/wingnut128/outlier-go/internal/config"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Formats
+const (
+	FormatCompact = "compact"
+	FormatPretty  = "pretty"
+	FormatJSON    = "json"
+)
+
+// Outputs
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+	OutputFile   = "file"
+)
+
+// LevelTrace is below slog's DEBUG, for the most verbose logging
+const LevelTrace = slog.LevelDebug - 4
+
+var levels = map[string]slog.Level{
+	"trace": LevelTrace,
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// ParseLevel parses a level name: trace, debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	level, ok := levels[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %q (supported: trace, debug, info, warn, error)", name)
+	}
+	return level, nil
+}
+
+// New creates a logger from the logging configuration. Records at or above
+// the configured level are written in the configured format and passed on
+// to the extra handlers, such as the OpenTelemetry bridge. Every record
+// logged with a request's context carries its request ID; written records
+// also carry its trace and span IDs. The returned closer releases the log
+// file, if any.
+func New(cfg config.LoggingConfig, extra ...slog.Handler) (*slog.Logger, io.Closer, error) {
+	level := slog.LevelInfo
+	if cfg.Level != "" {
+		var err error
+		if level, err = ParseLevel(cfg.Level); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	out, closer, err := openOutput(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: replaceLevel}
+	var h slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case FormatCompact, "":
+		h = slog.NewTextHandler(out, opts)
+	case FormatPretty:
+		h = newPrettyHandler(out, opts, isTerminal(out))
+	case FormatJSON:
+		h = slog.NewJSONHandler(out, opts)
+	default:
+		_ = closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q (supported: %s, %s, %s)", cfg.Format, FormatCompact, FormatPretty, FormatJSON)
+	}
+
+	handlers := []slog.Handler{&contextHandler{Handler: h, traceIDs: true}}
+	for _, e := range extra {
+		handlers = append(handlers, &contextHandler{Handler: e})
+	}
+	return slog.New(&fanoutHandler{level: level, handlers: handlers}), closer, nil
+}
+
+// openOutput opens the configured output. Standard streams are not closed.
+func openOutput(cfg config.LoggingConfig) (io.Writer, io.Closer, error) {
+	switch strings.ToLower(cfg.Output) {
+	case OutputStdout, "":
+		return os.Stdout, nopCloser{}, nil
+	case OutputStderr:
+		return os.Stderr, nopCloser{}, nil
+	case OutputFile:
+		if cfg.File == "" {
+			return nil, nil, fmt.Errorf("log output file requires a file path")
+		}
+		// Open eagerly so that an unwritable path fails at startup
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		_ = f.Close()
+		w := &lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.MaxSizeMB,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAgeDays,
+			Compress:   cfg.Compress,
+		}
+		return w, w, nil
+	}
+	return nil, nil, fmt.Errorf("unknown log output %q (supported: %s, %s, %s)", cfg.Output, OutputStdout, OutputStderr, OutputFile)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// replaceLevel names LevelTrace, which slog would print as DEBUG-4
+func replaceLevel(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.LevelKey {
+		if level, ok := a.Value.Any().(slog.Level); ok && level < slog.LevelDebug {
+			a.Value = slog.StringValue("TRACE")
+		}
+	}
+	return a
+}
+
+// isTerminal reports whether w is a character device, for colored output
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,184 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wingnut128/outlier-go/internal/config"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// newFileLogger creates a logger writing to a temporary file, returning the
+// file's path
+func newFileLogger(t *testing.T, cfg config.LoggingConfig, extra ...slog.Handler) (*slog.Logger, string) {
+	t.Helper()
+	cfg.Output = OutputFile
+	cfg.File = filepath.Join(t.TempDir(), "outlier.log")
+	logger, closer, err := New(cfg, extra...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = closer.Close() })
+	return logger, cfg.File
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+func TestNew_JSON(t *testing.T) {
+	logger, path := newFileLogger(t, config.LoggingConfig{Level: "info", Format: FormatJSON})
+
+	logger.Debug("hidden")
+	logger.Info("request", "path", `/calculate?q="quoted"`)
+
+	lines := readLines(t, path)
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %d: %q", len(lines), lines)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("expected valid JSON, got %s: %v", lines[0], err)
+	}
+	if entry["path"] != `/calculate?q="quoted"` || entry["level"] != "INFO" || entry["msg"] != "request" {
+		t.Errorf("unexpected entry %v", entry)
+	}
+}
+
+func TestNew_Compact(t *testing.T) {
+	logger, path := newFileLogger(t, config.LoggingConfig{Level: "trace", Format: FormatCompact})
+
+	logger.Log(context.Background(), LevelTrace, "verbose", "n", 1)
+
+	line := readLines(t, path)[0]
+	if !strings.Contains(line, "level=TRACE msg=verbose n=1") {
+		t.Errorf("expected a logfmt TRACE line, got %q", line)
+	}
+}
+
+func TestNew_ContextIDs(t *testing.T) {
+	extra := &captureHandler{}
+	logger, path := newFileLogger(t, config.LoggingConfig{Format: FormatJSON}, extra)
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	ctx = WithRequestID(ctx, "req-1")
+	logger.InfoContext(ctx, "with ids")
+	logger.Info("without ids")
+
+	lines := readLines(t, path)
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry[RequestIDKey] != "req-1" {
+		t.Errorf("expected request_id req-1, got %v", entry[RequestIDKey])
+	}
+	if entry[TraceIDKey] != span.SpanContext().TraceID().String() || entry[SpanIDKey] != span.SpanContext().SpanID().String() {
+		t.Errorf("expected the span's trace and span IDs, got %v", entry)
+	}
+	if strings.Contains(lines[1], RequestIDKey) || strings.Contains(lines[1], TraceIDKey) {
+		t.Errorf("expected no IDs without a request context, got %s", lines[1])
+	}
+
+	if len(extra.records) != 2 {
+		t.Fatalf("expected the extra handler to get 2 records, got %d", len(extra.records))
+	}
+	attrs := make(map[string]string)
+	extra.records[0].Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value.String()
+		return true
+	})
+	if attrs[RequestIDKey] != "req-1" {
+		t.Errorf("expected the extra handler to get the request ID, got %v", attrs)
+	}
+	if _, ok := attrs[TraceIDKey]; ok {
+		t.Error("expected no trace ID attribute for the extra handler")
+	}
+}
+
+func TestNew_Rotation(t *testing.T) {
+	logger, path := newFileLogger(t, config.LoggingConfig{Format: FormatCompact, MaxSizeMB: 1, MaxBackups: 1})
+
+	line := strings.Repeat("x", 1024)
+	for range 1100 {
+		logger.Info(line)
+	}
+
+	matches, err := filepath.Glob(strings.TrimSuffix(path, ".log") + "-*.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Errorf("expected 1 rotated file, got %v", matches)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.LoggingConfig
+	}{
+		{name: "unknown level", cfg: config.LoggingConfig{Level: "verbose"}},
+		{name: "unknown format", cfg: config.LoggingConfig{Format: "logfmt"}},
+		{name: "unknown output", cfg: config.LoggingConfig{Output: "syslog"}},
+		{name: "file output without path", cfg: config.LoggingConfig{Output: OutputFile}},
+		{name: "unwritable file", cfg: config.LoggingConfig{Output: OutputFile, File: filepath.Join(t.TempDir(), "missing", "outlier.log")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := New(tt.cfg); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestPrettyHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newPrettyHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}, false))
+
+	logger.With("service", "outlier").WithGroup("req").Warn("slow request", "status", 200)
+	logger.Log(context.Background(), LevelTrace, "hidden")
+
+	line := strings.TrimRight(buf.String(), "\n")
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("expected 1 line, got %q", buf.String())
+	}
+	if !strings.HasSuffix(line, "WARN   slow request  service=outlier req.status=200") {
+		t.Errorf("unexpected line %q", line)
+	}
+
+	buf.Reset()
+	colored := slog.New(newPrettyHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}, true))
+	colored.Error("failed")
+	if !strings.Contains(buf.String(), colorRed+"ERROR"+colorReset) {
+		t.Errorf("expected a colored level, got %q", buf.String())
+	}
+}
+
+// captureHandler keeps every record it handles
+type captureHandler struct {
+	records []slog.Record
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *captureHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	h.records = append(h.records, r)
+	return nil
+}
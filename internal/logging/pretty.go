@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// ANSI colors of the pretty format's levels
+const (
+	colorReset  = "\033[0m"
+	colorGray   = "\033[90m"
+	colorCyan   = "\033[36m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorRed    = "\033[31m"
+)
+
+// prettyHandler writes human-readable lines: the time, padded level and
+// message, followed by the attributes as key=value pairs. Levels are colored
+// on terminals.
+type prettyHandler struct {
+	// attrs formats the attributes into buf, leaving out the time, level and
+	// message
+	attrs slog.Handler
+	level slog.Leveler
+	color bool
+	state *prettyState
+}
+
+// prettyState is shared by a handler and those derived from it
+type prettyState struct {
+	mu  sync.Mutex
+	out io.Writer
+	buf bytes.Buffer
+}
+
+func newPrettyHandler(out io.Writer, opts *slog.HandlerOptions, color bool) *prettyHandler {
+	state := &prettyState{out: out}
+	attrs := slog.NewTextHandler(&state.buf, &slog.HandlerOptions{
+		Level: slog.Level(math.MinInt), // levels are checked by the pretty handler
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return &prettyHandler{attrs: attrs, level: opts.Level, color: color, state: state}
+}
+
+func (h *prettyHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *prettyHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	h.state.buf.Reset()
+	if err := h.attrs.Handle(ctx, r); err != nil {
+		return err
+	}
+	attrs := bytes.TrimRight(h.state.buf.Bytes(), "\n")
+
+	var line bytes.Buffer
+	if !r.Time.IsZero() {
+		line.WriteString(r.Time.Format(time.DateTime + ".000"))
+		line.WriteByte(' ')
+	}
+	name, color := levelName(r.Level)
+	if h.color {
+		line.WriteString(color + name + colorReset)
+	} else {
+		line.WriteString(name)
+	}
+	line.WriteString("  ")
+	line.WriteString(r.Message)
+	if len(attrs) > 0 {
+		line.WriteString("  ")
+		line.Write(attrs)
+	}
+	line.WriteByte('\n')
+	_, err := h.state.out.Write(line.Bytes())
+	return err
+}
+
+func (h *prettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = h.attrs.WithAttrs(attrs)
+	return &clone
+}
+
+func (h *prettyHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.attrs = h.attrs.WithGroup(name)
+	return &clone
+}
+
+// levelName returns a level's name, padded to a common width, and color
+func levelName(level slog.Level) (string, string) {
+	switch {
+	case level < slog.LevelDebug:
+		return "TRACE", colorGray
+	case level < slog.LevelInfo:
+		return "DEBUG", colorCyan
+	case level < slog.LevelWarn:
+		return "INFO ", colorGreen
+	case level < slog.LevelError:
+		return "WARN ", colorYellow
+	}
+	return "ERROR", colorRed
+}
@@ -0,0 +1,342 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FormatAccessLog is the format name reported for web-server access logs
+const FormatAccessLog = "accesslog"
+
+// Access log format presets. Both extend the combined log format with the
+// request duration: nginx's $request_time in seconds and Apache's %D in
+// microseconds.
+const (
+	NginxLogFormat  = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent" $request_time`
+	ApacheLogFormat = `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-Agent}i" %D`
+)
+
+var logFormatPresets = map[string]string{
+	"nginx":  NginxLogFormat,
+	"apache": ApacheLogFormat,
+}
+
+// AccessLogOptions configures extraction of values from access logs
+type AccessLogOptions struct {
+	// Format is a preset name ("nginx", "apache") or a custom log format string
+	// using nginx $variables or Apache %directives
+	Format string
+
+	// Pattern is a regular expression with a named "value" capture group and
+	// optional "status" and "path" groups. It takes precedence over Format.
+	Pattern string
+
+	// Field names the format variable holding the value, e.g.
+	// "upstream_response_time" or "T". Defaults to request_time for nginx
+	// formats and D (falling back to T) for Apache formats.
+	Field string
+
+	// Statuses restricts lines to these status codes; classes such as "5xx"
+	// are allowed
+	Statuses []string
+
+	// PathPrefix restricts lines to requests whose path has this prefix
+	PathPrefix string
+}
+
+func (o AccessLogOptions) enabled() bool {
+	return o.Format != "" || o.Pattern != ""
+}
+
+// accessLogParser extracts values from lines matching a compiled log format
+type accessLogParser struct {
+	re           *regexp.Regexp
+	valueIndex   int
+	statusIndex  int
+	pathIndex    int
+	requestIndex int
+	statuses     []string
+	pathPrefix   string
+	valueUnit    string
+}
+
+// logFieldUnits records the units of well-known nginx variables and Apache
+// directives so their values can be converted with --unit
+var logFieldUnits = map[string]string{
+	"request_time":           "s",
+	"upstream_connect_time":  "s",
+	"upstream_header_time":   "s",
+	"upstream_response_time": "s",
+	"body_bytes_sent":        "B",
+	"bytes_sent":             "B",
+	"request_length":         "B",
+	"D":                      "us",
+	"T":                      "s",
+	"b":                      "B",
+	"B":                      "B",
+	"I":                      "B",
+	"O":                      "B",
+}
+
+// apacheDirective matches an Apache log directive such as %h, %>s or %{Referer}i
+var apacheDirective = regexp.MustCompile(`%[<>]?(?:\{([^}]*)\})?([a-zA-Z%])`)
+
+// nginxVariable matches an nginx log variable such as $request_time
+var nginxVariable = regexp.MustCompile(`\$([a-zA-Z0-9_]+)`)
+
+var groupNameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// newAccessLogParser compiles the configured pattern or log format
+func newAccessLogParser(opts AccessLogOptions) (*accessLogParser, error) {
+	var (
+		re  *regexp.Regexp
+		err error
+	)
+	valueGroups := []string{"value"}
+	statusGroups := []string{"status"}
+	pathGroups := []string{"path"}
+	requestGroups := []string{"request"}
+
+	switch {
+	case opts.Pattern != "":
+		re, err = regexp.Compile(opts.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log pattern: %w", err)
+		}
+	case strings.Contains(resolveLogFormat(opts.Format), "$"):
+		re, err = compileLogFormat(resolveLogFormat(opts.Format), nginxVariable, nginxGroupName)
+		valueGroups = []string{"request_time"}
+		pathGroups = []string{"uri", "request_uri"}
+	default:
+		re, err = compileLogFormat(resolveLogFormat(opts.Format), apacheDirective, apacheGroupName)
+		valueGroups = []string{"D", "T"}
+		statusGroups = []string{"s"}
+		pathGroups = []string{"U"}
+		requestGroups = []string{"r"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Field != "" {
+		valueGroups = []string{groupNameSanitizer.ReplaceAllString(opts.Field, "_")}
+	}
+
+	p := &accessLogParser{
+		re:           re,
+		valueIndex:   subexpIndex(re, valueGroups),
+		statusIndex:  subexpIndex(re, statusGroups),
+		pathIndex:    subexpIndex(re, pathGroups),
+		requestIndex: subexpIndex(re, requestGroups),
+		statuses:     opts.Statuses,
+		pathPrefix:   opts.PathPrefix,
+	}
+
+	if p.valueIndex < 0 {
+		return nil, fmt.Errorf("log format has no value field (looked for %s)", strings.Join(valueGroups, ", "))
+	}
+	if opts.Pattern == "" {
+		p.valueUnit = logFieldUnits[re.SubexpNames()[p.valueIndex]]
+	}
+	if len(p.statuses) > 0 && p.statusIndex < 0 {
+		return nil, fmt.Errorf("log format has no status field to filter on")
+	}
+	if p.pathPrefix != "" && p.pathIndex < 0 && p.requestIndex < 0 {
+		return nil, fmt.Errorf("log format has no request or path field to filter on")
+	}
+
+	return p, nil
+}
+
+// resolveLogFormat expands preset names into their format strings
+func resolveLogFormat(format string) string {
+	if preset, ok := logFormatPresets[strings.ToLower(format)]; ok {
+		return preset
+	}
+	return format
+}
+
+// compileLogFormat converts a log format string into an anchored regular
+// expression with one named group per variable. Each variable captures up to
+// the literal character that follows it in the format, or the next whitespace
+// if it ends the format.
+func compileLogFormat(format string, variable *regexp.Regexp, groupName func([]string) string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	seen := make(map[string]bool)
+	last := 0
+	for _, loc := range variable.FindAllStringSubmatchIndex(format, -1) {
+		b.WriteString(regexp.QuoteMeta(format[last:loc[0]]))
+		last = loc[1]
+
+		match := make([]string, 0, len(loc)/2)
+		for i := 0; i < len(loc); i += 2 {
+			if loc[i] < 0 {
+				match = append(match, "")
+				continue
+			}
+			match = append(match, format[loc[i]:loc[i+1]])
+		}
+
+		capture := `\S*`
+		switch {
+		case match[0] == "%t":
+			// Apache's %t includes its own brackets and a space
+			capture = `\[[^\]]*\]`
+		case last < len(format):
+			capture = `[^` + regexp.QuoteMeta(format[last:last+1]) + `]*`
+		}
+
+		name := groupName(match)
+		if name == "" || seen[name] {
+			b.WriteString("(?:" + capture + ")")
+			continue
+		}
+		seen[name] = true
+		b.WriteString("(?P<" + name + ">" + capture + ")")
+	}
+	b.WriteString(regexp.QuoteMeta(format[last:]))
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid log format: %w", err)
+	}
+	return re, nil
+}
+
+// nginxGroupName names the group for a $variable after the variable itself
+func nginxGroupName(match []string) string {
+	return match[1]
+}
+
+// apacheGroupName names the group for a %directive after its letter, with
+// headers and other parameterized directives such as %{Referer}i becoming
+// "i_Referer"
+func apacheGroupName(match []string) string {
+	if match[2] == "%" {
+		return ""
+	}
+	if match[1] != "" {
+		return match[2] + "_" + groupNameSanitizer.ReplaceAllString(match[1], "_")
+	}
+	return match[2]
+}
+
+// subexpIndex returns the index of the first named group present in re, or -1
+func subexpIndex(re *regexp.Regexp, names []string) int {
+	for _, name := range names {
+		if i := re.SubexpIndex(name); i >= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseLine extracts the value field from a log line. It reports false for
+// lines that do not match the format, are excluded by a filter, or hold no
+// value ("-" or nothing).
+func (p *accessLogParser) parseLine(line string) (string, bool) {
+	m := p.re.FindStringSubmatch(line)
+	if m == nil {
+		return "", false
+	}
+
+	if len(p.statuses) > 0 && !matchStatus(m[p.statusIndex], p.statuses) {
+		return "", false
+	}
+	if p.pathPrefix != "" && !strings.HasPrefix(p.path(m), p.pathPrefix) {
+		return "", false
+	}
+
+	field := m[p.valueIndex]
+	return field, field != "" && field != "-"
+}
+
+// parseValue parses a value field, converting it from the field's known unit
+func (p *accessLogParser) parseValue(field string, vp *ValueParser) (float64, error) {
+	if p.valueUnit == "" {
+		return vp.Parse(field)
+	}
+
+	value, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", field)
+	}
+	return vp.Convert(value, p.valueUnit)
+}
+
+// path returns the request path from a dedicated path group or, failing that,
+// the second field of the request line ("GET /path HTTP/1.1")
+func (p *accessLogParser) path(m []string) string {
+	if p.pathIndex >= 0 {
+		return m[p.pathIndex]
+	}
+	fields := strings.Fields(m[p.requestIndex])
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
+
+// matchStatus reports whether status matches any code or class ("5xx") in allowed
+func matchStatus(status string, allowed []string) bool {
+	for _, want := range allowed {
+		want = strings.ToLower(strings.TrimSpace(want))
+		if want == status {
+			return true
+		}
+		if len(want) == 3 && strings.HasSuffix(want, "xx") && len(status) == 3 && status[0] == want[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// readAccessLog reads values from every matching line of an access log,
+// reporting lines whose value cannot be parsed to rep
+func readAccessLog(r io.Reader, opts AccessLogOptions, vp *ValueParser, rep *report) ([]float64, error) {
+	p, err := newAccessLogParser(opts)
+	if err != nil {
+		return nil, err
+	}
+	column := p.re.SubexpNames()[p.valueIndex]
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTextLineLength)
+
+	var values []float64
+	lines, matched := 0, 0
+	for scanner.Scan() {
+		lines++
+		field, ok := p.parseLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		matched++
+		value, err := p.parseValue(field, vp)
+		if err != nil {
+			if err := rep.add(lines, column, field, err.Error()); err != nil {
+				return nil, fmt.Errorf("invalid access log record: %w", err)
+			}
+			continue
+		}
+		if err := rep.count(1); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read access log: %w", err)
+	}
+
+	if lines > 0 && matched == 0 {
+		return nil, fmt.Errorf("none of %d log lines matched the log format and filters", lines)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,254 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const nginxLog = `192.168.1.1 - - [10/Oct/2025:13:55:36 +0000] "GET /api/users?id=1 HTTP/1.1" 200 512 "-" "curl/8.0" 0.120
+192.168.1.2 - alice [10/Oct/2025:13:55:37 +0000] "POST /api/orders HTTP/1.1" 201 64 "https://example.com/" "Mozilla/5.0 (X11; Linux)" 0.450
+192.168.1.3 - - [10/Oct/2025:13:55:38 +0000] "GET /static/app.js HTTP/1.1" 304 0 "-" "Mozilla/5.0" 0.002
+192.168.1.4 - - [10/Oct/2025:13:55:39 +0000] "GET /api/users HTTP/1.1" 503 128 "-" "curl/8.0" 1.500
+not a log line
+`
+
+const apacheLog = `10.0.0.1 - - [10/Oct/2025:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326 "http://example.com/start" "Mozilla/4.08 [en] (Win98; I ;Nav)" 1200
+10.0.0.2 - frank [10/Oct/2025:13:55:40 -0700] "GET /api/items HTTP/1.1" 500 12 "-" "curl/8.0" 98000
+`
+
+func TestReadAccessLog_Presets(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		opts AccessLogOptions
+		want []float64
+	}{
+		{
+			name: "nginx",
+			data: nginxLog,
+			opts: AccessLogOptions{Format: "nginx"},
+			want: []float64{0.120, 0.450, 0.002, 1.500},
+		},
+		{
+			name: "nginx status filter",
+			data: nginxLog,
+			opts: AccessLogOptions{Format: "nginx", Statuses: []string{"2xx"}},
+			want: []float64{0.120, 0.450},
+		},
+		{
+			name: "nginx path prefix filter",
+			data: nginxLog,
+			opts: AccessLogOptions{Format: "NGINX", PathPrefix: "/api/"},
+			want: []float64{0.120, 0.450, 1.500},
+		},
+		{
+			name: "nginx combined filters",
+			data: nginxLog,
+			opts: AccessLogOptions{Format: "nginx", Statuses: []string{"503", "201"}, PathPrefix: "/api/users"},
+			want: []float64{1.500},
+		},
+		{
+			name: "nginx alternate field",
+			data: nginxLog,
+			opts: AccessLogOptions{Format: "nginx", Field: "body_bytes_sent"},
+			want: []float64{512, 64, 0, 128},
+		},
+		{
+			name: "apache",
+			data: apacheLog,
+			opts: AccessLogOptions{Format: "apache"},
+			want: []float64{1200, 98000},
+		},
+		{
+			name: "apache status filter",
+			data: apacheLog,
+			opts: AccessLogOptions{Format: "apache", Statuses: []string{"5xx"}},
+			want: []float64{98000},
+		},
+		{
+			name: "apache path prefix filter",
+			data: apacheLog,
+			opts: AccessLogOptions{Format: "apache", PathPrefix: "/index"},
+			want: []float64{1200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readAccessLog(strings.NewReader(tt.data), tt.opts, &ValueParser{}, strictReport())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReadAccessLog_CustomFormats(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		opts AccessLogOptions
+		want []float64
+	}{
+		{
+			name: "custom nginx format",
+			data: "GET /a 200 rt=0.5 urt=0.4\nGET /b 404 rt=0.1 urt=-\n",
+			opts: AccessLogOptions{Format: "$request_method $uri $status rt=$request_time urt=$upstream_response_time"},
+			want: []float64{0.5, 0.1},
+		},
+		{
+			name: "custom nginx format skips missing upstream time",
+			data: "GET /a 200 rt=0.5 urt=0.4\nGET /b 404 rt=0.1 urt=-\n",
+			opts: AccessLogOptions{Format: "$request_method $uri $status rt=$request_time urt=$upstream_response_time", Field: "upstream_response_time"},
+			want: []float64{0.4},
+		},
+		{
+			name: "custom nginx format with uri path filter",
+			data: "GET /a 200 rt=0.5\nGET /b 404 rt=0.1\n",
+			opts: AccessLogOptions{Format: "$request_method $uri $status rt=$request_time", PathPrefix: "/b"},
+			want: []float64{0.1},
+		},
+		{
+			name: "custom apache format with %T",
+			data: "/x 200 3\n/y 500 7\n",
+			opts: AccessLogOptions{Format: "%U %>s %T", PathPrefix: "/y"},
+			want: []float64{7},
+		},
+		{
+			name: "regex mode",
+			data: "ts=1 path=/a status=200 took=12ms\nts=2 path=/b status=500 took=40ms\n",
+			opts: AccessLogOptions{Pattern: `path=(?P<path>\S+) status=(?P<status>\d+) took=(?P<value>[\d.]+)ms`},
+			want: []float64{12, 40},
+		},
+		{
+			name: "regex mode with filters",
+			data: "ts=1 path=/a status=200 took=12ms\nts=2 path=/b status=500 took=40ms\n",
+			opts: AccessLogOptions{Pattern: `path=(?P<path>\S+) status=(?P<status>\d+) took=(?P<value>[\d.]+)ms`, Statuses: []string{"200"}},
+			want: []float64{12},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readAccessLog(strings.NewReader(tt.data), tt.opts, &ValueParser{}, strictReport())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReadAccessLog_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		opts AccessLogOptions
+	}{
+		{name: "invalid regex", data: "x", opts: AccessLogOptions{Pattern: `(?P<value>[`}},
+		{name: "regex without value group", data: "x", opts: AccessLogOptions{Pattern: `(\d+)`}},
+		{name: "format without value field", data: "x", opts: AccessLogOptions{Format: "$remote_addr $status"}},
+		{name: "status filter without status field", data: "x", opts: AccessLogOptions{Pattern: `(?P<value>\d+)`, Statuses: []string{"200"}}},
+		{name: "path filter without path field", data: "x", opts: AccessLogOptions{Pattern: `(?P<value>\d+)`, PathPrefix: "/"}},
+		{name: "no matching lines", data: "garbage\nmore garbage\n", opts: AccessLogOptions{Format: "nginx"}},
+		{name: "invalid value", data: "took=12\ntook=12x\n", opts: AccessLogOptions{Pattern: `took=(?P<value>\S+)`}},
+		{name: "invalid value of known unit", data: "GET /a 200 rt=0.5\nGET /b 200 rt=fast\n", opts: AccessLogOptions{Format: "$request_method $uri $status rt=$request_time"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readAccessLog(strings.NewReader(tt.data), tt.opts, &ValueParser{}, strictReport()); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestMatchStatus(t *testing.T) {
+	tests := []struct {
+		status  string
+		allowed []string
+		want    bool
+	}{
+		{"200", []string{"200"}, true},
+		{"204", []string{"2xx"}, true},
+		{"204", []string{"2XX"}, true},
+		{"404", []string{"2xx", "5xx"}, false},
+		{"503", []string{"2xx", "5xx"}, true},
+		{"-", []string{"2xx"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := matchStatus(tt.status, tt.allowed); got != tt.want {
+			t.Errorf("matchStatus(%q, %v): expected %v, got %v", tt.status, tt.allowed, tt.want, got)
+		}
+	}
+}
+
+func TestDecode_AccessLog(t *testing.T) {
+	res, err := Decode(bytes.NewReader(gzipBytes(t, []byte(nginxLog))), "access.log.gz", Options{
+		AccessLog: AccessLogOptions{Format: "nginx"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Format != FormatAccessLog {
+		t.Errorf("expected format %q, got %q", FormatAccessLog, res.Format)
+	}
+	if len(res.Values) != 4 {
+		t.Errorf("expected 4 values, got %d", len(res.Values))
+	}
+}
+
+func TestDecode_AccessLogInvalidValues(t *testing.T) {
+	data := "took=12ms\ntook=soon\ntook=-\ntook=40ms\n"
+	opts := Options{AccessLog: AccessLogOptions{Pattern: `took=(?P<value>\S+)`}}
+
+	_, err := Decode(strings.NewReader(data), "app.log", opts)
+	var recErr *RecordError
+	if !errors.As(err, &recErr) || recErr.Line != 2 || recErr.Column != "value" || recErr.Value != "soon" {
+		t.Fatalf("expected a record error for line 2, got %v", err)
+	}
+
+	opts.Mode = ModeLenient
+	res, err := Decode(strings.NewReader(data), "app.log", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(res.Values, []float64{12, 40}) {
+		t.Errorf("expected [12 40], got %v", res.Values)
+	}
+	if res.Skipped != 1 || len(res.Errors) != 1 || res.Errors[0].Line != 2 {
+		t.Errorf("expected line 2 to be skipped and reported, got %d skipped, errors %+v", res.Skipped, res.Errors)
+	}
+}
+
+func TestReadAccessLogFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "access.log")
+	if err := os.WriteFile(path, []byte(apacheLog), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := ReadAccessLogFile(path, AccessLogOptions{Format: "apache"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(values, []float64{1200, 98000}) {
+		t.Errorf("expected [1200 98000], got %v", values)
+	}
+
+	if _, err := ReadAccessLogFile(filepath.Join(tmpDir, "missing.log"), AccessLogOptions{Format: "apache"}); err == nil {
+		t.Error("expected error for missing file, got nil")
+	}
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMaxDecompressedSize is the default cap on decompressed input (1 GiB)
+const DefaultMaxDecompressedSize int64 = 1 << 30
+
+// ErrDecompressedSizeExceeded is returned when a compressed input expands
+// beyond the configured maximum size
+var ErrDecompressedSizeExceeded = errors.New("decompressed input exceeds maximum size")
+
+// Compression identifies the codec wrapping an input stream
+type Compression string
+
+// Supported compression codecs
+const (
+	CompressionNone  Compression = ""
+	CompressionGzip  Compression = "gzip"
+	CompressionZstd  Compression = "zstd"
+	CompressionBzip2 Compression = "bzip2"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+)
+
+var compressionExtensions = map[string]Compression{
+	".gz":    CompressionGzip,
+	".gzip":  CompressionGzip,
+	".zst":   CompressionZstd,
+	".zstd":  CompressionZstd,
+	".bz2":   CompressionBzip2,
+	".bzip2": CompressionBzip2,
+}
+
+// DetectCompression identifies the compression codec from the leading bytes of
+// the input, falling back to the filename extension
+func DetectCompression(header []byte, filename string) Compression {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(header, zstdMagic):
+		return CompressionZstd
+	case bytes.HasPrefix(header, bzip2Magic):
+		return CompressionBzip2
+	}
+	return compressionExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// formatExt returns the lowercase extension naming the data format, ignoring a
+// trailing compression extension (e.g. "data.csv.gz" yields ".csv")
+func formatExt(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if _, ok := compressionExtensions[ext]; ok {
+		return strings.ToLower(filepath.Ext(strings.TrimSuffix(filename, filepath.Ext(filename))))
+	}
+	return ext
+}
+
+// decompress wraps r in a streaming decoder for any detected compression and
+// reports the codec used. The returned reader enforces the configured
+// decompressed size limit.
+func decompress(r io.Reader, filename string, opts Options) (io.ReadCloser, Compression, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(len(zstdMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, CompressionNone, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	var decoded io.ReadCloser
+	codec := DetectCompression(header, filename)
+	switch codec {
+	case CompressionGzip:
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, codec, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		decoded = zr
+	case CompressionZstd:
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, codec, fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+		decoded = zr.IOReadCloser()
+	case CompressionBzip2:
+		decoded = io.NopCloser(bzip2.NewReader(br))
+	default:
+		return io.NopCloser(br), codec, nil
+	}
+
+	limit := opts.maxDecompressedSize()
+	if limit < 0 {
+		return decoded, codec, nil
+	}
+	return &limitedReadCloser{rc: decoded, remaining: limit, limit: limit}, codec, nil
+}
+
+// limitedReadCloser fails with ErrDecompressedSizeExceeded instead of silently
+// truncating once more than limit bytes have been read
+type limitedReadCloser struct {
+	rc        io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (l *limitedReadCloser) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		var probe [1]byte
+		n, err := l.rc.Read(probe[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w (%d bytes)", ErrDecompressedSizeExceeded, l.limit)
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.rc.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+func (l *limitedReadCloser) Close() error {
+	return l.rc.Close()
+}
@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// bzip2CSV is "value\n1\n2\n3\n" compressed with bzip2 (no encoder in the stdlib)
+var bzip2CSV = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xcf, 0x8c, 0xa5,
+	0xc3, 0x00, 0x00, 0x05, 0xc9, 0x80, 0x00, 0x10, 0x38, 0x00, 0x22, 0x04, 0x03,
+	0x00, 0x20, 0x00, 0x22, 0x03, 0x23, 0xd4, 0x20, 0xc9, 0x88, 0x77, 0x89, 0x48,
+	0x70, 0xbc, 0x5d, 0xc9, 0x14, 0xe1, 0x42, 0x43, 0x3e, 0x32, 0x97, 0x0c,
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("failed to gzip data: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func zstdBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("failed to create zstd encoder: %v", err)
+	}
+	defer enc.Close()
+	return enc.EncodeAll(data, nil)
+}
+
+func TestDetectCompression(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   []byte
+		filename string
+		want     Compression
+	}{
+		{name: "gzip magic", header: []byte{0x1f, 0x8b, 0x08, 0x00}, filename: "data.csv", want: CompressionGzip},
+		{name: "zstd magic", header: []byte{0x28, 0xb5, 0x2f, 0xfd}, filename: "data", want: CompressionZstd},
+		{name: "bzip2 magic", header: []byte("BZh9"), filename: "data.json", want: CompressionBzip2},
+		{name: "extension fallback", header: []byte("valu"), filename: "data.csv.GZ", want: CompressionGzip},
+		{name: "plain", header: []byte("[1, "), filename: "data.json", want: CompressionNone},
+		{name: "empty input", header: nil, filename: "data.csv", want: CompressionNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectCompression(tt.header, tt.filename); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatExt(t *testing.T) {
+	tests := map[string]string{
+		"data.csv":      ".csv",
+		"data.csv.gz":   ".csv",
+		"DATA.JSON.ZST": ".json",
+		"data.json.bz2": ".json",
+		"archive.gz":    "",
+		"data.xml":      ".xml",
+	}
+
+	for filename, want := range tests {
+		if got := formatExt(filename); got != want {
+			t.Errorf("formatExt(%q): expected %q, got %q", filename, want, got)
+		}
+	}
+}
+
+func TestReadValuesFromReader_Compressed(t *testing.T) {
+	csvData := []byte("value\n1\n2\n3\n")
+	jsonData := []byte(`[1, 2, 3]`)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		filename string
+	}{
+		{name: "gzip CSV", data: gzipBytes(t, csvData), filename: "latency.csv.gz"},
+		{name: "gzip JSON without compression extension", data: gzipBytes(t, jsonData), filename: "latency.json"},
+		{name: "zstd JSON", data: zstdBytes(t, jsonData), filename: "latency.json.zst"},
+		{name: "zstd CSV", data: zstdBytes(t, csvData), filename: "latency.csv.zstd"},
+		{name: "bzip2 CSV", data: bzip2CSV, filename: "latency.csv.bz2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadValuesFromReader(bytes.NewReader(tt.data), tt.filename, Options{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, []float64{1, 2, 3}) {
+				t.Errorf("expected [1 2 3], got %v", got)
+			}
+		})
+	}
+}
+
+func TestReadValuesFromReader_DecompressionLimit(t *testing.T) {
+	data := gzipBytes(t, append([]byte("value\n"), bytes.Repeat([]byte("1\n"), 10000)...))
+
+	_, err := ReadValuesFromReader(bytes.NewReader(data), "bomb.csv.gz", Options{MaxDecompressedSize: 1024})
+	if !errors.Is(err, ErrDecompressedSizeExceeded) {
+		t.Fatalf("expected ErrDecompressedSizeExceeded, got %v", err)
+	}
+
+	values, err := ReadValuesFromReader(bytes.NewReader(data), "bomb.csv.gz", Options{MaxDecompressedSize: -1})
+	if err != nil {
+		t.Fatalf("unexpected error with limit disabled: %v", err)
+	}
+	if len(values) != 10000 {
+		t.Errorf("expected 10000 values, got %d", len(values))
+	}
+}
+
+func TestReadValuesFromReader_ExactLimit(t *testing.T) {
+	csvData := []byte("value\n1\n2\n3\n")
+	data := gzipBytes(t, csvData)
+
+	values, err := ReadValuesFromReader(bytes.NewReader(data), "data.csv.gz", Options{MaxDecompressedSize: int64(len(csvData))})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(values, []float64{1, 2, 3}) {
+		t.Errorf("expected [1 2 3], got %v", values)
+	}
+}
+
+func TestReadValuesFromReader_CorruptGzip(t *testing.T) {
+	_, err := ReadValuesFromReader(bytes.NewReader([]byte("not gzip")), "data.csv.gz", Options{})
+	if err == nil {
+		t.Error("expected error for corrupt gzip stream, got nil")
+	}
+}
+
+func TestReadValuesFromFile_Compressed(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "latency.csv.gz")
+	if err := os.WriteFile(path, gzipBytes(t, []byte("value\n5\n6\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := ReadValuesFromFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(values, []float64{5, 6}) {
+		t.Errorf("expected [5 6], got %v", values)
+	}
+}
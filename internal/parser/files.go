@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// StdinPath names standard input in a list of input paths
+const StdinPath = "-"
+
+// FormatMixed is the format reported for merged results of different formats
+const FormatMixed = "mixed"
+
+// Source is a named input that is opened when it is decoded
+type Source struct {
+	// Name identifies the source in results and errors, and is used for
+	// format and compression detection
+	Name string
+	// ContentType is an optional MIME type hint overriding Options.ContentType
+	ContentType string
+	// Open returns the source's content
+	Open func() (io.ReadCloser, error)
+}
+
+// FileSource returns a Source reading the file at path, or standard input when
+// path is StdinPath
+func FileSource(path string) Source {
+	if path == StdinPath {
+		return Source{
+			Name: path,
+			Open: func() (io.ReadCloser, error) { return io.NopCloser(os.Stdin), nil },
+		}
+	}
+	return Source{
+		Name: path,
+		Open: func() (io.ReadCloser, error) {
+			file, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open file: %w", err)
+			}
+			return file, nil
+		},
+	}
+}
+
+// ExpandPaths resolves file arguments into a list of files. Glob patterns are
+// expanded and directories are walked recursively for files with a supported
+// extension (any file when access log parsing is enabled), skipping hidden
+// entries. StdinPath is passed through. Duplicates are removed.
+func ExpandPaths(patterns []string, opts Options) ([]string, error) {
+	var paths []string
+	add := func(path string) {
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+
+	for _, pattern := range patterns {
+		if pattern == StdinPath {
+			add(pattern)
+			continue
+		}
+
+		matches := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			var err error
+			matches, err = filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", pattern)
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open file: %w", err)
+			}
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+
+			files, err := walkDir(match, opts)
+			if err != nil {
+				return nil, err
+			}
+			if len(files) == 0 {
+				return nil, fmt.Errorf("no supported files in directory %s", match)
+			}
+			for _, file := range files {
+				add(file)
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// walkDir lists the supported files below dir in lexical order
+func walkDir(dir string, opts Options) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && (opts.AccessLog.enabled() || isSupportedFile(path)) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// isSupportedFile reports whether a filename, ignoring any compression
+// extension, names a registered input format
+func isSupportedFile(name string) bool {
+	ext := formatExt(name)
+	for _, f := range Formats() {
+		if slices.Contains(f.Extensions(), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeFiles decodes the files at paths concurrently, see DecodeSources
+func DecodeFiles(paths []string, opts Options) ([]*Result, error) {
+	sources := make([]Source, len(paths))
+	for i, path := range paths {
+		sources[i] = FileSource(path)
+	}
+	return DecodeSources(sources, opts)
+}
+
+// DecodeSources decodes sources concurrently and returns their results in
+// order, each with Source set. The first failure, prefixed with the source
+// name, is returned.
+func DecodeSources(sources []Source, opts Options) ([]*Result, error) {
+	results := make([]*Result, len(sources))
+	if opts.MaxValues > 0 {
+		opts.decoded = new(atomic.Int64)
+	}
+
+	var g errgroup.Group
+	g.SetLimit(runtime.GOMAXPROCS(0))
+	for i, src := range sources {
+		g.Go(func() error {
+			res, err := decodeSource(src, opts)
+			if err != nil {
+				return fmt.Errorf("%s: %w", src.Name, err)
+			}
+			results[i] = res
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func decodeSource(src Source, opts Options) (*Result, error) {
+	rc, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	if src.ContentType != "" {
+		opts.ContentType = src.ContentType
+	}
+	name := src.Name
+	if name == StdinPath {
+		name = ""
+	}
+
+	res, err := Decode(rc, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	res.Source = src.Name
+	return res, nil
+}
+
+// Merge pools the values of several results. The merged result reports the
+// common format (or FormatMixed) and unit, the total skipped and non-finite
+// counts, the first record errors tagged with their source, capped by
+// maxErrors as by Options.MaxErrors, and groups of the same name pooled
+// across results. Results converted to different units cannot be merged.
+func Merge(results []*Result, maxErrors int) (*Result, error) {
+	if maxErrors == 0 {
+		maxErrors = DefaultMaxErrors
+	}
+	merged := &Result{}
+	total := 0
+	for _, res := range results {
+		total += len(res.Values)
+	}
+	merged.Values = make([]float64, 0, total)
+
+	for i, res := range results {
+		merged.Values = append(merged.Values, res.Values...)
+		merged.Skipped += res.Skipped
+		merged.NonFinite.Dropped += res.NonFinite.Dropped
+		merged.NonFinite.Clamped += res.NonFinite.Clamped
+		merged.Groups = mergeGroups(merged.Groups, res.Groups)
+		for _, e := range res.Errors {
+			if maxErrors >= 0 && len(merged.Errors) >= maxErrors {
+				break
+			}
+			if e.Source == "" {
+				e.Source = res.Source
+			}
+			merged.Errors = append(merged.Errors, e)
+		}
+
+		switch {
+		case i == 0:
+			merged.Format = res.Format
+		case merged.Format != res.Format:
+			merged.Format = FormatMixed
+		}
+
+		if res.Unit == "" {
+			continue
+		}
+		if merged.Unit != "" && merged.Unit != res.Unit {
+			return nil, fmt.Errorf("inputs use different units (%s, %s); set a target unit to convert them", merged.Unit, res.Unit)
+		}
+		merged.Unit = res.Unit
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files relative to dir
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExpandPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"hosts/a.csv":          "value\n1\n",
+		"hosts/b.csv.gz":       "",
+		"hosts/nested/c.jsonl": "3\n",
+		"hosts/notes.md":       "# notes",
+		"hosts/.hidden.csv":    "value\n9\n",
+		"hosts/.git/x.csv":     "value\n9\n",
+		"other/d.json":         "[4]",
+		"other/e.txt":          "5",
+		"logs/access.log":      "",
+	})
+	join := func(names ...string) []string {
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = filepath.Join(dir, name)
+		}
+		return paths
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		opts     Options
+		want     []string
+	}{
+		{
+			name:     "directory",
+			patterns: join("hosts"),
+			want:     join("hosts/a.csv", "hosts/b.csv.gz", "hosts/nested/c.jsonl"),
+		},
+		{
+			name:     "glob",
+			patterns: join("other/*"),
+			want:     join("other/d.json", "other/e.txt"),
+		},
+		{
+			name:     "repeats and duplicates",
+			patterns: append(join("other/e.txt", "hosts/a.csv", "hosts"), "-"),
+			want:     append(join("other/e.txt", "hosts/a.csv", "hosts/b.csv.gz", "hosts/nested/c.jsonl"), "-"),
+		},
+		{
+			name:     "explicit file with any extension",
+			patterns: join("hosts/notes.md"),
+			want:     join("hosts/notes.md"),
+		},
+		{
+			name:     "access logs include every file",
+			patterns: join("logs"),
+			opts:     Options{AccessLog: AccessLogOptions{Format: "nginx"}},
+			want:     join("logs/access.log"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandPaths(tt.patterns, tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExpandPaths_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"empty/notes.md": ""})
+
+	tests := []struct {
+		name    string
+		pattern string
+	}{
+		{name: "missing file", pattern: filepath.Join(dir, "missing.csv")},
+		{name: "glob without matches", pattern: filepath.Join(dir, "*.parquet")},
+		{name: "invalid glob", pattern: filepath.Join(dir, "[")},
+		{name: "directory without supported files", pattern: filepath.Join(dir, "empty")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ExpandPaths([]string{tt.pattern}, Options{}); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestDecodeFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.csv":  "value\n1\n2\n",
+		"b.json": "[3, 4, 5]",
+		"c.txt":  "6\n",
+	})
+	paths := []string{filepath.Join(dir, "a.csv"), filepath.Join(dir, "b.json"), filepath.Join(dir, "c.txt")}
+
+	results, err := DecodeFiles(paths, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	for i, res := range results {
+		if res.Source != paths[i] {
+			t.Errorf("expected source %q, got %q", paths[i], res.Source)
+		}
+	}
+	if !slices.Equal(results[1].Values, []float64{3, 4, 5}) || results[1].Format != FormatJSON {
+		t.Errorf("expected JSON [3 4 5], got %s %v", results[1].Format, results[1].Values)
+	}
+
+	merged, err := Merge(results, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(merged.Values, []float64{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("expected [1 2 3 4 5 6], got %v", merged.Values)
+	}
+	if merged.Format != FormatMixed {
+		t.Errorf("expected format %q, got %q", FormatMixed, merged.Format)
+	}
+}
+
+func TestDecodeFiles_Error(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"good.csv": "value\n1\n",
+		"bad.csv":  "value\nx\n",
+	})
+
+	_, err := DecodeFiles([]string{filepath.Join(dir, "good.csv"), filepath.Join(dir, "bad.csv")}, Options{})
+	if err == nil || !strings.Contains(err.Error(), "bad.csv") {
+		t.Fatalf("expected error naming bad.csv, got %v", err)
+	}
+	var recErr *RecordError
+	if !errors.As(err, &recErr) || recErr.Line != 2 {
+		t.Errorf("expected wrapped RecordError on line 2, got %v", err)
+	}
+}
+
+func TestDecodeFiles_MaxValues(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.txt": "1\n2\n",
+		"b.txt": "3\n4\n",
+	})
+	paths := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")}
+
+	// The cap applies to the files together
+	if _, err := DecodeFiles(paths, Options{MaxValues: 3}); !errors.Is(err, ErrTooManyValues) {
+		t.Errorf("expected ErrTooManyValues, got %v", err)
+	}
+	if _, err := DecodeFiles(paths, Options{MaxValues: 4}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	results := []*Result{
+		{Values: []float64{1}, Format: FormatCSV, Source: "a.csv", Skipped: 1, Errors: []RecordError{{Line: 2, Reason: "bad"}}},
+		{Values: []float64{2}, Format: FormatCSV, Source: "b.csv", Unit: "ms", Groups: []Group{{Name: "x", Values: []float64{2}}}},
+		{Values: []float64{3}, Format: FormatCSV, Source: "c.csv", Unit: "ms", Skipped: 2, Groups: []Group{{Name: "x", Values: []float64{3}}}},
+	}
+
+	merged, err := Merge(results, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if merged.Format != FormatCSV || merged.Unit != "ms" || merged.Skipped != 3 {
+		t.Errorf("unexpected merged result %+v", merged)
+	}
+	if len(merged.Errors) != 1 || merged.Errors[0].Error() != "a.csv: line 2: bad" {
+		t.Errorf("expected source-tagged error, got %+v", merged.Errors)
+	}
+	if len(merged.Groups) != 1 || !slices.Equal(merged.Groups[0].Values, []float64{2, 3}) {
+		t.Errorf("expected group x with [2 3], got %+v", merged.Groups)
+	}
+	if results[0].Errors[0].Source != "" {
+		t.Error("Merge modified its input")
+	}
+
+	// Errors are capped across results, keeping the total skipped count
+	for _, res := range results {
+		res.Errors = []RecordError{{Line: 1, Reason: "bad"}, {Line: 2, Reason: "bad"}}
+	}
+	if merged, err = Merge(results, 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(merged.Errors) != 3 || merged.Errors[2].Source != "b.csv" || merged.Skipped != 3 {
+		t.Errorf("expected 3 of the errors and 3 skipped, got %+v", merged)
+	}
+	if merged, _ = Merge(results, -1); len(merged.Errors) != 6 {
+		t.Errorf("expected every error with a negative cap, got %d", len(merged.Errors))
+	}
+
+	results[2].Unit = "s"
+	if _, err := Merge(results, 0); err == nil {
+		t.Error("expected error merging different units, got nil")
+	}
+}
@@ -0,0 +1,266 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Format decodes one kind of input. Formats are selected by filename
+// extension, by MIME type, or by sniffing the leading bytes of the content.
+// Implementations must be safe for concurrent use.
+type Format interface {
+	// Name identifies the format in results, e.g. "csv"
+	Name() string
+	// Extensions lists the lowercase filename extensions, including the dot,
+	// that select the format
+	Extensions() []string
+	// MIMETypes lists the media types that select the format
+	MIMETypes() []string
+	// Sniff reports whether the leading bytes of a decompressed input, at most
+	// 4 KiB, look like this format
+	Sniff(prefix []byte, opts Options) bool
+	// Decode reads values from the decompressed input
+	Decode(r io.Reader, dc *DecodeContext) ([]float64, error)
+}
+
+// FallbackFormat is implemented by formats whose extensions and MIME types are
+// too generic to select them outright, such as ".txt". Such inputs are still
+// sniffed, and the format is used only when no format recognizes the content.
+type FallbackFormat interface {
+	Format
+	Fallback() bool
+}
+
+// DecodeContext carries the per-input state a Format needs while decoding
+type DecodeContext struct {
+	// Options are the options the input is decoded with
+	Options Options
+	// Values parses numbers with unit suffixes and localized separators
+	// according to Options.Unit and Options.Locale
+	Values *ValueParser
+
+	rep    *report
+	raw    io.Reader
+	codec  Compression
+	labels []string
+	names  []string
+}
+
+// SetGroups labels the values returned by Decode with the names of the series
+// they belong to, such as benchmark names; labels[i] names the group of the
+// i-th value. Grouped values are reported in Result.Groups.
+func (dc *DecodeContext) SetGroups(labels []string) {
+	dc.labels = labels
+}
+
+// SetNames identifies the grouped values returned by Decode, such as test
+// names, so that outliers can be reported by name; names[i] names the i-th
+// value. It has no effect without SetGroups.
+func (dc *DecodeContext) SetNames(names []string) {
+	dc.names = names
+}
+
+// Skip records an invalid record. In strict mode it returns an error that the
+// decoder should return; in lenient mode the record is reported in
+// Result.Errors and nil is returned.
+func (dc *DecodeContext) Skip(line int, column, value, reason string) error {
+	return dc.rep.add(line, column, value, reason)
+}
+
+// ReaderAt returns random access to the input decoded from r, for formats such
+// as Parquet that keep metadata at the end. Uncompressed seekable inputs are
+// read in place; other streams are buffered in memory up to
+// Options.MaxDecompressedSize, failing with ErrDecompressedSizeExceeded
+// beyond it.
+func (dc *DecodeContext) ReaderAt(r io.Reader) (io.ReaderAt, int64, error) {
+	return parquetSource(dc.raw, r, dc.codec, dc.Options.maxDecompressedSize())
+}
+
+// registry holds the registered formats. Built-in formats come first; formats
+// registered by users are consulted before them.
+var registry struct {
+	sync.RWMutex
+	formats  []Format
+	builtins int
+}
+
+func init() {
+	for _, f := range []Format{parquetFormat{}, jsonFormat{}, goBenchFormat{}, junitFormat{}, harFormat{}, otlpFormat{}, ndjsonFormat{}, csvFormat{}, textFormat{}, accessLogFormat{}} {
+		Register(f)
+	}
+	registry.builtins = len(registry.formats)
+}
+
+// Register makes a format available to Decode and everything built on it.
+// Registered formats take precedence over the built-in formats for extensions,
+// MIME types and content sniffing. Register panics if f is nil or a format
+// with the same name is already registered.
+func Register(f Format) {
+	if f == nil {
+		panic("parser: Register format is nil")
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	for _, existing := range registry.formats {
+		if existing.Name() == f.Name() {
+			panic(fmt.Sprintf("parser: Register called twice for format %q", f.Name()))
+		}
+	}
+	registry.formats = append(registry.formats, f)
+}
+
+// Formats returns the registered formats, built-in formats first
+func Formats() []Format {
+	registry.RLock()
+	defer registry.RUnlock()
+	return slices.Clone(registry.formats)
+}
+
+// LookupFormat returns the registered format with the given name
+func LookupFormat(name string) (Format, bool) {
+	for _, f := range Formats() {
+		if f.Name() == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// formatsByPrecedence returns the registered formats with user formats ahead
+// of the built-in ones
+func formatsByPrecedence() []Format {
+	registry.RLock()
+	defer registry.RUnlock()
+	formats := slices.Clone(registry.formats[registry.builtins:])
+	return append(formats, registry.formats[:registry.builtins]...)
+}
+
+func isFallback(f Format) bool {
+	fb, ok := f.(FallbackFormat)
+	return ok && fb.Fallback()
+}
+
+// formatNames lists the names of the registered formats for error messages
+func formatNames() string {
+	var names []string
+	for _, f := range Formats() {
+		names = append(names, f.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// Built-in formats
+
+type jsonFormat struct{}
+
+func (jsonFormat) Name() string         { return FormatJSON }
+func (jsonFormat) Extensions() []string { return []string{".json"} }
+func (jsonFormat) MIMETypes() []string  { return []string{"application/json", "text/json"} }
+
+func (jsonFormat) Sniff(prefix []byte, _ Options) bool {
+	trimmed := sniffTrim(prefix)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+func (jsonFormat) Decode(r io.Reader, dc *DecodeContext) ([]float64, error) {
+	return readJSONFromReader(r, dc.Values, dc.rep)
+}
+
+type ndjsonFormat struct{}
+
+func (ndjsonFormat) Name() string         { return FormatNDJSON }
+func (ndjsonFormat) Extensions() []string { return []string{".ndjson", ".jsonl"} }
+func (ndjsonFormat) MIMETypes() []string {
+	return []string{"application/x-ndjson", "application/ndjson", "application/jsonl", "application/jsonlines"}
+}
+
+func (ndjsonFormat) Sniff(prefix []byte, _ Options) bool {
+	trimmed := sniffTrim(prefix)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+func (ndjsonFormat) Decode(r io.Reader, dc *DecodeContext) ([]float64, error) {
+	return readNDJSONFromReader(r, dc.Values, dc.rep)
+}
+
+type csvFormat struct{}
+
+func (csvFormat) Name() string         { return FormatCSV }
+func (csvFormat) Extensions() []string { return []string{".csv"} }
+func (csvFormat) MIMETypes() []string  { return []string{"text/csv", "application/csv"} }
+
+// Sniff detects a header row: a delimited first line, or a lone "value"
+// column. Lines of comma-decimal numbers such as "1,5" are text rather than CSV.
+func (csvFormat) Sniff(prefix []byte, opts Options) bool {
+	first := sniffFirstLine(prefix)
+	if first == "" || strings.HasPrefix(first, textComment) {
+		return false
+	}
+	if strings.ContainsAny(first, ",;") && !(opts.Locale.decimal() == "," && isTextLine(first, opts.Locale)) {
+		return true
+	}
+	return strings.EqualFold(strings.Trim(first, `"`), "value")
+}
+
+func (csvFormat) Decode(r io.Reader, dc *DecodeContext) ([]float64, error) {
+	return readCSVColumn(newCSVReader(r), dc.Options.column(), dc.Values, dc.rep)
+}
+
+type textFormat struct{}
+
+func (textFormat) Name() string         { return FormatText }
+func (textFormat) Extensions() []string { return []string{".txt", ".text"} }
+func (textFormat) MIMETypes() []string  { return []string{"text/plain"} }
+func (textFormat) Fallback() bool       { return true }
+
+// Sniff requires the first line holding anything besides comments to be numbers
+func (textFormat) Sniff(prefix []byte, opts Options) bool {
+	for _, line := range strings.Split(string(sniffTrim(prefix)), "\n") {
+		if len(textFields(line)) == 0 {
+			continue
+		}
+		return isTextLine(line, opts.Locale)
+	}
+	return false
+}
+
+func (textFormat) Decode(r io.Reader, dc *DecodeContext) ([]float64, error) {
+	return readTextFromReader(r, dc.Values, dc.rep)
+}
+
+type parquetFormat struct{}
+
+func (parquetFormat) Name() string         { return FormatParquet }
+func (parquetFormat) Extensions() []string { return []string{".parquet", ".parq"} }
+func (parquetFormat) MIMETypes() []string {
+	return []string{"application/vnd.apache.parquet", "application/x-parquet"}
+}
+
+func (parquetFormat) Sniff(prefix []byte, _ Options) bool {
+	return strings.HasPrefix(string(prefix), string(parquetMagic))
+}
+
+func (parquetFormat) Decode(r io.Reader, dc *DecodeContext) ([]float64, error) {
+	src, size, err := dc.ReaderAt(r)
+	if err != nil {
+		return nil, err
+	}
+	return readParquet(src, size, dc.Options.column(), dc.rep)
+}
+
+// accessLogFormat is never detected; Decode selects it when
+// Options.AccessLog is set
+type accessLogFormat struct{}
+
+func (accessLogFormat) Name() string                   { return FormatAccessLog }
+func (accessLogFormat) Extensions() []string           { return nil }
+func (accessLogFormat) MIMETypes() []string            { return nil }
+func (accessLogFormat) Sniff(_ []byte, _ Options) bool { return false }
+
+func (accessLogFormat) Decode(r io.Reader, dc *DecodeContext) ([]float64, error) {
+	return readAccessLog(r, dc.Options.AccessLog, dc.Values, dc.rep)
+}
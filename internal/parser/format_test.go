@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// kvFormat is a custom format of "name=value" lines, used to exercise the
+// registry the way an embedding application would
+type kvFormat struct{}
+
+func (kvFormat) Name() string         { return "kv" }
+func (kvFormat) Extensions() []string { return []string{".kv"} }
+func (kvFormat) MIMETypes() []string  { return []string{"application/x-kv"} }
+
+func (kvFormat) Sniff(prefix []byte, _ Options) bool {
+	return bytes.HasPrefix(sniffTrim(prefix), []byte("kv:"))
+}
+
+func (kvFormat) Decode(r io.Reader, dc *DecodeContext) ([]float64, error) {
+	scanner := bufio.NewScanner(r)
+	var values []float64
+	for line := 1; scanner.Scan(); line++ {
+		name, raw, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value, err := dc.Values.Parse(raw)
+		if err != nil {
+			if err := dc.Skip(line, name, raw, err.Error()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		values = append(values, value)
+	}
+	return values, scanner.Err()
+}
+
+var registerKV sync.Once
+
+func TestRegister_CustomFormat(t *testing.T) {
+	registerKV.Do(func() { Register(kvFormat{}) })
+
+	tests := []struct {
+		name        string
+		data        string
+		filename    string
+		contentType string
+	}{
+		{name: "extension", data: "a=1\nb=2ms\n", filename: "data.kv"},
+		{name: "compressed extension", data: "a=1\nb=2ms\n", filename: "data.kv.gz"},
+		{name: "MIME type", data: "a=1\nb=2ms\n", filename: "blob", contentType: "application/x-kv"},
+		{name: "sniffed", data: "kv:\na=1\nb=2ms\n", filename: "blob"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(tt.data)
+			if strings.HasSuffix(tt.filename, ".gz") {
+				data = gzipBytes(t, data)
+			}
+			res, err := Decode(bytes.NewReader(data), tt.filename, Options{ContentType: tt.contentType, Unit: "ms"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Format != "kv" || res.Unit != "ms" {
+				t.Errorf("expected kv in ms, got %s in %s", res.Format, res.Unit)
+			}
+			if !slices.Equal(res.Values, []float64{1, 2}) {
+				t.Errorf("expected [1 2], got %v", res.Values)
+			}
+		})
+	}
+
+	res, err := Decode(strings.NewReader("a=1\nb=x\n"), "data.kv", Options{Mode: ModeLenient})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Skipped != 1 || res.Errors[0].Line != 2 || res.Errors[0].Column != "b" {
+		t.Errorf("expected skipped record on line 2, got %+v", res.Errors)
+	}
+	if _, err := Decode(strings.NewReader("a=1\nb=x\n"), "data.kv", Options{}); err == nil {
+		t.Error("expected error in strict mode, got nil")
+	}
+
+	// Values of registered formats are counted once they are decoded
+	if _, err := Decode(strings.NewReader("a=1\nb=2\n"), "data.kv", Options{MaxValues: 1}); !errors.Is(err, ErrTooManyValues) {
+		t.Errorf("expected ErrTooManyValues, got %v", err)
+	}
+
+	if !isSupportedFile("hosts/web.kv") {
+		t.Error("expected .kv files to be supported")
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic registering a duplicate format")
+		}
+	}()
+	Register(csvFormat{})
+}
+
+func TestFormats(t *testing.T) {
+	var names []string
+	for _, f := range Formats() {
+		names = append(names, f.Name())
+	}
+	want := []string{FormatParquet, FormatJSON, FormatGoBench, FormatJUnit, FormatHAR, FormatOTLP, FormatNDJSON, FormatCSV, FormatText, FormatAccessLog}
+	if len(names) < len(want) || !slices.Equal(names[:len(want)], want) {
+		t.Errorf("expected built-in formats %v first, got %v", want, names)
+	}
+
+	f, ok := LookupFormat(FormatCSV)
+	if !ok || !slices.Contains(f.Extensions(), ".csv") {
+		t.Errorf("expected csv format with .csv extension, got %v", f)
+	}
+	if _, ok := LookupFormat("xml"); ok {
+		t.Error("expected no xml format")
+	}
+}
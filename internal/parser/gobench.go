@@ -0,0 +1,225 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FormatGoBench is the format name for `go test -bench` output, as text or as a
+// `go test -json` (test2json) stream
+const FormatGoBench = "gobench"
+
+// DefaultMetric is the Go benchmark metric read when Options.Metric is empty
+const DefaultMetric = "ns/op"
+
+// benchResult matches a benchmark result line: the name, the iteration count,
+// and the value/unit pairs that follow
+var benchResult = regexp.MustCompile(`^(Benchmark\S*)\s+(\d+)\s+(.+)$`)
+
+// benchMetricUnits maps metrics reported by the testing package to the units
+// of their values; other "<unit>/op" metrics use the unit before the slash
+var benchMetricUnits = map[string]string{
+	"ns/op":  "ns",
+	"sec/op": "s",
+	"B/op":   "B",
+}
+
+// test2jsonEvent is the subset of a `go test -json` event carrying output
+type test2jsonEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+func (o Options) metric() string {
+	if o.Metric == "" {
+		return DefaultMetric
+	}
+	return o.Metric
+}
+
+// benchMetricUnit returns the unit of a metric's values, if it has one that
+// can be converted with Options.Unit
+func benchMetricUnit(metric string) string {
+	if unit, ok := benchMetricUnits[metric]; ok {
+		return unit
+	}
+	if unit, ok := strings.CutSuffix(metric, "/op"); ok {
+		if _, err := ParseUnit(unit); err == nil {
+			return unit
+		}
+	}
+	return ""
+}
+
+type goBenchFormat struct{}
+
+func (goBenchFormat) Name() string         { return FormatGoBench }
+func (goBenchFormat) Extensions() []string { return []string{".bench"} }
+func (goBenchFormat) MIMETypes() []string  { return nil }
+
+// Sniff detects a test2json stream or any benchmark result line
+func (goBenchFormat) Sniff(prefix []byte, _ Options) bool {
+	first := sniffFirstLine(prefix)
+	if strings.HasPrefix(first, "{") {
+		return strings.Contains(first, `"Action":`)
+	}
+	for _, line := range strings.Split(string(prefix), "\n") {
+		if benchResult.MatchString(strings.TrimSpace(line)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Decode reads the selected metric of every benchmark result, grouped by
+// benchmark name unless grouping is disabled
+func (goBenchFormat) Decode(r io.Reader, dc *DecodeContext) ([]float64, error) {
+	key, err := groupKey(dc.Options, FormatGoBench, "name")
+	if err != nil {
+		return nil, err
+	}
+	b := &benchReader{
+		dc:     dc,
+		metric: dc.Options.metric(),
+		output: make(map[string]string),
+	}
+	b.unit = benchMetricUnit(b.metric)
+	if err := b.read(r); err != nil {
+		return nil, err
+	}
+
+	if b.results > 0 && len(b.values) == 0 && dc.rep.skipped == 0 {
+		return nil, fmt.Errorf("no benchmark reports metric %q (available: %s)", b.metric, strings.Join(b.metrics, ", "))
+	}
+	if key != GroupNone {
+		dc.SetGroups(b.labels)
+	}
+	return b.values, nil
+}
+
+// benchReader collects metric values from benchmark output
+type benchReader struct {
+	dc     *DecodeContext
+	metric string
+	unit   string
+
+	// output buffers test2json output per package and test until a line is
+	// complete, as benchmark names and results arrive in separate events
+	output map[string]string
+
+	results int
+	metrics []string
+	values  []float64
+	labels  []string
+}
+
+func (b *benchReader) read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTextLineLength)
+
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if !bytes.HasPrefix(raw, []byte("{")) {
+			if err := b.parseLine(line, string(raw)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var ev test2jsonEvent
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			if err := b.dc.Skip(line, "", string(raw), "invalid test2json event"); err != nil {
+				return fmt.Errorf("failed to parse benchmark output: %w", err)
+			}
+			continue
+		}
+		if ev.Action != "output" {
+			continue
+		}
+
+		key := ev.Package + "\x00" + ev.Test
+		text := b.output[key] + ev.Output
+		for {
+			out, rest, ok := strings.Cut(text, "\n")
+			if !ok {
+				break
+			}
+			if err := b.parseLine(line, strings.TrimSpace(out)); err != nil {
+				return err
+			}
+			text = rest
+		}
+		b.output[key] = text
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read benchmark output: %w", err)
+	}
+
+	// Flush output that did not end with a newline
+	keys := slices.Sorted(maps.Keys(b.output))
+	for _, key := range keys {
+		if err := b.parseLine(0, strings.TrimSpace(b.output[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseLine reads the metric from a benchmark result line. Other lines, such
+// as goos/pkg headers and PASS, are ignored.
+func (b *benchReader) parseLine(line int, text string) error {
+	m := benchResult.FindStringSubmatch(text)
+	if m == nil {
+		return nil
+	}
+	fields := strings.Fields(m[3])
+	if len(fields)%2 != 0 {
+		return nil
+	}
+	b.results++
+
+	for i := 0; i < len(fields); i += 2 {
+		metric := fields[i+1]
+		if !slices.Contains(b.metrics, metric) {
+			b.metrics = append(b.metrics, metric)
+		}
+		if metric != b.metric {
+			continue
+		}
+
+		value, err := b.parseValue(fields[i])
+		if err != nil {
+			if err := b.dc.Skip(line, m[1], fields[i], err.Error()); err != nil {
+				return fmt.Errorf("invalid benchmark result: %w", err)
+			}
+			return nil
+		}
+		if err := b.dc.rep.count(1); err != nil {
+			return err
+		}
+		b.values = append(b.values, value)
+		b.labels = append(b.labels, m[1])
+	}
+	return nil
+}
+
+func (b *benchReader) parseValue(s string) (float64, error) {
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	if b.unit == "" {
+		return value, nil
+	}
+	return b.dc.Values.Convert(value, b.unit)
+}
@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const benchText = `goos: linux
+goarch: amd64
+pkg: example.com/app
+cpu: AMD EPYC 7763 64-Core Processor
+BenchmarkEncode-8   	  120000	      9800 ns/op	    2048 B/op	      12 allocs/op
+BenchmarkEncode-8   	  120000	     10200 ns/op	    2048 B/op	      12 allocs/op
+BenchmarkDecode/small-8   	  500000	      2100 ns/op	  95.30 MB/s	     512 B/op	       3 allocs/op
+BenchmarkDecode/small-8   	  500000	      2300 ns/op	  87.10 MB/s	     512 B/op	       3 allocs/op
+PASS
+ok  	example.com/app	4.210s
+`
+
+// benchJSON is `go test -json -bench` output, where test2json emits the
+// benchmark name and its results as separate output events
+const benchJSON = `{"Action":"start","Package":"example.com/app"}
+{"Action":"output","Package":"example.com/app","Output":"goos: linux\n"}
+{"Action":"run","Package":"example.com/app","Test":"BenchmarkEncode"}
+{"Action":"output","Package":"example.com/app","Test":"BenchmarkEncode","Output":"BenchmarkEncode-8   \t"}
+{"Action":"output","Package":"example.com/app","Test":"BenchmarkEncode","Output":"  120000\t      9800 ns/op\t    2048 B/op\t      12 allocs/op\n"}
+{"Action":"output","Package":"example.com/app","Test":"BenchmarkEncode","Output":"BenchmarkEncode-8   \t  120000\t     10200 ns/op\t    2048 B/op\t      12 allocs/op\n"}
+{"Action":"output","Package":"example.com/app","Test":"BenchmarkEncode","Output":"--- BENCH: BenchmarkEncode-8\n"}
+{"Action":"pass","Package":"example.com/app","Elapsed":4.2}
+`
+
+func TestDecode_GoBench(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		opts       Options
+		want       []float64
+		wantUnit   string
+		wantGroups []Group
+	}{
+		{
+			name:     "default metric",
+			data:     benchText,
+			want:     []float64{9800, 10200, 2100, 2300},
+			wantUnit: "ns",
+			wantGroups: []Group{
+				{Name: "BenchmarkEncode-8", Values: []float64{9800, 10200}},
+				{Name: "BenchmarkDecode/small-8", Values: []float64{2100, 2300}},
+			},
+		},
+		{
+			name:     "converted",
+			data:     benchText,
+			opts:     Options{Unit: "us"},
+			want:     []float64{9.8, 10.2, 2.1, 2.3},
+			wantUnit: "us",
+			wantGroups: []Group{
+				{Name: "BenchmarkEncode-8", Values: []float64{9.8, 10.2}},
+				{Name: "BenchmarkDecode/small-8", Values: []float64{2.1, 2.3}},
+			},
+		},
+		{
+			name: "custom metric",
+			data: benchText,
+			opts: Options{Metric: "MB/s"},
+			want: []float64{95.3, 87.1},
+			wantGroups: []Group{
+				{Name: "BenchmarkDecode/small-8", Values: []float64{95.3, 87.1}},
+			},
+		},
+		{
+			name:     "test2json",
+			data:     benchJSON,
+			opts:     Options{Metric: "B/op"},
+			want:     []float64{2048, 2048},
+			wantUnit: "B",
+			wantGroups: []Group{
+				{Name: "BenchmarkEncode-8", Values: []float64{2048, 2048}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Decode(strings.NewReader(tt.data), "", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Format != FormatGoBench {
+				t.Errorf("expected format %q, got %q", FormatGoBench, res.Format)
+			}
+			if !slices.EqualFunc(res.Values, tt.want, func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }) {
+				t.Errorf("expected %v, got %v", tt.want, res.Values)
+			}
+			if res.Unit != tt.wantUnit {
+				t.Errorf("expected unit %q, got %q", tt.wantUnit, res.Unit)
+			}
+			if len(res.Groups) != len(tt.wantGroups) {
+				t.Fatalf("expected groups %v, got %v", tt.wantGroups, res.Groups)
+			}
+			for i, g := range res.Groups {
+				if g.Name != tt.wantGroups[i].Name || len(g.Values) != len(tt.wantGroups[i].Values) {
+					t.Errorf("expected group %v, got %v", tt.wantGroups[i], g)
+				}
+			}
+		})
+	}
+}
+
+func TestDecode_GoBenchErrors(t *testing.T) {
+	if _, err := Decode(strings.NewReader(benchText), "bench.txt", Options{Metric: "widgets/op"}); err == nil ||
+		!strings.Contains(err.Error(), "allocs/op") {
+		t.Errorf("expected error listing available metrics, got %v", err)
+	}
+	if _, err := Decode(strings.NewReader(benchText), "bench.txt", Options{Metric: "B/op", Unit: "ms"}); err == nil {
+		t.Error("expected error converting bytes to milliseconds, got nil")
+	}
+}
+
+func TestSniffFormat_GoBench(t *testing.T) {
+	for _, data := range []string{benchText, benchJSON, "BenchmarkX 10 5 ns/op\n"} {
+		if got := SniffFormat([]byte(data)); got != FormatGoBench {
+			t.Errorf("expected %q for %.30q, got %q", FormatGoBench, data, got)
+		}
+	}
+	if got := SniffFormat([]byte("{\"value\": 1}\n")); got != FormatNDJSON {
+		t.Errorf("expected NDJSON objects to stay NDJSON, got %q", got)
+	}
+}
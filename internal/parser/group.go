@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// GroupNone disables grouping for formats that group their values by default
+const GroupNone = "none"
+
+// Group holds the values of one labelled series within an input, such as a
+// single benchmark in Go benchmark output
+type Group struct {
+	Name   string
+	Values []float64
+	// Names identifies each value, such as the test it was measured for, when
+	// the format provides one
+	Names []string
+	// NonFinite counts the group's NaN and ±Inf values dropped or clamped
+	// by Options.NonFinite
+	NonFinite NonFiniteStats
+}
+
+// groupKey validates opts.GroupBy against the keys a format supports and
+// returns the selected one. The first key is the default; GroupNone is always
+// accepted.
+func groupKey(opts Options, format string, keys ...string) (string, error) {
+	key := strings.ToLower(opts.GroupBy)
+	switch {
+	case key == "":
+		return keys[0], nil
+	case key == GroupNone || slices.Contains(keys, key):
+		return key, nil
+	default:
+		return "", fmt.Errorf("cannot group %s input by %q (supported: %s, %s)", format, opts.GroupBy, strings.Join(keys, ", "), GroupNone)
+	}
+}
+
+// groupValues splits values into groups by label, in order of first
+// appearance, applying the non-finite policy to each group. names, if not nil,
+// identifies each value.
+func groupValues(values []float64, labels, names []string, policy NonFinitePolicy) ([]Group, error) {
+	if len(labels) != len(values) {
+		return nil, fmt.Errorf("decoder returned %d group labels for %d values", len(labels), len(values))
+	}
+	if names != nil && len(names) != len(values) {
+		return nil, fmt.Errorf("decoder returned %d value names for %d values", len(names), len(values))
+	}
+
+	var groups []Group
+	index := make(map[string]int)
+	for i, label := range labels {
+		g, ok := index[label]
+		if !ok {
+			g = len(groups)
+			index[label] = g
+			groups = append(groups, Group{Name: label})
+		}
+		groups[g].Values = append(groups[g].Values, values[i])
+		if names != nil {
+			groups[g].Names = append(groups[g].Names, names[i])
+		}
+	}
+
+	for i := range groups {
+		g := &groups[i]
+		if g.Names != nil && policy == NonFiniteDrop {
+			kept := g.Names[:0]
+			for j, v := range g.Values {
+				if isFinite(v) {
+					kept = append(kept, g.Names[j])
+				}
+			}
+			g.Names = kept
+		}
+		values, stats, err := ApplyNonFinitePolicy(g.Values, policy)
+		if err != nil {
+			return nil, fmt.Errorf("group %q contains %w", g.Name, err)
+		}
+		g.Values, g.NonFinite = values, stats
+	}
+	return groups, nil
+}
+
+// mergeGroups appends groups to merged, pooling groups of the same name
+func mergeGroups(merged, groups []Group) []Group {
+	for _, g := range groups {
+		i := slices.IndexFunc(merged, func(m Group) bool { return m.Name == g.Name })
+		if i < 0 {
+			i = len(merged)
+			merged = append(merged, Group{Name: g.Name})
+		}
+		merged[i].Values = append(merged[i].Values, g.Values...)
+		merged[i].NonFinite.Dropped += g.NonFinite.Dropped
+		merged[i].NonFinite.Clamped += g.NonFinite.Clamped
+		merged[i].Names = append(merged[i].Names, g.Names...)
+		if len(merged[i].Names) != len(merged[i].Values) {
+			// Only some inputs named their values
+			merged[i].Names = nil
+		}
+	}
+	return merged
+}
@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// FormatHAR is the format name for HTTP Archive (HAR) files exported by
+// browsers
+const FormatHAR = "har"
+
+// HAR timing phases. PhaseTotal is the entry's total time.
+const (
+	PhaseBlocked = "blocked"
+	PhaseDNS     = "dns"
+	PhaseConnect = "connect"
+	PhaseSSL     = "ssl"
+	PhaseSend    = "send"
+	PhaseWait    = "wait"
+	PhaseReceive = "receive"
+	PhaseTotal   = "total"
+)
+
+var harPhases = []string{PhaseBlocked, PhaseDNS, PhaseConnect, PhaseSSL, PhaseSend, PhaseWait, PhaseReceive, PhaseTotal}
+
+// harLog matches the opening of a HAR document
+var harLog = regexp.MustCompile(`^\{\s*"log"\s*:\s*\{`)
+
+// HAROptions configures extraction of timings from HAR files
+type HAROptions struct {
+	// Phase is the timing phase read from each entry: blocked, dns, connect,
+	// ssl, send, wait, receive or total (the default)
+	Phase string
+
+	// URL restricts entries to request URLs containing this substring
+	URL string
+
+	// Host restricts entries to this host or its subdomains
+	Host string
+
+	// MIME restricts entries to response content types with this prefix,
+	// e.g. "image/" or "application/json"
+	MIME string
+}
+
+func (o HAROptions) phase() (string, error) {
+	phase := strings.ToLower(o.Phase)
+	if phase == "" {
+		return PhaseTotal, nil
+	}
+	if !slices.Contains(harPhases, phase) {
+		return "", fmt.Errorf("unknown HAR timing phase %q (supported: %s)", o.Phase, strings.Join(harPhases, ", "))
+	}
+	return phase, nil
+}
+
+// harEntry is the subset of a HAR entry holding its timings
+type harEntry struct {
+	Time    float64 `json:"time"`
+	Request struct {
+		URL string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		Content struct {
+			MimeType string `json:"mimeType"`
+		} `json:"content"`
+	} `json:"response"`
+	Timings map[string]*float64 `json:"timings"`
+}
+
+// timing returns an entry's time in milliseconds for a phase, reporting false
+// when the phase does not apply (-1 or absent)
+func (e harEntry) timing(phase string) (float64, bool) {
+	if phase == PhaseTotal {
+		return e.Time, true
+	}
+	v := e.Timings[phase]
+	if v == nil || *v == -1 {
+		return 0, false
+	}
+	return *v, true
+}
+
+// matches reports whether an entry passes the URL, host and MIME filters
+func (e harEntry) matches(opts HAROptions, host string) bool {
+	if opts.URL != "" && !strings.Contains(e.Request.URL, opts.URL) {
+		return false
+	}
+	if opts.Host != "" {
+		want := strings.ToLower(opts.Host)
+		if host != want && !strings.HasSuffix(host, "."+want) {
+			return false
+		}
+	}
+	if opts.MIME != "" {
+		mediaType, _, err := mime.ParseMediaType(e.Response.Content.MimeType)
+		if err != nil {
+			mediaType = e.Response.Content.MimeType
+		}
+		if !strings.HasPrefix(strings.ToLower(mediaType), strings.ToLower(opts.MIME)) {
+			return false
+		}
+	}
+	return true
+}
+
+type harFormat struct{}
+
+func (harFormat) Name() string         { return FormatHAR }
+func (harFormat) Extensions() []string { return []string{".har"} }
+func (harFormat) MIMETypes() []string  { return []string{"application/har+json"} }
+
+func (harFormat) Sniff(prefix []byte, _ Options) bool {
+	return harLog.Match(sniffTrim(prefix))
+}
+
+// Decode reads a timing phase, in milliseconds, from every entry passing the
+// filters, grouped by host (the default) or response MIME type and named
+// after the request URL
+func (harFormat) Decode(r io.Reader, dc *DecodeContext) ([]float64, error) {
+	opts := dc.Options.HAR
+	phase, err := opts.phase()
+	if err != nil {
+		return nil, err
+	}
+	key, err := groupKey(dc.Options, FormatHAR, "host", "mime")
+	if err != nil {
+		return nil, err
+	}
+
+	var har struct {
+		Log *struct {
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("failed to parse HAR: %w", err)
+	}
+	if har.Log == nil {
+		return nil, fmt.Errorf("failed to parse HAR: missing log object")
+	}
+
+	var values []float64
+	var labels, names []string
+	for i, entry := range har.Log.Entries {
+		host := ""
+		if u, err := url.Parse(entry.Request.URL); err == nil {
+			host = strings.ToLower(u.Hostname())
+		}
+		if !entry.matches(opts, host) {
+			continue
+		}
+
+		ms, ok := entry.timing(phase)
+		if !ok {
+			continue
+		}
+		if ms < 0 {
+			if err := dc.Skip(0, fmt.Sprintf("$.log.entries[%d].%s", i, phase), fmt.Sprint(ms), "negative timing"); err != nil {
+				return nil, fmt.Errorf("invalid HAR entry: %w", err)
+			}
+			continue
+		}
+		value, err := dc.Values.Convert(ms, "ms")
+		if err != nil {
+			return nil, err
+		}
+
+		if err := dc.rep.count(1); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		names = append(names, entry.Request.URL)
+		if key == "mime" {
+			mediaType, _, _ := mime.ParseMediaType(entry.Response.Content.MimeType)
+			labels = append(labels, mediaType)
+		} else {
+			labels = append(labels, host)
+		}
+	}
+
+	if key != GroupNone {
+		dc.SetGroups(labels)
+		dc.SetNames(names)
+	}
+	return values, nil
+}
@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const harFile = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "Firefox", "version": "131.0"},
+    "entries": [
+      {
+        "time": 120.5,
+        "request": {"method": "GET", "url": "https://www.example.com/"},
+        "response": {"status": 200, "content": {"mimeType": "text/html; charset=utf-8"}},
+        "timings": {"blocked": 1, "dns": 10, "connect": 30, "ssl": 20, "send": 0.5, "wait": 70, "receive": 9}
+      },
+      {
+        "time": 45,
+        "request": {"method": "GET", "url": "https://cdn.example.com/app.js"},
+        "response": {"status": 200, "content": {"mimeType": "application/javascript"}},
+        "timings": {"blocked": -1, "dns": -1, "connect": -1, "ssl": -1, "send": 1, "wait": 40, "receive": 4}
+      },
+      {
+        "time": 300,
+        "request": {"method": "GET", "url": "https://api.other.net/v1/items?page=2"},
+        "response": {"status": 200, "content": {"mimeType": "application/json"}},
+        "timings": {"blocked": 2, "dns": 25, "connect": 50, "ssl": 35, "send": 1, "wait": 200, "receive": 22}
+      },
+      {
+        "time": 15,
+        "request": {"method": "GET", "url": "https://cdn.example.com/logo.png"},
+        "response": {"status": 200, "content": {"mimeType": "image/png"}},
+        "timings": {"send": 0, "wait": 10, "receive": 5}
+      }
+    ]
+  }
+}`
+
+func TestDecode_HAR(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       Options
+		want       []float64
+		wantGroups []string
+	}{
+		{name: "total", want: []float64{120.5, 45, 300, 15}, wantGroups: []string{"www.example.com", "cdn.example.com", "api.other.net"}},
+		{name: "wait phase", opts: Options{HAR: HAROptions{Phase: "wait"}}, want: []float64{70, 40, 200, 10}, wantGroups: []string{"www.example.com", "cdn.example.com", "api.other.net"}},
+		{name: "dns skips missing and -1", opts: Options{HAR: HAROptions{Phase: "DNS"}}, want: []float64{10, 25}, wantGroups: []string{"www.example.com", "api.other.net"}},
+		{name: "host filter matches subdomains", opts: Options{HAR: HAROptions{Host: "example.com"}}, want: []float64{120.5, 45, 15}, wantGroups: []string{"www.example.com", "cdn.example.com"}},
+		{name: "URL filter", opts: Options{HAR: HAROptions{URL: "/v1/"}}, want: []float64{300}, wantGroups: []string{"api.other.net"}},
+		{name: "MIME filter", opts: Options{HAR: HAROptions{MIME: "application/"}}, want: []float64{45, 300}, wantGroups: []string{"cdn.example.com", "api.other.net"}},
+		{name: "group by MIME type", opts: Options{GroupBy: "mime"}, want: []float64{120.5, 45, 300, 15}, wantGroups: []string{"text/html", "application/javascript", "application/json", "image/png"}},
+		{name: "ungrouped in seconds", opts: Options{GroupBy: GroupNone, Unit: "s", HAR: HAROptions{Phase: "receive"}}, want: []float64{0.009, 0.004, 0.022, 0.005}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Decode(strings.NewReader(harFile), "session.har", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Format != FormatHAR {
+				t.Errorf("expected format %q, got %q", FormatHAR, res.Format)
+			}
+			if !slices.EqualFunc(res.Values, tt.want, func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }) {
+				t.Errorf("expected %v, got %v", tt.want, res.Values)
+			}
+			var groups []string
+			for _, g := range res.Groups {
+				groups = append(groups, g.Name)
+			}
+			if !slices.Equal(groups, tt.wantGroups) {
+				t.Errorf("expected groups %v, got %v", tt.wantGroups, groups)
+			}
+		})
+	}
+}
+
+func TestDecode_HARSniffed(t *testing.T) {
+	res, err := Decode(strings.NewReader(harFile), "blob", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Format != FormatHAR || res.Unit != "ms" {
+		t.Errorf("expected HAR in ms, got %s in %s", res.Format, res.Unit)
+	}
+	if names := res.Groups[1].Names; !slices.Equal(names, []string{"https://cdn.example.com/app.js", "https://cdn.example.com/logo.png"}) {
+		t.Errorf("expected entry URLs as names, got %v", names)
+	}
+}
+
+func TestReadValuesFromFile_HAR(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.har")
+	if err := os.WriteFile(path, []byte(harFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := ReadValuesFromFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(values, []float64{120.5, 45, 300, 15}) {
+		t.Errorf("expected total times, got %v", values)
+	}
+}
+
+func TestDecode_HARErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		opts Options
+	}{
+		{name: "unknown phase", data: harFile, opts: Options{HAR: HAROptions{Phase: "tls"}}},
+		{name: "unknown grouping", data: harFile, opts: Options{GroupBy: "suite"}},
+		{name: "not a HAR", data: `{"entries": []}`},
+		{name: "malformed", data: `{"log": {"entries": [`},
+		{name: "negative timing", data: `{"log": {"entries": [{"time": -5, "request": {"url": "https://a.test/"}}]}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(strings.NewReader(tt.data), "session.har", tt.opts); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package parser
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FormatJUnit is the format name for JUnit XML test reports
+const FormatJUnit = "junit"
+
+// junitTestCase is a <testcase> element. Skipped tests did not run, so their
+// times are ignored.
+type junitTestCase struct {
+	Name      string    `xml:"name,attr"`
+	Classname string    `xml:"classname,attr"`
+	Time      string    `xml:"time,attr"`
+	Skipped   *struct{} `xml:"skipped"`
+}
+
+// label returns the name identifying the test case, qualified by its class
+func (tc junitTestCase) label() string {
+	if tc.Classname == "" {
+		return tc.Name
+	}
+	return tc.Classname + "." + tc.Name
+}
+
+type junitFormat struct{}
+
+func (junitFormat) Name() string         { return FormatJUnit }
+func (junitFormat) Extensions() []string { return []string{".xml"} }
+func (junitFormat) MIMETypes() []string  { return []string{"application/xml", "text/xml"} }
+
+// Sniff detects an XML document with a <testsuites> or <testsuite> element
+func (junitFormat) Sniff(prefix []byte, _ Options) bool {
+	trimmed := string(sniffTrim(prefix))
+	return strings.HasPrefix(trimmed, "<") && strings.Contains(trimmed, "<testsuite")
+}
+
+// Decode reads the time of every test case, in seconds, grouped by test suite
+// (the default), class or test name and named after the test
+func (junitFormat) Decode(r io.Reader, dc *DecodeContext) ([]float64, error) {
+	key, err := groupKey(dc.Options, FormatJUnit, "suite", "class", "name")
+	if err != nil {
+		return nil, err
+	}
+
+	dec := xml.NewDecoder(r)
+	var suites []string
+	var values []float64
+	var labels, names []string
+	testcases := 0
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JUnit XML: %w", err)
+		}
+
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "testsuite":
+				suites = append(suites, junitAttr(el, "name"))
+			case "testcase":
+				line, _ := dec.InputPos()
+				var tc junitTestCase
+				if err := dec.DecodeElement(&tc, &el); err != nil {
+					return nil, fmt.Errorf("failed to parse JUnit XML: %w", err)
+				}
+				testcases++
+				if tc.Skipped != nil {
+					continue
+				}
+
+				value, err := parseJUnitTime(tc.Time, dc.Values)
+				if err != nil {
+					if err := dc.Skip(line, tc.label(), tc.Time, err.Error()); err != nil {
+						return nil, fmt.Errorf("invalid JUnit test case: %w", err)
+					}
+					continue
+				}
+
+				suite := ""
+				if len(suites) > 0 {
+					suite = suites[len(suites)-1]
+				}
+				if err := dc.rep.count(1); err != nil {
+					return nil, err
+				}
+				values = append(values, value)
+				names = append(names, tc.label())
+				switch key {
+				case "suite":
+					labels = append(labels, suite)
+				case "class":
+					labels = append(labels, tc.Classname)
+				case "name":
+					labels = append(labels, tc.label())
+				}
+			}
+		case xml.EndElement:
+			if el.Name.Local == "testsuite" && len(suites) > 0 {
+				suites = suites[:len(suites)-1]
+			}
+		}
+	}
+
+	if testcases == 0 {
+		return nil, fmt.Errorf("no JUnit test cases found")
+	}
+	if key != GroupNone {
+		dc.SetGroups(labels)
+		dc.SetNames(names)
+	}
+	return values, nil
+}
+
+// parseJUnitTime converts a time attribute in seconds into the target unit.
+// Times are read in the configured locale; without one, a lone comma as in
+// "1,5" is a decimal comma, and other commas must group thousands, as some
+// reporters write "1,234.5".
+func parseJUnitTime(s string, vp *ValueParser) (float64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("missing time")
+	}
+	number := s
+	switch loc := vp.Locale(); {
+	case !loc.isDefault():
+		number = loc.normalize(s)
+	case strings.Count(s, ",") == 1 && !strings.Contains(s, "."):
+		number = strings.Replace(s, ",", ".", 1)
+	case strings.Contains(s, ","):
+		number = ungroupThousands(s)
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return vp.Convert(value, "s")
+}
+
+// ungroupThousands removes the commas of a number grouped in thousands, such
+// as "1,234.5", or returns "" if they do not separate groups of three digits
+func ungroupThousands(s string) string {
+	integer, fraction, decimal := strings.Cut(s, ".")
+	groups := strings.Split(integer, ",")
+	if lead := strings.TrimLeft(groups[0], "+-"); lead == "" || len(lead) > 3 {
+		return ""
+	}
+	for _, g := range groups[1:] {
+		if len(g) != 3 {
+			return ""
+		}
+	}
+	number := strings.Join(groups, "")
+	if decimal {
+		number += "." + fraction
+	}
+	return number
+}
+
+func junitAttr(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
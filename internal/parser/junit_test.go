@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/wingnut128/outlier-go/internal/calculator"
+)
+
+const junitReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="api" tests="3">
+    <testcase classname="api.Users" name="TestCreate" time="0.120"/>
+    <testcase classname="api.Users" name="TestDelete" time="0.080">
+      <failure message="boom">trace</failure>
+    </testcase>
+    <testcase classname="api.Orders" name="TestList" time="2.5"/>
+  </testsuite>
+  <testsuite name="db" tests="2">
+    <testcase classname="db.Pool" name="TestAcquire" time="1,234.5"/>
+    <testcase classname="db.Pool" name="TestSkipped" time="0">
+      <skipped/>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+
+func TestDecode_JUnit(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       Options
+		want       []float64
+		wantGroups []string
+	}{
+		{name: "by suite", want: []float64{0.12, 0.08, 2.5, 1234.5}, wantGroups: []string{"api", "db"}},
+		{name: "by class", opts: Options{GroupBy: "class"}, want: []float64{0.12, 0.08, 2.5, 1234.5}, wantGroups: []string{"api.Users", "api.Orders", "db.Pool"}},
+		{name: "by name", opts: Options{GroupBy: "name"}, want: []float64{0.12, 0.08, 2.5, 1234.5}, wantGroups: []string{"api.Users.TestCreate", "api.Users.TestDelete", "api.Orders.TestList", "db.Pool.TestAcquire"}},
+		{name: "ungrouped in ms", opts: Options{GroupBy: GroupNone, Unit: "ms"}, want: []float64{120, 80, 2500, 1234500}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Decode(strings.NewReader(junitReport), "report.xml", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Format != FormatJUnit {
+				t.Errorf("expected format %q, got %q", FormatJUnit, res.Format)
+			}
+			if !slices.Equal(res.Values, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, res.Values)
+			}
+			var groups []string
+			for _, g := range res.Groups {
+				groups = append(groups, g.Name)
+			}
+			if !slices.Equal(groups, tt.wantGroups) {
+				t.Errorf("expected groups %v, got %v", tt.wantGroups, groups)
+			}
+		})
+	}
+}
+
+func TestDecode_JUnitNames(t *testing.T) {
+	res, err := Decode(strings.NewReader(junitReport), "", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Format != FormatJUnit {
+		t.Fatalf("expected sniffed format %q, got %q", FormatJUnit, res.Format)
+	}
+	want := []string{"api.Users.TestCreate", "api.Users.TestDelete", "api.Orders.TestList"}
+	if !slices.Equal(res.Groups[0].Names, want) {
+		t.Errorf("expected names %v, got %v", want, res.Groups[0].Names)
+	}
+}
+
+func TestDecode_JUnitDecimalComma(t *testing.T) {
+	data := `<testsuite><testcase name="a" time="1,5"/><testcase name="b" time="2,5"/></testsuite>`
+	res, err := Decode(strings.NewReader(data), "report.xml", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(res.Values, []float64{1.5, 2.5}) {
+		t.Fatalf("expected [1.5 2.5], got %v", res.Values)
+	}
+	if p50, err := calculator.CalculatePercentile(res.Values, 50); err != nil || p50 != 2 {
+		t.Errorf("expected a P50 of 2 s, got %v, %v", p50, err)
+	}
+
+	// A configured locale decides instead
+	loc, err := NewLocale("de", "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = `<testsuite><testcase name="a" time="1.234,5"/></testsuite>`
+	res, err = Decode(strings.NewReader(data), "report.xml", Options{Locale: loc})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(res.Values, []float64{1234.5}) {
+		t.Errorf("expected [1234.5], got %v", res.Values)
+	}
+}
+
+func TestDecode_JUnitErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		opts Options
+	}{
+		{name: "no test cases", data: `<testsuites></testsuites>`},
+		{name: "malformed XML", data: `<testsuite><testcase name="a" time="1">`},
+		{name: "missing time", data: `<testsuite><testcase name="a"/></testsuite>`},
+		{name: "invalid time", data: `<testsuite><testcase name="a" time="fast"/></testsuite>`},
+		{name: "misplaced thousands separator", data: `<testsuite><testcase name="a" time="12,34.5"/></testsuite>`},
+		{name: "several decimal commas", data: `<testsuite><testcase name="a" time="1,5,0"/></testsuite>`},
+		{name: "unknown grouping", data: junitReport, opts: Options{GroupBy: "host"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(strings.NewReader(tt.data), "report.xml", tt.opts); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+
+	res, err := Decode(strings.NewReader(`<testsuite><testcase name="a" time="x"/><testcase name="b" time="2"/></testsuite>`), "report.xml", Options{Mode: ModeLenient})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Skipped != 1 || res.Errors[0].Column != "a" || res.Errors[0].Line != 1 {
+		t.Errorf("expected test a to be skipped on line 1, got %+v", res.Errors)
+	}
+}
@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Locale describes how numbers are written in localized exports such as
+// "1.234,56" or "1 234,56 €". The zero value parses plain Go-style numbers.
+type Locale struct {
+	// Decimal is the decimal separator, "." (the default) or ","
+	Decimal string
+	// Grouping is the thousands separator removed before parsing, if any. A
+	// space also matches no-break and narrow no-break spaces.
+	Grouping string
+	// StripSymbols removes currency symbols and percent signs, so "€12" and
+	// "12%" both read as 12
+	StripSymbols bool
+}
+
+// localePresets maps lowercase language or language-region tags to their
+// number formats
+var localePresets = map[string]Locale{
+	"c":     {Decimal: "."},
+	"en":    {Decimal: ".", Grouping: ","},
+	"ja":    {Decimal: ".", Grouping: ","},
+	"zh":    {Decimal: ".", Grouping: ","},
+	"de":    {Decimal: ",", Grouping: "."},
+	"nl":    {Decimal: ",", Grouping: "."},
+	"es":    {Decimal: ",", Grouping: "."},
+	"it":    {Decimal: ",", Grouping: "."},
+	"pt":    {Decimal: ",", Grouping: "."},
+	"da":    {Decimal: ",", Grouping: "."},
+	"id":    {Decimal: ",", Grouping: "."},
+	"tr":    {Decimal: ",", Grouping: "."},
+	"fr":    {Decimal: ",", Grouping: " "},
+	"sv":    {Decimal: ",", Grouping: " "},
+	"fi":    {Decimal: ",", Grouping: " "},
+	"nb":    {Decimal: ",", Grouping: " "},
+	"pl":    {Decimal: ",", Grouping: " "},
+	"cs":    {Decimal: ",", Grouping: " "},
+	"ru":    {Decimal: ",", Grouping: " "},
+	"de-ch": {Decimal: ".", Grouping: "'"},
+	"fr-ch": {Decimal: ".", Grouping: "'"},
+	"it-ch": {Decimal: ".", Grouping: "'"},
+}
+
+// NewLocale resolves a locale preset such as "de", "fr-FR" or "de_CH.UTF-8"
+// and applies explicit separator overrides. All arguments may be empty.
+func NewLocale(preset, decimal, grouping string, stripSymbols bool) (Locale, error) {
+	var loc Locale
+	if preset != "" {
+		p, err := lookupLocale(preset)
+		if err != nil {
+			return Locale{}, err
+		}
+		loc = p
+	}
+	if decimal != "" {
+		loc.Decimal = decimal
+	}
+	if grouping != "" {
+		loc.Grouping = grouping
+	}
+	loc.StripSymbols = loc.StripSymbols || stripSymbols
+
+	if err := loc.validate(); err != nil {
+		return Locale{}, err
+	}
+	return loc, nil
+}
+
+func lookupLocale(name string) (Locale, error) {
+	tag := strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	tag, _, _ = strings.Cut(tag, ".")
+
+	if loc, ok := localePresets[tag]; ok {
+		return loc, nil
+	}
+	lang, _, _ := strings.Cut(tag, "-")
+	if loc, ok := localePresets[lang]; ok {
+		return loc, nil
+	}
+	return Locale{}, fmt.Errorf("unknown locale %q", name)
+}
+
+func (l Locale) validate() error {
+	if l.Decimal != "" && l.Decimal != "." && l.Decimal != "," {
+		return fmt.Errorf("decimal separator must be \".\" or \",\", got %q", l.Decimal)
+	}
+	if l.Grouping == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(l.Grouping) != 1 {
+		return fmt.Errorf("grouping separator must be a single character, got %q", l.Grouping)
+	}
+	if l.Grouping == l.decimal() {
+		return fmt.Errorf("grouping and decimal separators must differ, both are %q", l.Grouping)
+	}
+	r, _ := utf8.DecodeRuneInString(l.Grouping)
+	if unicode.IsDigit(r) || r == '-' || r == '+' {
+		return fmt.Errorf("invalid grouping separator %q", l.Grouping)
+	}
+	return nil
+}
+
+func (l Locale) decimal() string {
+	if l.Decimal == "" {
+		return "."
+	}
+	return l.Decimal
+}
+
+// isDefault reports whether numbers can be parsed without normalization
+func (l Locale) isDefault() bool {
+	return l.decimal() == "." && l.Grouping == "" && !l.StripSymbols
+}
+
+// normalize rewrites a localized number into Go syntax, e.g. "1.234,56 €"
+// becomes "1234.56"
+func (l Locale) normalize(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		case l.StripSymbols && (r == '%' || unicode.Is(unicode.Sc, r)):
+		case l.isGrouping(r):
+		case string(r) == l.decimal():
+			b.WriteByte('.')
+		case r == '.' && l.decimal() != ".":
+			// A "." that is neither decimal nor grouping separator makes the
+			// number ambiguous, e.g. "1.234" in a locale grouping with spaces
+			return ""
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func (l Locale) isGrouping(r rune) bool {
+	if l.Grouping == "" {
+		return false
+	}
+	if l.Grouping == " " {
+		return r == ' ' || r == '\u00a0' || r == '\u202f'
+	}
+	return string(r) == l.Grouping
+}
+
+// csvDelimiter picks the CSV field delimiter from a header line: semicolons
+// or tabs when they outnumber commas, as in spreadsheets exported with a comma
+// decimal separator
+func csvDelimiter(header string) rune {
+	commas := strings.Count(header, ",")
+	semicolons := strings.Count(header, ";")
+	tabs := strings.Count(header, "\t")
+
+	switch {
+	case semicolons > commas && semicolons >= tabs:
+		return ';'
+	case tabs > commas:
+		return '\t'
+	default:
+		return ','
+	}
+}
@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewLocale(t *testing.T) {
+	tests := []struct {
+		name     string
+		preset   string
+		decimal  string
+		grouping string
+		strip    bool
+		want     Locale
+		wantErr  bool
+	}{
+		{name: "empty", want: Locale{}},
+		{name: "german", preset: "de", want: Locale{Decimal: ",", Grouping: "."}},
+		{name: "region tag", preset: "de_AT.UTF-8", want: Locale{Decimal: ",", Grouping: "."}},
+		{name: "swiss", preset: "de-CH", want: Locale{Decimal: ".", Grouping: "'"}},
+		{name: "french", preset: "fr-FR", want: Locale{Decimal: ",", Grouping: " "}},
+		{name: "overrides", preset: "en", grouping: "_", strip: true, want: Locale{Decimal: ".", Grouping: "_", StripSymbols: true}},
+		{name: "explicit separators", decimal: ",", grouping: ".", want: Locale{Decimal: ",", Grouping: "."}},
+		{name: "unknown preset", preset: "xx", wantErr: true},
+		{name: "invalid decimal", decimal: ";", wantErr: true},
+		{name: "same separators", decimal: ",", grouping: ",", wantErr: true},
+		{name: "multi-character grouping", grouping: "..", wantErr: true},
+		{name: "digit grouping", grouping: "0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewLocale(tt.preset, tt.decimal, tt.grouping, tt.strip)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValueParser_Locale(t *testing.T) {
+	german := Locale{Decimal: ",", Grouping: "."}
+	french := Locale{Decimal: ",", Grouping: " "}
+	english := Locale{Decimal: ".", Grouping: ","}
+
+	tests := []struct {
+		name    string
+		locale  Locale
+		input   string
+		want    float64
+		wantErr bool
+	}{
+		{name: "german decimal", locale: german, input: "1,5", want: 1.5},
+		{name: "german grouping", locale: german, input: "1.234,56", want: 1234.56},
+		{name: "german negative", locale: german, input: "-1.000", want: -1000},
+		{name: "german with unit", locale: german, input: "1,5 s", want: 1.5},
+		{name: "french spaces", locale: french, input: "1 234 567,8", want: 1234567.8},
+		{name: "french no-break space", locale: french, input: "1\u00a0234,5", want: 1234.5},
+		{name: "french narrow no-break space", locale: french, input: "1\u202f234,5", want: 1234.5},
+		{name: "french rejects dot", locale: french, input: "1.5", wantErr: true},
+		{name: "english grouping", locale: english, input: "1,234.5", want: 1234.5},
+		{name: "currency kept without stripping", locale: german, input: "12 €", wantErr: true},
+		{name: "currency", locale: Locale{Decimal: ",", Grouping: ".", StripSymbols: true}, input: "1.234,50 €", want: 1234.5},
+		{name: "leading currency", locale: Locale{StripSymbols: true}, input: "$-12.5", want: -12.5},
+		{name: "percent", locale: Locale{Decimal: ",", StripSymbols: true}, input: "12,5 %", want: 12.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&ValueParser{}).WithLocale(tt.locale).Parse(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCSVDelimiter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   rune
+	}{
+		{"host,value", ','},
+		{"host;value", ';'},
+		{"host;value,extra", ','},
+		{"host\tvalue", '\t'},
+		{"value", ','},
+	}
+
+	for _, tt := range tests {
+		if got := csvDelimiter(tt.header); got != tt.want {
+			t.Errorf("csvDelimiter(%q): expected %q, got %q", tt.header, tt.want, got)
+		}
+	}
+}
+
+func TestDecode_Locale(t *testing.T) {
+	german := Locale{Decimal: ",", Grouping: ".", StripSymbols: true}
+
+	tests := []struct {
+		name       string
+		data       string
+		filename   string
+		locale     Locale
+		wantFormat string
+		want       []float64
+	}{
+		{name: "semicolon CSV", data: "host;value\na;1.234,5\nb;\"2,5\"\n", filename: "export.csv", locale: german, wantFormat: FormatCSV, want: []float64{1234.5, 2.5}},
+		{name: "sniffed semicolon CSV", data: "host;value\na;1,5 €\n", filename: "export", locale: german, wantFormat: FormatCSV, want: []float64{1.5}},
+		{name: "comma decimal text", data: "1,5\n2,25\n", filename: "data.txt", locale: german, wantFormat: FormatText, want: []float64{1.5, 2.25}},
+		{name: "sniffed comma decimal text", data: "1,5 2,5\n", filename: "blob", locale: german, wantFormat: FormatText, want: []float64{1.5, 2.5}},
+		{name: "JSON strings", data: `["1.000,5", 2]`, filename: "data.json", locale: german, wantFormat: FormatJSON, want: []float64{1000.5, 2}},
+		{name: "tab separated", data: "host\tvalue\na\t3\n", filename: "data.csv", wantFormat: FormatCSV, want: []float64{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Decode(strings.NewReader(tt.data), tt.filename, Options{Locale: tt.locale})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Format != tt.wantFormat {
+				t.Errorf("expected format %q, got %q", tt.wantFormat, res.Format)
+			}
+			if !slices.Equal(res.Values, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, res.Values)
+			}
+		})
+	}
+}
+
+func TestDecode_InvalidLocale(t *testing.T) {
+	if _, err := Decode(strings.NewReader("value\n1\n"), "data.csv", Options{Locale: Locale{Decimal: ";"}}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// NonFinitePolicy decides how NaN and ±Inf values are handled
+type NonFinitePolicy string
+
+// Non-finite value policies
+const (
+	// NonFiniteReject fails on the first NaN or ±Inf value
+	NonFiniteReject NonFinitePolicy = "reject"
+	// NonFiniteDrop removes NaN and ±Inf values
+	NonFiniteDrop NonFinitePolicy = "drop"
+	// NonFiniteClamp treats +Inf as the largest and -Inf as the smallest finite
+	// value in the dataset. NaN has no place in the ordering and is dropped.
+	NonFiniteClamp NonFinitePolicy = "clamp"
+)
+
+// ParseNonFinitePolicy parses a policy name, defaulting to NonFiniteReject when
+// name is empty
+func ParseNonFinitePolicy(name string) (NonFinitePolicy, error) {
+	switch policy := NonFinitePolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case "":
+		return NonFiniteReject, nil
+	case NonFiniteReject, NonFiniteDrop, NonFiniteClamp:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown non-finite policy %q (supported: reject, drop, clamp)", name)
+	}
+}
+
+// NonFiniteStats counts the values changed by a NonFinitePolicy
+type NonFiniteStats struct {
+	Dropped int
+	Clamped int
+}
+
+// NonFiniteError reports a NaN or ±Inf value rejected by NonFiniteReject
+type NonFiniteError struct {
+	Index int
+	Value float64
+}
+
+func (e *NonFiniteError) Error() string {
+	return fmt.Sprintf("non-finite value %v at index %d", e.Value, e.Index)
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// ApplyNonFinitePolicy returns values with NaN and ±Inf handled according to
+// policy. The input slice is not modified; when it holds only finite values it
+// is returned as is.
+func ApplyNonFinitePolicy(values []float64, policy NonFinitePolicy) ([]float64, NonFiniteStats, error) {
+	var stats NonFiniteStats
+
+	first := -1
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for i, v := range values {
+		if !isFinite(v) {
+			if first < 0 {
+				first = i
+			}
+			continue
+		}
+		minValue = math.Min(minValue, v)
+		maxValue = math.Max(maxValue, v)
+	}
+	if first < 0 {
+		return values, stats, nil
+	}
+
+	switch policy {
+	case "", NonFiniteReject:
+		return nil, stats, &NonFiniteError{Index: first, Value: values[first]}
+	case NonFiniteDrop, NonFiniteClamp:
+	default:
+		return nil, stats, fmt.Errorf("unknown non-finite policy %q (supported: reject, drop, clamp)", policy)
+	}
+
+	// Clamping needs at least one finite value to clamp to
+	clamp := policy == NonFiniteClamp && !math.IsInf(minValue, 1)
+
+	out := make([]float64, 0, len(values))
+	for _, v := range values {
+		switch {
+		case isFinite(v):
+			out = append(out, v)
+		case clamp && math.IsInf(v, 1):
+			out = append(out, maxValue)
+			stats.Clamped++
+		case clamp && math.IsInf(v, -1):
+			out = append(out, minValue)
+			stats.Clamped++
+		default:
+			stats.Dropped++
+		}
+	}
+
+	return out, stats, nil
+}
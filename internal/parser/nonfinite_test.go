@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestParseNonFinitePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    NonFinitePolicy
+		wantErr bool
+	}{
+		{name: "", want: NonFiniteReject},
+		{name: "reject", want: NonFiniteReject},
+		{name: "DROP", want: NonFiniteDrop},
+		{name: " clamp ", want: NonFiniteClamp},
+		{name: "ignore", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseNonFinitePolicy(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseNonFinitePolicy(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseNonFinitePolicy(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyNonFinitePolicy(t *testing.T) {
+	nan, posInf, negInf := math.NaN(), math.Inf(1), math.Inf(-1)
+
+	tests := []struct {
+		name   string
+		values []float64
+		policy NonFinitePolicy
+		want   []float64
+		stats  NonFiniteStats
+	}{
+		{name: "finite values untouched", values: []float64{3, 1, 2}, policy: NonFiniteReject, want: []float64{3, 1, 2}},
+		{name: "drop", values: []float64{1, nan, 2, posInf, negInf}, policy: NonFiniteDrop, want: []float64{1, 2}, stats: NonFiniteStats{Dropped: 3}},
+		{name: "clamp", values: []float64{5, posInf, 1, negInf, nan, 3}, policy: NonFiniteClamp, want: []float64{5, 5, 1, 1, 3}, stats: NonFiniteStats{Dropped: 1, Clamped: 2}},
+		{name: "clamp without finite values", values: []float64{posInf, negInf}, policy: NonFiniteClamp, want: []float64{}, stats: NonFiniteStats{Dropped: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, stats, err := ApplyNonFinitePolicy(tt.values, tt.policy)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+			if stats != tt.stats {
+				t.Errorf("expected stats %+v, got %+v", tt.stats, stats)
+			}
+		})
+	}
+}
+
+func TestApplyNonFinitePolicy_Reject(t *testing.T) {
+	_, _, err := ApplyNonFinitePolicy([]float64{1, 2, math.Inf(1), math.NaN()}, NonFiniteReject)
+
+	var nfErr *NonFiniteError
+	if !errors.As(err, &nfErr) {
+		t.Fatalf("expected NonFiniteError, got %v", err)
+	}
+	if nfErr.Index != 2 || !math.IsInf(nfErr.Value, 1) {
+		t.Errorf("expected +Inf at index 2, got %v at index %d", nfErr.Value, nfErr.Index)
+	}
+}
+
+func TestApplyNonFinitePolicy_UnknownPolicy(t *testing.T) {
+	if _, _, err := ApplyNonFinitePolicy([]float64{math.NaN()}, "ignore"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
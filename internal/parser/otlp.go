@@ -0,0 +1,253 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FormatOTLP is the format name for OpenTelemetry (OTLP) trace files in the
+// protobuf JSON encoding, as written by the collector's file exporter
+const FormatOTLP = "otlp"
+
+// Span status filters, matching the OTLP status codes
+const (
+	StatusUnset = "unset"
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+var otlpStatuses = []string{StatusUnset, StatusOK, StatusError}
+
+// otlpServiceName is the resource attribute naming the service of its spans
+const otlpServiceName = "service.name"
+
+// OTLPOptions filters the spans read from OTLP trace files
+type OTLPOptions struct {
+	// Attributes restricts spans to those with every attribute given as
+	// "key=value", or just "key" to require its presence. Resource attributes
+	// such as service.name apply to all of the resource's spans.
+	Attributes []string
+
+	// Status restricts spans to a status code: unset, ok or error
+	Status string
+}
+
+// otlpFilter is the parsed form of OTLPOptions
+type otlpFilter struct {
+	attrs  []otlpAttrFilter
+	status string
+}
+
+type otlpAttrFilter struct {
+	key, value string
+	any        bool
+}
+
+func (o OTLPOptions) filter() (otlpFilter, error) {
+	var f otlpFilter
+	for _, attr := range o.Attributes {
+		key, value, found := strings.Cut(attr, "=")
+		if key == "" {
+			return otlpFilter{}, fmt.Errorf("invalid span attribute filter %q (expected key=value)", attr)
+		}
+		f.attrs = append(f.attrs, otlpAttrFilter{key: key, value: value, any: !found})
+	}
+
+	f.status = strings.ToLower(o.Status)
+	if f.status != "" && !slices.Contains(otlpStatuses, f.status) {
+		return otlpFilter{}, fmt.Errorf("unknown span status %q (supported: %s)", o.Status, strings.Join(otlpStatuses, ", "))
+	}
+	return f, nil
+}
+
+// matches reports whether a span passes the filters, looking attributes up on
+// the span before its resource
+func (f otlpFilter) matches(span otlpSpan, resource otlpAttributes) bool {
+	if f.status != "" && span.Status.Code.name() != f.status {
+		return false
+	}
+	for _, attr := range f.attrs {
+		value, ok := span.Attributes.get(attr.key)
+		if !ok {
+			value, ok = resource.get(attr.key)
+		}
+		if !ok || (!attr.any && value != attr.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// otlpTracesData is an ExportTraceServiceRequest, the top-level object of
+// OTLP trace files
+type otlpTracesData struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes otlpAttributes `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []otlpSpan `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type otlpSpan struct {
+	TraceID    string         `json:"traceId"`
+	SpanID     string         `json:"spanId"`
+	Name       string         `json:"name"`
+	Start      otlpNanos      `json:"startTimeUnixNano"`
+	End        otlpNanos      `json:"endTimeUnixNano"`
+	Attributes otlpAttributes `json:"attributes"`
+	Status     struct {
+		Code otlpStatusCode `json:"code"`
+	} `json:"status"`
+}
+
+// otlpNanos is a fixed64 timestamp, encoded as a decimal string or a number
+type otlpNanos uint64
+
+func (n *otlpNanos) UnmarshalJSON(data []byte) error {
+	s := string(bytes.Trim(data, `"`))
+	if s == "" || s == "null" {
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %s", data)
+	}
+	*n = otlpNanos(v)
+	return nil
+}
+
+// otlpStatusCode is a span status code, encoded as a number or as its enum
+// name, e.g. "STATUS_CODE_ERROR"
+type otlpStatusCode string
+
+func (c *otlpStatusCode) UnmarshalJSON(data []byte) error {
+	*c = otlpStatusCode(bytes.Trim(data, `"`))
+	return nil
+}
+
+// name returns the status filter matching the code
+func (c otlpStatusCode) name() string {
+	switch strings.TrimPrefix(strings.ToUpper(string(c)), "STATUS_CODE_") {
+	case "1", "OK":
+		return StatusOK
+	case "2", "ERROR":
+		return StatusError
+	}
+	return StatusUnset
+}
+
+// otlpAttributes is a list of key/value attributes. Values are AnyValue
+// objects such as {"stringValue": "GET"} or {"intValue": "200"}.
+type otlpAttributes []struct {
+	Key   string                     `json:"key"`
+	Value map[string]json.RawMessage `json:"value"`
+}
+
+// get returns the value of an attribute as a string
+func (a otlpAttributes) get(key string) (string, bool) {
+	for _, attr := range a {
+		if attr.Key != key {
+			continue
+		}
+		for _, raw := range attr.Value {
+			var s string
+			if err := json.Unmarshal(raw, &s); err == nil {
+				return s, true
+			}
+			return string(raw), true
+		}
+		return "", true
+	}
+	return "", false
+}
+
+type otlpFormat struct{}
+
+func (otlpFormat) Name() string         { return FormatOTLP }
+func (otlpFormat) Extensions() []string { return nil }
+func (otlpFormat) MIMETypes() []string  { return nil }
+
+// Sniff detects a JSON object holding resourceSpans
+func (otlpFormat) Sniff(prefix []byte, _ Options) bool {
+	trimmed := sniffTrim(prefix)
+	return len(trimmed) > 0 && trimmed[0] == '{' && bytes.Contains(trimmed, []byte(`"resourceSpans"`))
+}
+
+// Decode reads the duration of every span passing the filters, in
+// milliseconds, grouped by service and span name (the default), service or
+// span name and named after the trace and span IDs. The collector's file
+// exporter writes one object per line, so concatenated objects are read in
+// turn.
+func (otlpFormat) Decode(r io.Reader, dc *DecodeContext) ([]float64, error) {
+	filter, err := dc.Options.OTLP.filter()
+	if err != nil {
+		return nil, err
+	}
+	key, err := groupKey(dc.Options, FormatOTLP, "span", "service", "name")
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(r)
+	var values []float64
+	var labels, names []string
+	for {
+		var traces otlpTracesData
+		if err := dec.Decode(&traces); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse OTLP traces: %w", err)
+		}
+
+		for _, rs := range traces.ResourceSpans {
+			service, _ := rs.Resource.Attributes.get(otlpServiceName)
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					if !filter.matches(span, rs.Resource.Attributes) {
+						continue
+					}
+					if span.Start == 0 || span.End == 0 || span.End < span.Start {
+						value := fmt.Sprintf("start=%d end=%d", span.Start, span.End)
+						if err := dc.Skip(0, span.TraceID+"/"+span.SpanID, value, "missing or inverted timestamps"); err != nil {
+							return nil, fmt.Errorf("invalid OTLP span: %w", err)
+						}
+						continue
+					}
+					value, err := dc.Values.Convert(float64(span.End-span.Start)/1e6, "ms")
+					if err != nil {
+						return nil, err
+					}
+
+					if err := dc.rep.count(1); err != nil {
+						return nil, err
+					}
+					values = append(values, value)
+					names = append(names, span.TraceID+"/"+span.SpanID)
+					switch key {
+					case "span":
+						labels = append(labels, service+"/"+span.Name)
+					case "service":
+						labels = append(labels, service)
+					case "name":
+						labels = append(labels, span.Name)
+					}
+				}
+			}
+		}
+	}
+
+	if key != GroupNone {
+		dc.SetGroups(labels)
+		dc.SetNames(names)
+	}
+	return values, nil
+}
@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// otlpFile holds two exports as written by the collector's file exporter, one
+// per line, mixing string and numeric timestamps and status codes
+const otlpFile = `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"checkout"}}]},"scopeSpans":[{"scope":{"name":"net/http"},"spans":[` +
+	`{"traceId":"t1","spanId":"a","name":"GET /cart","startTimeUnixNano":"1700000000000000000","endTimeUnixNano":"1700000000120000000","attributes":[{"key":"http.response.status_code","value":{"intValue":"200"}}],"status":{}},` +
+	`{"traceId":"t1","spanId":"b","name":"GET /cart","startTimeUnixNano":"1700000001000000000","endTimeUnixNano":"1700000001080000000","attributes":[{"key":"http.response.status_code","value":{"intValue":"200"}}],"status":{"code":1}},` +
+	`{"traceId":"t2","spanId":"c","name":"POST /pay","startTimeUnixNano":1700000002000000000,"endTimeUnixNano":1700000002500000000,"attributes":[{"key":"http.response.status_code","value":{"intValue":500}}],"status":{"code":"STATUS_CODE_ERROR"}}` +
+	`]}]}]}
+{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"inventory"}}]},"scopeSpans":[{"spans":[` +
+	`{"traceId":"t1","spanId":"d","name":"SELECT items","startTimeUnixNano":"1700000000010000000","endTimeUnixNano":"1700000000040000000","attributes":[{"key":"db.system","value":{"stringValue":"postgresql"}}],"status":{"code":2}}` +
+	`]}]}]}
+`
+
+func TestDecode_OTLP(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       Options
+		want       []float64
+		wantGroups []string
+	}{
+		{name: "durations by service and span", want: []float64{120, 80, 500, 30}, wantGroups: []string{"checkout/GET /cart", "checkout/POST /pay", "inventory/SELECT items"}},
+		{name: "by service", opts: Options{GroupBy: "service"}, want: []float64{120, 80, 500, 30}, wantGroups: []string{"checkout", "inventory"}},
+		{name: "by span name", opts: Options{GroupBy: "name"}, want: []float64{120, 80, 500, 30}, wantGroups: []string{"GET /cart", "POST /pay", "SELECT items"}},
+		{name: "error status", opts: Options{OTLP: OTLPOptions{Status: "ERROR"}}, want: []float64{500, 30}, wantGroups: []string{"checkout/POST /pay", "inventory/SELECT items"}},
+		{name: "unset status", opts: Options{OTLP: OTLPOptions{Status: "unset"}}, want: []float64{120}, wantGroups: []string{"checkout/GET /cart"}},
+		{name: "span attribute", opts: Options{OTLP: OTLPOptions{Attributes: []string{"http.response.status_code=200"}}}, want: []float64{120, 80}, wantGroups: []string{"checkout/GET /cart"}},
+		{name: "numeric attribute", opts: Options{OTLP: OTLPOptions{Attributes: []string{"http.response.status_code=500"}}}, want: []float64{500}, wantGroups: []string{"checkout/POST /pay"}},
+		{name: "attribute presence", opts: Options{OTLP: OTLPOptions{Attributes: []string{"db.system"}}}, want: []float64{30}, wantGroups: []string{"inventory/SELECT items"}},
+		{name: "resource attribute", opts: Options{OTLP: OTLPOptions{Attributes: []string{"service.name=inventory"}}}, want: []float64{30}, wantGroups: []string{"inventory/SELECT items"}},
+		{name: "ungrouped in seconds", opts: Options{GroupBy: GroupNone, Unit: "s"}, want: []float64{0.12, 0.08, 0.5, 0.03}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Decode(strings.NewReader(otlpFile), "traces.json", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Format != FormatOTLP {
+				t.Errorf("expected format %q, got %q", FormatOTLP, res.Format)
+			}
+			if !slices.EqualFunc(res.Values, tt.want, func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }) {
+				t.Errorf("expected %v, got %v", tt.want, res.Values)
+			}
+			var groups []string
+			for _, g := range res.Groups {
+				groups = append(groups, g.Name)
+			}
+			if !slices.Equal(groups, tt.wantGroups) {
+				t.Errorf("expected groups %v, got %v", tt.wantGroups, groups)
+			}
+		})
+	}
+}
+
+func TestDecode_OTLPSniffed(t *testing.T) {
+	res, err := Decode(strings.NewReader(otlpFile), "", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Format != FormatOTLP || res.Unit != "ms" {
+		t.Errorf("expected OTLP in ms, got %s in %s", res.Format, res.Unit)
+	}
+	if names := res.Groups[0].Names; !slices.Equal(names, []string{"t1/a", "t1/b"}) {
+		t.Errorf("expected trace and span IDs as names, got %v", names)
+	}
+}
+
+func TestReadValuesFromFile_OTLP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	if err := os.WriteFile(path, []byte(otlpFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := ReadValuesFromFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(values) != 4 {
+		t.Errorf("expected 4 span durations, got %v", values)
+	}
+}
+
+func TestDecode_OTLPLenient(t *testing.T) {
+	data := `{"resourceSpans":[{"scopeSpans":[{"spans":[` +
+		`{"traceId":"t","spanId":"ok","name":"op","startTimeUnixNano":"1000000","endTimeUnixNano":"3000000"},` +
+		`{"traceId":"t","spanId":"open","name":"op","startTimeUnixNano":"1000000"},` +
+		`{"traceId":"t","spanId":"inverted","name":"op","startTimeUnixNano":"3000000","endTimeUnixNano":"1000000"}` +
+		`]}]}]}`
+
+	if _, err := Decode(strings.NewReader(data), "", Options{}); err == nil {
+		t.Error("expected strict mode to fail on a span without an end time")
+	}
+
+	res, err := Decode(strings.NewReader(data), "", Options{Mode: ModeLenient})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(res.Values, []float64{2}) || res.Skipped != 2 {
+		t.Errorf("expected one 2ms span and two skipped, got %v with %d skipped", res.Values, res.Skipped)
+	}
+	if res.Errors[0].Column != "t/open" {
+		t.Errorf("expected the span to be identified, got %+v", res.Errors[0])
+	}
+}
+
+func TestDecode_OTLPErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		opts Options
+	}{
+		{name: "unknown status", data: otlpFile, opts: Options{OTLP: OTLPOptions{Status: "failed"}}},
+		{name: "empty attribute key", data: otlpFile, opts: Options{OTLP: OTLPOptions{Attributes: []string{"=x"}}}},
+		{name: "unknown grouping", data: otlpFile, opts: Options{GroupBy: "host"}},
+		{name: "malformed", data: `{"resourceSpans":[{"scopeSpans":[`},
+		{name: "invalid timestamp", data: `{"resourceSpans":[{"scopeSpans":[{"spans":[{"startTimeUnixNano":"soon"}]}]}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(strings.NewReader(tt.data), "", tt.opts); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetMagic opens (and closes) every Parquet file
+var parquetMagic = []byte("PAR1")
+
+// parquetValueBufferSize is the number of values decoded per page read
+const parquetValueBufferSize = 1024
+
+// ReadParquetBytes reads the named numeric column from Parquet data in a byte slice
+func ReadParquetBytes(data []byte, column string) ([]float64, error) {
+	return readParquet(bytes.NewReader(data), int64(len(data)), column, strictReport())
+}
+
+// readParquet reads a numeric column from a Parquet file one row group and page
+// at a time. Nested columns are addressed with dot-separated paths such as
+// "timings.total". Null values are skipped.
+func readParquet(r io.ReaderAt, size int64, column string, rep *report) ([]float64, error) {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Parquet file: %w", err)
+	}
+
+	leaf, ok := file.Schema().Lookup(strings.Split(column, ".")...)
+	if !ok {
+		return nil, fmt.Errorf("no column %q in Parquet file", column)
+	}
+
+	switch kind := leaf.Node.Type().Kind(); kind {
+	case parquet.Int32, parquet.Int64, parquet.Float, parquet.Double:
+	default:
+		return nil, fmt.Errorf("unsupported type %s for Parquet column %q (supported: int32, int64, float, double)", kind, column)
+	}
+
+	// The row count comes from the file, so preallocate no more than allowed
+	rows := file.NumRows()
+	if rep.maxValues > 0 {
+		rows = min(rows, int64(rep.maxValues))
+	}
+	values := make([]float64, 0, rows)
+	buf := make([]parquet.Value, parquetValueBufferSize)
+
+	for _, rowGroup := range file.RowGroups() {
+		pages := rowGroup.ColumnChunks()[leaf.ColumnIndex].Pages()
+		values, err = readParquetPages(pages, buf, values, rep)
+		pages.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Parquet column %q: %w", column, err)
+		}
+	}
+
+	return values, nil
+}
+
+// readParquetPages appends the non-null values of every page to values
+func readParquetPages(pages parquet.Pages, buf []parquet.Value, values []float64, rep *report) ([]float64, error) {
+	for {
+		page, err := pages.ReadPage()
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		n := len(values)
+		values, err = appendParquetValues(page.Values(), buf, values)
+		parquet.Release(page)
+		if err != nil {
+			return nil, err
+		}
+		if err := rep.count(len(values) - n); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func appendParquetValues(reader parquet.ValueReader, buf []parquet.Value, values []float64) ([]float64, error) {
+	for {
+		n, err := reader.ReadValues(buf)
+		for _, v := range buf[:n] {
+			switch {
+			case v.IsNull():
+			case v.Kind() == parquet.Int32:
+				values = append(values, float64(v.Int32()))
+			case v.Kind() == parquet.Int64:
+				values = append(values, float64(v.Int64()))
+			case v.Kind() == parquet.Float:
+				values = append(values, float64(v.Float()))
+			case v.Kind() == parquet.Double:
+				values = append(values, v.Double())
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parquetSource returns random access to a Parquet input. Parquet keeps its
+// metadata in a footer, so uncompressed files and uploads that support
+// io.ReaderAt are read in place while other streams are buffered in memory,
+// up to limit bytes unless limit is negative.
+func parquetSource(raw io.Reader, decoded io.Reader, codec Compression, limit int64) (io.ReaderAt, int64, error) {
+	if ra, ok := raw.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok && codec == CompressionNone {
+		size, err := ra.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to determine Parquet file size: %w", err)
+		}
+		return ra, size, nil
+	}
+
+	// Compressed streams are already capped as they are decompressed
+	if codec == CompressionNone && limit >= 0 {
+		decoded = &limitedReadCloser{rc: io.NopCloser(decoded), remaining: limit, limit: limit}
+	}
+	data, err := io.ReadAll(decoded)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read Parquet input: %w", err)
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type parquetTestRow struct {
+	Value   float64 `parquet:"value"`
+	Latency *int64  `parquet:"latency,optional"`
+	Count   int32   `parquet:"count"`
+	Ratio   float32 `parquet:"ratio"`
+	Host    string  `parquet:"host"`
+	Timings struct {
+		Total float64 `parquet:"total"`
+	} `parquet:"timings"`
+}
+
+func parquetTestData(t *testing.T) []byte {
+	t.Helper()
+
+	latency := func(v int64) *int64 { return &v }
+	rows := []parquetTestRow{
+		{Value: 1.5, Latency: latency(10), Count: 1, Ratio: 0.5, Host: "a"},
+		{Value: 2.5, Latency: nil, Count: 2, Ratio: 0.25, Host: "b"},
+		{Value: 3.5, Latency: latency(30), Count: 3, Ratio: 0.125, Host: "c"},
+		{Value: 4.5, Latency: latency(40), Count: 4, Ratio: 1, Host: "d"},
+		{Value: 5.5, Latency: nil, Count: 5, Ratio: 2, Host: "e"},
+	}
+	for i := range rows {
+		rows[i].Timings.Total = float64(i * 100)
+	}
+
+	var buf bytes.Buffer
+	// Small row groups exercise reading across row group boundaries
+	if err := parquet.Write(&buf, rows, parquet.MaxRowsPerRowGroup(2)); err != nil {
+		t.Fatalf("failed to write Parquet data: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadParquetBytes(t *testing.T) {
+	data := parquetTestData(t)
+
+	tests := []struct {
+		name    string
+		column  string
+		want    []float64
+		wantErr bool
+	}{
+		{name: "double column", column: "value", want: []float64{1.5, 2.5, 3.5, 4.5, 5.5}},
+		{name: "nullable int64 column", column: "latency", want: []float64{10, 30, 40}},
+		{name: "int32 column", column: "count", want: []float64{1, 2, 3, 4, 5}},
+		{name: "float column", column: "ratio", want: []float64{0.5, 0.25, 0.125, 1, 2}},
+		{name: "nested column", column: "timings.total", want: []float64{0, 100, 200, 300, 400}},
+		{name: "missing column", column: "missing", wantErr: true},
+		{name: "string column", column: "host", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadParquetBytes(data, tt.column)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReadParquetBytes_Invalid(t *testing.T) {
+	if _, err := ReadParquetBytes([]byte("PAR1 not really parquet"), "value"); err == nil {
+		t.Error("expected error for invalid Parquet data, got nil")
+	}
+}
+
+func TestReadParquetFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "extract.parquet")
+	if err := os.WriteFile(path, parquetTestData(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := ReadParquetFile(path, "count")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(values, []float64{1, 2, 3, 4, 5}) {
+		t.Errorf("expected [1 2 3 4 5], got %v", values)
+	}
+
+	if _, err := ReadParquetFile(filepath.Join(tmpDir, "missing.parquet"), "value"); err == nil {
+		t.Error("expected error for missing file, got nil")
+	}
+}
+
+func TestDecode_Parquet(t *testing.T) {
+	data := parquetTestData(t)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		filename string
+		column   string
+		want     []float64
+	}{
+		{name: "by extension", data: data, filename: "extract.parquet", want: []float64{1.5, 2.5, 3.5, 4.5, 5.5}},
+		{name: "sniffed from magic bytes", data: data, filename: "blob", column: "latency", want: []float64{10, 30, 40}},
+		{name: "gzip compressed", data: gzipBytes(t, data), filename: "extract.parquet.gz", column: "count", want: []float64{1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Decode(bytes.NewReader(tt.data), tt.filename, Options{Column: tt.column})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Format != FormatParquet {
+				t.Errorf("expected format %q, got %q", FormatParquet, res.Format)
+			}
+			if !slices.Equal(res.Values, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, res.Values)
+			}
+		})
+	}
+}
+
+func TestDecode_ParquetFromStream(t *testing.T) {
+	// A plain io.Reader without random access is buffered before decoding
+	r := struct{ *bytes.Buffer }{bytes.NewBuffer(parquetTestData(t))}
+
+	res, err := Decode(r, "", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res.Values) != 5 {
+		t.Errorf("expected 5 values, got %d", len(res.Values))
+	}
+}
+
+func TestDecode_ParquetFromStreamTooLarge(t *testing.T) {
+	data := parquetTestData(t)
+	stream := func() io.Reader { return struct{ *bytes.Buffer }{bytes.NewBuffer(data)} }
+
+	_, err := Decode(stream(), "extract.parquet", Options{MaxDecompressedSize: int64(len(data) - 1)})
+	if !errors.Is(err, ErrDecompressedSizeExceeded) {
+		t.Fatalf("expected ErrDecompressedSizeExceeded, got %v", err)
+	}
+
+	// Inputs read in place are not buffered, so the limit does not apply
+	if _, err := Decode(bytes.NewReader(data), "extract.parquet", Options{MaxDecompressedSize: 1}); err != nil {
+		t.Errorf("unexpected error for a seekable input: %v", err)
+	}
+	if _, err := Decode(stream(), "extract.parquet", Options{MaxDecompressedSize: int64(len(data))}); err != nil {
+		t.Errorf("unexpected error at the limit: %v", err)
+	}
+}
+
+func TestDecode_ParquetMaxValues(t *testing.T) {
+	data := parquetTestData(t)
+	if _, err := Decode(bytes.NewReader(data), "extract.parquet", Options{MaxValues: 4}); !errors.Is(err, ErrTooManyValues) {
+		t.Errorf("expected ErrTooManyValues, got %v", err)
+	}
+	// Nulls are not counted
+	if _, err := Decode(bytes.NewReader(data), "extract.parquet", Options{Column: "latency", MaxValues: 3}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDecode_CSVColumn(t *testing.T) {
+	res, err := Decode(bytes.NewReader([]byte("host,latency_ms\na,12\nb,15\n")), "data.csv", Options{Column: "latency_ms"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(res.Values, []float64{12, 15}) {
+		t.Errorf("expected [12 15], got %v", res.Values)
+	}
+}
@@ -1,43 +1,157 @@
 package parser
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
+	"sync/atomic"
 )
 
-// ReadValuesFromFile reads values from a file based on its extension
+// Options controls how input data is decoded
+type Options struct {
+	// MaxDecompressedSize caps the number of bytes read from a compressed input
+	// and buffered from a Parquet stream without random access. Zero means
+	// DefaultMaxDecompressedSize; a negative value disables the cap.
+	MaxDecompressedSize int64
+
+	// ContentType is an optional MIME type hint (e.g. from a multipart part
+	// header) used when the filename extension does not identify the format
+	ContentType string
+
+	// Column names the CSV or Parquet column holding values. Nested Parquet
+	// columns use dot-separated paths. Defaults to "value".
+	Column string
+
+	// AccessLog selects web-server access log parsing when its Format or
+	// Pattern is set, overriding format detection
+	AccessLog AccessLogOptions
+
+	// HAR selects the timing phase and filters for HAR files
+	HAR HAROptions
+
+	// OTLP filters the spans of OTLP trace files
+	OTLP OTLPOptions
+
+	// Unit converts values with duration or byte-size suffixes (e.g. "120ms",
+	// "3KiB") into this unit. When empty, the unit of the first suffixed value
+	// is used.
+	Unit string
+
+	// Mode is ModeStrict (the default), which fails on the first invalid
+	// record, or ModeLenient, which skips invalid records and reports them
+	Mode string
+
+	// MaxErrors caps the record errors kept in a lenient report. Defaults to
+	// DefaultMaxErrors; negative keeps every error.
+	MaxErrors int
+
+	// MaxValues stops decoding with ErrTooManyValues once more values than
+	// this have been read; zero is unlimited. DecodeSources applies it to
+	// all sources together.
+	MaxValues int
+
+	// decoded counts the values read by the decodes sharing MaxValues
+	decoded *atomic.Int64
+
+	// NonFinite decides how NaN and ±Inf values are handled. Defaults to
+	// NonFiniteReject.
+	NonFinite NonFinitePolicy
+
+	// Locale describes localized number formats such as "1.234,56" in CSV
+	// cells, JSON strings and plain text
+	Locale Locale
+
+	// Metric selects the Go benchmark metric to read, such as "B/op",
+	// "allocs/op" or a custom metric. Defaults to "ns/op".
+	Metric string
+
+	// GroupBy selects the label grouping values into Result.Groups for
+	// formats that label them, such as "suite" or "class" for JUnit reports.
+	// Empty uses the format's default; GroupNone disables grouping.
+	GroupBy string
+}
+
+func (o Options) maxDecompressedSize() int64 {
+	if o.MaxDecompressedSize == 0 {
+		return DefaultMaxDecompressedSize
+	}
+	return o.MaxDecompressedSize
+}
+
+// Result holds the values decoded from an input along with the detected format
+// and the unit values were converted into, if any
+type Result struct {
+	Values []float64
+	Format string
+	Unit   string
+	// Source names the input for results of DecodeSources and DecodeFiles
+	Source string
+
+	// Skipped counts invalid records skipped in lenient mode, of which at most
+	// Options.MaxErrors are described in Errors
+	Skipped int
+	Errors  []RecordError
+
+	// NonFinite counts NaN and ±Inf values dropped or clamped by
+	// Options.NonFinite
+	NonFinite NonFiniteStats
+
+	// Groups splits Values into named series for formats that label them,
+	// such as the benchmarks of Go benchmark output
+	Groups []Group
+}
+
+// DefaultColumn is the CSV and Parquet column read when Options.Column is empty
+const DefaultColumn = "value"
+
+func (o Options) column() string {
+	if o.Column == "" {
+		return DefaultColumn
+	}
+	return o.Column
+}
+
+// ReadValuesFromFile reads values from a file, detecting the format from its
+// extension or content
 func ReadValuesFromFile(path string) ([]float64, error) {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".json":
-		return ReadJSONFile(path)
-	case ".csv":
-		return ReadCSVFile(path)
-	default:
-		return nil, fmt.Errorf("unsupported file format: %s (supported: .json, .csv)", ext)
+	return ReadValuesFromFileWithOptions(path, Options{})
+}
+
+// ReadValuesFromFileWithOptions reads values from a file, see Decode
+func ReadValuesFromFileWithOptions(path string, opts Options) ([]float64, error) {
+	res, err := DecodeFile(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return res.Values, nil
+}
+
+// DecodeFile reads values and input metadata from a file, see Decode
+func DecodeFile(path string, opts Options) (*Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
+	defer file.Close()
+
+	return Decode(file, path, opts)
 }
 
 // ReadJSONFile reads a JSON file containing an array of numbers
 func ReadJSONFile(path string) ([]float64, error) {
-	data, err := os.ReadFile(path)
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read JSON file: %w", err)
 	}
+	defer file.Close()
 
-	var values []float64
-	if err := json.Unmarshal(data, &values); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
-	}
-
-	return values, nil
+	return readJSONFromReader(file, &ValueParser{}, strictReport())
 }
 
 // ReadCSVFile reads a CSV file with a "value" column
@@ -51,53 +165,336 @@ func ReadCSVFile(path string) ([]float64, error) {
 	return readCSVFromReader(csv.NewReader(file))
 }
 
-// ReadValuesFromBytes reads values from a byte slice based on the filename extension
+// ReadParquetFile reads the named numeric column from a Parquet file
+func ReadParquetFile(path, column string) ([]float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Parquet file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat Parquet file: %w", err)
+	}
+
+	return readParquet(file, info.Size(), column, strictReport())
+}
+
+// ReadTextFile reads a plain-text file of whitespace-separated numbers
+func ReadTextFile(path string) ([]float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open text file: %w", err)
+	}
+	defer file.Close()
+
+	return readTextFromReader(file, &ValueParser{}, strictReport())
+}
+
+// ReadAccessLogFile reads values from the lines of a web-server access log
+func ReadAccessLogFile(path string, opts AccessLogOptions) ([]float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open access log: %w", err)
+	}
+	defer file.Close()
+
+	return readAccessLog(file, opts, &ValueParser{}, strictReport())
+}
+
+// ReadValuesFromBytes reads values from a byte slice, see Decode
 func ReadValuesFromBytes(data []byte, filename string) ([]float64, error) {
-	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	case ".json":
-		return ReadJSONBytes(data)
-	case ".csv":
-		return ReadCSVBytes(data)
-	default:
-		return nil, fmt.Errorf("unsupported file format: %s (supported: .json, .csv)", ext)
+	return ReadValuesFromReader(bytes.NewReader(data), filename, Options{})
+}
+
+// ReadValuesFromReader reads values from a stream, see Decode
+func ReadValuesFromReader(r io.Reader, filename string, opts Options) ([]float64, error) {
+	res, err := Decode(r, filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	return res.Values, nil
+}
+
+// FormatError reports a failure to decode an input of a detected format
+type FormatError struct {
+	Format string
+	Err    error
+}
+
+func (e *FormatError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// Decode reads values from a stream. Compressed streams are detected by magic
+// bytes or a double extension such as ".csv.gz" and decoded on the fly. The
+// format is taken from the filename extension or opts.ContentType when either
+// names a registered format, and otherwise detected from the content itself.
+// Inputs named ".txt" or typed text/plain that cannot be classified are read as
+// plain text. Access logs are never detected and must be selected via
+// opts.AccessLog. Failures past format detection are returned as a
+// *FormatError.
+func Decode(r io.Reader, filename string, opts Options) (*Result, error) {
+	rc, codec, err := decompress(r, filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	br := bufio.NewReaderSize(rc, sniffLen)
+	if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		_, _ = br.Discard(3)
+	}
+
+	format := formatHint(filename, opts.ContentType)
+	if opts.AccessLog.enabled() {
+		format = FormatAccessLog
+	}
+	if err := opts.Locale.validate(); err != nil {
+		return nil, err
+	}
+	if format != FormatAccessLog {
+		prefix, err := br.Peek(sniffLen)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, fmt.Errorf("failed to read input: %w", err)
+		}
+		format = detectFormat(format, prefix, opts)
+	}
+	if format == "" {
+		format = formatFallback(filename, opts.ContentType)
+	}
+
+	vp, err := NewValueParser(opts.Unit)
+	if err != nil {
+		return nil, err
+	}
+	vp.WithLocale(opts.Locale)
+	rep, err := newReport(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := LookupFormat(format)
+	if !ok {
+		return nil, fmt.Errorf("unsupported file format: %s (supported: %s)", formatExt(filename), formatNames())
+	}
+	dc := &DecodeContext{Options: opts, Values: vp, rep: rep, raw: r, codec: codec}
+	values, err := f.Decode(br, dc)
+	if err == nil {
+		// Registered formats do not count their values as they go
+		err = rep.count(len(values) - rep.counted)
+	}
+	if err != nil {
+		return nil, &FormatError{Format: format, Err: err}
+	}
+	// Rejected values are reported by their index in the whole input rather
+	// than in their group
+	pooled, stats, err := ApplyNonFinitePolicy(values, opts.NonFinite)
+	if err != nil {
+		return nil, &FormatError{Format: format, Err: fmt.Errorf("input contains %w", err)}
 	}
+	var groups []Group
+	if dc.labels != nil {
+		if groups, err = groupValues(values, dc.labels, dc.names, opts.NonFinite); err != nil {
+			return nil, &FormatError{Format: format, Err: err}
+		}
+	}
+	values = pooled
+
+	return &Result{
+		Values:    values,
+		Format:    format,
+		Unit:      vp.Unit(),
+		Skipped:   rep.skipped,
+		Errors:    rep.errors,
+		NonFinite: stats,
+		Groups:    groups,
+	}, nil
 }
 
 // ReadJSONBytes reads JSON data from a byte slice
 func ReadJSONBytes(data []byte) ([]float64, error) {
-	var values []float64
-	if err := json.Unmarshal(data, &values); err != nil {
+	return readJSONFromReader(bytes.NewReader(data), &ValueParser{}, strictReport())
+}
+
+// ReadCSVBytes reads CSV data from a byte slice
+func ReadCSVBytes(data []byte) ([]float64, error) {
+	return readCSVFromReader(csv.NewReader(bytes.NewReader(data)))
+}
+
+// ReadTextBytes reads plain-text numbers from a byte slice
+func ReadTextBytes(data []byte) ([]float64, error) {
+	return readTextFromReader(bytes.NewReader(data), &ValueParser{}, strictReport())
+}
+
+// readJSONFromReader decodes a JSON array of numbers, or strings with units such
+// as "120ms", from a stream. Null elements are skipped.
+func readJSONFromReader(r io.Reader, vp *ValueParser, rep *report) ([]float64, error) {
+	dec := json.NewDecoder(r)
+
+	var elements []json.RawMessage
+	if err := dec.Decode(&elements); err != nil {
 		return nil, fmt.Errorf("failed to parse JSON: %w", err)
 	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse JSON: unexpected data after array")
+	}
+
+	values := make([]float64, 0, len(elements))
+	for i, raw := range elements {
+		value, ok, err := parseJSONValue(raw, vp)
+		if err != nil {
+			if err := rep.add(0, fmt.Sprintf("$[%d]", i), string(raw), err.Error()); err != nil {
+				return nil, fmt.Errorf("invalid JSON element: %w", err)
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := rep.count(1); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
 	return values, nil
 }
 
-// ReadCSVBytes reads CSV data from a byte slice
-func ReadCSVBytes(data []byte) ([]float64, error) {
-	return readCSVFromReader(csv.NewReader(strings.NewReader(string(data))))
+// parseJSONValue converts a JSON number or string into a value. It reports
+// false for null.
+func parseJSONValue(raw json.RawMessage, vp *ValueParser) (float64, bool, error) {
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		return 0, false, nil
+	case bytes.HasPrefix(raw, []byte(`"`)):
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return 0, false, err
+		}
+		value, err := vp.Parse(strings.TrimSpace(s))
+		return value, err == nil, err
+	default:
+		var value float64
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return 0, false, err
+		}
+		return value, true, nil
+	}
+}
+
+// readNDJSONFromReader decodes newline-delimited JSON where each line is either
+// a number, a string with a unit, or an object with a "value" field holding
+// one. Blank lines and records with a null or missing value are skipped.
+func readNDJSONFromReader(r io.Reader, vp *ValueParser, rep *report) ([]float64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTextLineLength)
+
+	var values []float64
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		value, ok, err := parseNDJSONRecord(raw, vp)
+		if err != nil {
+			if err := rep.add(line, "", string(raw), err.Error()); err != nil {
+				return nil, fmt.Errorf("failed to parse NDJSON record: %w", err)
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := rep.count(1); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read NDJSON input: %w", err)
+	}
+
+	return values, nil
+}
+
+// parseNDJSONRecord converts one NDJSON record into a value. It reports false
+// for records with a null or missing value.
+func parseNDJSONRecord(raw []byte, vp *ValueParser) (float64, bool, error) {
+	if !json.Valid(raw) {
+		return 0, false, fmt.Errorf("invalid JSON")
+	}
+
+	if bytes.HasPrefix(raw, []byte("{")) {
+		var obj struct {
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return 0, false, err
+		}
+		if obj.Value == nil {
+			return 0, false, nil
+		}
+		raw = obj.Value
+	}
+
+	return parseJSONValue(raw, vp)
+}
+
+// newCSVReader creates a CSV reader using the delimiter detected from the
+// header line
+func newCSVReader(r io.Reader) *csv.Reader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReaderSize(r, sniffLen)
+	}
+	prefix, _ := br.Peek(sniffLen)
+	header, _, _ := bytes.Cut(prefix, []byte("\n"))
+
+	reader := csv.NewReader(br)
+	reader.Comma = csvDelimiter(string(header))
+	return reader
 }
 
 // readCSVFromReader reads values from a CSV reader that has a "value" column header
 func readCSVFromReader(reader *csv.Reader) ([]float64, error) {
+	return readCSVColumn(reader, DefaultColumn, &ValueParser{}, strictReport())
+}
+
+// readCSVColumn reads values from the named column of a CSV reader with a header
+// row. Empty cells are skipped; malformed rows, rows without the column and
+// cells that are not numbers are invalid records, as are rows with more or
+// fewer fields than the header in strict mode.
+func readCSVColumn(reader *csv.Reader, column string, vp *ValueParser, rep *report) ([]float64, error) {
+	// Row lengths are checked below, against the value column in lenient
+	// mode and the header in strict mode
+	reader.FieldsPerRecord = -1
+
 	// Read header
 	header, err := reader.Read()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CSV header: %w", err)
 	}
 
-	// Find "value" column index
+	// Find value column index
 	valueIndex := -1
 	for i, col := range header {
-		if strings.TrimSpace(strings.ToLower(col)) == "value" {
+		if strings.EqualFold(strings.TrimSpace(col), column) {
 			valueIndex = i
 			break
 		}
 	}
 
 	if valueIndex == -1 {
-		return nil, fmt.Errorf("CSV file must have a 'value' column")
+		return nil, fmt.Errorf("CSV file must have a '%s' column", column)
 	}
+	columnName := strings.TrimSpace(header[valueIndex])
 
 	// Read values
 	var values []float64
@@ -106,11 +503,29 @@ func readCSVFromReader(reader *csv.Reader) ([]float64, error) {
 		if errors.Is(err, io.EOF) {
 			break
 		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := rep.add(parseErr.Line, "", "", parseErr.Err.Error()); err != nil {
+				return nil, fmt.Errorf("failed to read CSV record: %w", err)
+			}
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to read CSV record: %w", err)
 		}
 
 		if valueIndex >= len(record) {
+			line, _ := reader.FieldPos(0)
+			if err := rep.add(line, columnName, "", fmt.Sprintf("row has %d fields, missing column", len(record))); err != nil {
+				return nil, fmt.Errorf("invalid CSV record: %w", err)
+			}
+			continue
+		}
+		if !rep.lenient && len(record) != len(header) {
+			line, _ := reader.FieldPos(0)
+			if err := rep.add(line, "", "", fmt.Sprintf("row has %d fields, header has %d", len(record), len(header))); err != nil {
+				return nil, fmt.Errorf("invalid CSV record: %w", err)
+			}
 			continue
 		}
 
@@ -119,11 +534,18 @@ func readCSVFromReader(reader *csv.Reader) ([]float64, error) {
 			continue
 		}
 
-		value, err := strconv.ParseFloat(valueStr, 64)
+		value, err := vp.Parse(valueStr)
 		if err != nil {
-			return nil, fmt.Errorf("invalid number in CSV: %s", valueStr)
+			line, _ := reader.FieldPos(valueIndex)
+			if err := rep.add(line, columnName, valueStr, err.Error()); err != nil {
+				return nil, fmt.Errorf("invalid CSV record: %w", err)
+			}
+			continue
 		}
 
+		if err := rep.count(1); err != nil {
+			return nil, err
+		}
 		values = append(values, value)
 	}
 
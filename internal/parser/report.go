@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Parse modes controlling how invalid records are handled
+const (
+	// ModeStrict fails on the first invalid record
+	ModeStrict = "strict"
+	// ModeLenient skips invalid records and reports them in Result.Errors
+	ModeLenient = "lenient"
+)
+
+// DefaultMaxErrors is the number of record errors kept in a lenient report when
+// Options.MaxErrors is zero
+const DefaultMaxErrors = 10
+
+// RecordError describes an input record that could not be parsed
+type RecordError struct {
+	// Line is the 1-based input line, or 0 when unknown (JSON arrays)
+	Line int
+	// Column is the CSV column name, the 1-based field of a text line, or the
+	// index of a JSON array element such as "$[3]"
+	Column string
+	// Value is the offending input, if any
+	Value string
+	// Reason explains why the record was rejected
+	Reason string
+	// Source names the input the record came from in merged results
+	Source string
+}
+
+func (e *RecordError) Error() string {
+	var parts []string
+	if e.Line > 0 {
+		parts = append(parts, fmt.Sprintf("line %d", e.Line))
+	}
+	if e.Column != "" {
+		parts = append(parts, fmt.Sprintf("column %q", e.Column))
+	}
+	msg := e.Reason
+	if len(parts) > 0 {
+		msg = strings.Join(parts, ", ") + ": " + e.Reason
+	}
+	if e.Source != "" {
+		msg = e.Source + ": " + msg
+	}
+	return msg
+}
+
+// ErrTooManyValues is returned when inputs hold more values than
+// Options.MaxValues
+var ErrTooManyValues = errors.New("too many values")
+
+// report collects invalid records according to the parse mode, and counts
+// decoded values against Options.MaxValues
+type report struct {
+	lenient   bool
+	maxErrors int
+	skipped   int
+	errors    []RecordError
+
+	maxValues int
+	counted   int           // values counted by this decode
+	decoded   *atomic.Int64 // values counted by every decode sharing the cap
+}
+
+func newReport(opts Options) (*report, error) {
+	rep := &report{maxErrors: opts.MaxErrors, maxValues: opts.MaxValues, decoded: opts.decoded}
+	if rep.maxErrors == 0 {
+		rep.maxErrors = DefaultMaxErrors
+	}
+	if rep.decoded == nil {
+		rep.decoded = new(atomic.Int64)
+	}
+
+	switch strings.ToLower(opts.Mode) {
+	case "", ModeStrict:
+	case ModeLenient:
+		rep.lenient = true
+	default:
+		return nil, fmt.Errorf("unknown parse mode %q (supported: strict, lenient)", opts.Mode)
+	}
+	return rep, nil
+}
+
+// strictReport fails on the first invalid record
+func strictReport() *report {
+	return &report{}
+}
+
+// add records an invalid record. In strict mode it returns the record as an
+// error to abort parsing; in lenient mode the record is counted, kept if the
+// report has room, and nil is returned.
+func (r *report) add(line int, column, value, reason string) error {
+	e := RecordError{Line: line, Column: column, Value: value, Reason: reason}
+	if !r.lenient {
+		return &e
+	}
+
+	r.skipped++
+	if r.maxErrors < 0 || len(r.errors) < r.maxErrors {
+		r.errors = append(r.errors, e)
+	}
+	return nil
+}
+
+// count adds n decoded values, failing with ErrTooManyValues once more than
+// Options.MaxValues have been decoded
+func (r *report) count(n int) error {
+	r.counted += n
+	if r.maxValues <= 0 {
+		return nil
+	}
+	if r.decoded.Add(int64(n)) > int64(r.maxValues) {
+		return fmt.Errorf("%w: more than %d", ErrTooManyValues, r.maxValues)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package parser
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecode_Lenient(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		filename    string
+		want        []float64
+		wantSkipped int
+		wantErrors  []RecordError
+	}{
+		{
+			name:        "CSV bad cells",
+			data:        "host,value\na,1\nb,N/A\nc,-\nd,null\ne,2\n",
+			filename:    "data.csv",
+			want:        []float64{1, 2},
+			wantSkipped: 3,
+			wantErrors: []RecordError{
+				{Line: 3, Column: "value", Value: "N/A", Reason: `invalid number "N/A"`},
+				{Line: 4, Column: "value", Value: "-", Reason: `invalid number "-"`},
+				{Line: 5, Column: "value", Value: "null", Reason: `invalid number "null"`},
+			},
+		},
+		{
+			name:        "CSV short row",
+			data:        "host,value\na,1\nb\nc,3\n",
+			filename:    "data.csv",
+			want:        []float64{1, 3},
+			wantSkipped: 1,
+			wantErrors:  []RecordError{{Line: 3, Column: "value", Reason: "row has 1 fields, missing column"}},
+		},
+		{
+			name:     "CSV ragged rows with the column",
+			data:     "value,host\n1,a\n2\n3,c,extra\n",
+			filename: "data.csv",
+			want:     []float64{1, 2, 3},
+		},
+		{
+			name:        "CSV malformed quote",
+			data:        "value\n1\n\"2\n",
+			filename:    "data.csv",
+			want:        []float64{1},
+			wantSkipped: 1,
+		},
+		{
+			name:        "JSON array elements",
+			data:        `[1, "fast", {"a": 1}, 2]`,
+			filename:    "data.json",
+			want:        []float64{1, 2},
+			wantSkipped: 2,
+		},
+		{
+			name:        "NDJSON records",
+			data:        "{\"value\": 1}\n{bad}\n\n{\"value\": \"N/A\"}\n2\n",
+			filename:    "data.ndjson",
+			want:        []float64{1, 2},
+			wantSkipped: 2,
+			wantErrors: []RecordError{
+				{Line: 2, Value: "{bad}", Reason: "invalid JSON"},
+				{Line: 4, Value: `{"value": "N/A"}`, Reason: `invalid number "N/A"`},
+			},
+		},
+		{
+			name:        "text fields",
+			data:        "1 2\n3 x 4\n",
+			filename:    "data.txt",
+			want:        []float64{1, 2, 3, 4},
+			wantSkipped: 1,
+			wantErrors:  []RecordError{{Line: 2, Column: "2", Value: "x", Reason: `invalid number "x"`}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Decode(strings.NewReader(tt.data), tt.filename, Options{Mode: ModeLenient})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(res.Values, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, res.Values)
+			}
+			if res.Skipped != tt.wantSkipped {
+				t.Errorf("expected %d skipped, got %d", tt.wantSkipped, res.Skipped)
+			}
+			if tt.wantErrors != nil && !slices.Equal(res.Errors, tt.wantErrors) {
+				t.Errorf("expected errors %+v, got %+v", tt.wantErrors, res.Errors)
+			}
+			if len(res.Errors) != res.Skipped {
+				t.Errorf("expected %d errors, got %d", res.Skipped, len(res.Errors))
+			}
+		})
+	}
+}
+
+func TestDecode_Strict(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		filename string
+		want     RecordError
+	}{
+		{name: "CSV bad cell", data: "host,value\na,1\nb,N/A\n", filename: "data.csv", want: RecordError{Line: 3, Column: "value", Value: "N/A", Reason: `invalid number "N/A"`}},
+		{name: "CSV short row", data: "host,value\na,1\nb\n", filename: "data.csv", want: RecordError{Line: 3, Column: "value", Reason: "row has 1 fields, missing column"}},
+		{name: "CSV ragged row", data: "value,host\n1,a\n2\n", filename: "data.csv", want: RecordError{Line: 3, Reason: "row has 1 fields, header has 2"}},
+		{name: "CSV long row", data: "host,value\na,1\nb,2,extra\n", filename: "data.csv", want: RecordError{Line: 3, Reason: "row has 3 fields, header has 2"}},
+		{name: "JSON element", data: `[1, "fast"]`, filename: "data.json", want: RecordError{Column: "$[1]", Value: `"fast"`, Reason: `invalid number "fast"`}},
+		{name: "text field", data: "1\n2 x\n", filename: "data.txt", want: RecordError{Line: 2, Column: "2", Value: "x", Reason: `invalid number "x"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.data), tt.filename, Options{Mode: ModeStrict})
+			var recErr *RecordError
+			if !errors.As(err, &recErr) {
+				t.Fatalf("expected a RecordError, got %v", err)
+			}
+			if *recErr != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, *recErr)
+			}
+		})
+	}
+}
+
+func TestDecode_LenientErrorCap(t *testing.T) {
+	data := "value\n" + strings.Repeat("x\n", 25) + "1\n"
+
+	res, err := Decode(strings.NewReader(data), "data.csv", Options{Mode: ModeLenient})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Skipped != 25 {
+		t.Errorf("expected 25 skipped, got %d", res.Skipped)
+	}
+	if len(res.Errors) != DefaultMaxErrors {
+		t.Errorf("expected %d errors, got %d", DefaultMaxErrors, len(res.Errors))
+	}
+
+	res, err = Decode(strings.NewReader(data), "data.csv", Options{Mode: ModeLenient, MaxErrors: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res.Errors) != 3 || res.Errors[2].Line != 4 {
+		t.Errorf("expected 3 errors ending at line 4, got %+v", res.Errors)
+	}
+
+	res, err = Decode(strings.NewReader(data), "data.csv", Options{Mode: ModeLenient, MaxErrors: -1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res.Errors) != 25 {
+		t.Errorf("expected 25 errors, got %d", len(res.Errors))
+	}
+}
+
+func TestDecode_MaxValues(t *testing.T) {
+	inputs := map[string]string{
+		"data.csv":    "value\n1\n2\n3\n4\n",
+		"data.json":   "[1, 2, 3, 4]",
+		"data.ndjson": "1\n2\n3\n4\n",
+		"data.txt":    "1 2\n3 4\n",
+	}
+	for name, data := range inputs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode(strings.NewReader(data), name, Options{MaxValues: 3}); !errors.Is(err, ErrTooManyValues) {
+				t.Errorf("expected ErrTooManyValues, got %v", err)
+			}
+			res, err := Decode(strings.NewReader(data), name, Options{MaxValues: 4})
+			if err != nil || len(res.Values) != 4 {
+				t.Errorf("expected 4 values at the limit, got %v, %v", res, err)
+			}
+		})
+	}
+}
+
+func TestDecode_InvalidMode(t *testing.T) {
+	if _, err := Decode(strings.NewReader("value\n1\n"), "data.csv", Options{Mode: "sloppy"}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestRecordError_Error(t *testing.T) {
+	tests := []struct {
+		err  RecordError
+		want string
+	}{
+		{RecordError{Line: 3, Column: "value", Reason: "bad"}, `line 3, column "value": bad`},
+		{RecordError{Line: 3, Reason: "bad"}, "line 3: bad"},
+		{RecordError{Column: "$[1]", Reason: "bad"}, `column "$[1]": bad`},
+		{RecordError{Reason: "bad"}, "bad"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestDecode_NonFinite(t *testing.T) {
+	data := "value\n1\nNaN\n+Inf\n-Infinity\n4\n"
+
+	if _, err := Decode(strings.NewReader(data), "data.csv", Options{}); err == nil {
+		t.Error("expected error rejecting non-finite values, got nil")
+	}
+
+	res, err := Decode(strings.NewReader(data), "data.csv", Options{NonFinite: NonFiniteDrop})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(res.Values, []float64{1, 4}) || res.NonFinite.Dropped != 3 {
+		t.Errorf("expected [1 4] with 3 dropped, got %v %+v", res.Values, res.NonFinite)
+	}
+
+	res, err = Decode(strings.NewReader(data), "data.csv", Options{NonFinite: NonFiniteClamp})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(res.Values, []float64{1, 4, 1, 4}) || res.NonFinite.Dropped != 1 || res.NonFinite.Clamped != 2 {
+		t.Errorf("expected [1 4 1 4] with 1 dropped and 2 clamped, got %v %+v", res.Values, res.NonFinite)
+	}
+}
+
+func TestGroupValues_NonFinite(t *testing.T) {
+	values := []float64{1, math.NaN(), 2, math.Inf(1), 3}
+	labels := []string{"a", "a", "b", "b", "a"}
+	names := []string{"a1", "a2", "b1", "b2", "a3"}
+
+	groups, err := groupValues(values, labels, names, NonFiniteDrop)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(groups[0].Values, []float64{1, 3}) || !slices.Equal(groups[0].Names, []string{"a1", "a3"}) || groups[0].NonFinite.Dropped != 1 {
+		t.Errorf("expected a with 1 dropped, got %+v", groups[0])
+	}
+	if !slices.Equal(groups[1].Values, []float64{2}) || groups[1].NonFinite.Dropped != 1 {
+		t.Errorf("expected b with 1 dropped, got %+v", groups[1])
+	}
+
+	groups, err = groupValues(values, labels, nil, NonFiniteClamp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if groups[0].NonFinite != (NonFiniteStats{Dropped: 1}) || groups[1].NonFinite != (NonFiniteStats{Clamped: 1}) {
+		t.Errorf("expected a with 1 dropped and b with 1 clamped, got %+v and %+v", groups[0].NonFinite, groups[1].NonFinite)
+	}
+
+	if _, err := groupValues(values, labels, nil, NonFiniteReject); err == nil || !strings.Contains(err.Error(), `group "a"`) {
+		t.Errorf("expected group a to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"bytes"
+	"mime"
+	"slices"
+	"strings"
+)
+
+// Format names reported for decoded inputs
+const (
+	FormatJSON    = "json"
+	FormatNDJSON  = "ndjson"
+	FormatCSV     = "csv"
+	FormatText    = "text"
+	FormatParquet = "parquet"
+)
+
+// sniffLen is the number of leading bytes inspected during content detection
+const sniffLen = 4096
+
+// formatHint returns the format implied by a filename extension or MIME type.
+// Generic hints such as text/plain, application/octet-stream and those of
+// fallback formats select no format.
+func formatHint(filename, contentType string) string {
+	return matchFormat(filename, contentType, false)
+}
+
+// formatFallback returns the fallback format used for generic inputs that
+// content sniffing could not classify
+func formatFallback(filename, contentType string) string {
+	return matchFormat(filename, contentType, true)
+}
+
+// matchFormat finds the format, among the fallback formats or the others,
+// registered for the filename extension or, failing that, the MIME type
+func matchFormat(filename, contentType string, fallback bool) string {
+	formats := formatsByPrecedence()
+	ext := formatExt(filename)
+	for _, f := range formats {
+		if isFallback(f) == fallback && slices.Contains(f.Extensions(), ext) {
+			return f.Name()
+		}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	for _, f := range formats {
+		if isFallback(f) == fallback && slices.Contains(f.MIMETypes(), mediaType) {
+			return f.Name()
+		}
+	}
+	return ""
+}
+
+// genericFormats are recognized by syntax alone, such as a leading "{", so
+// sniffing them never overrides a format hint
+var genericFormats = []string{FormatJSON, FormatNDJSON, FormatCSV, FormatText}
+
+// detectFormat returns the format of an input given the format hinted by its
+// name or MIME type, if any. Inputs without a hint are sniffed. A hint is
+// overridden when its format rejects the content and a more specific format
+// recognizes it, such as OTLP traces or test2json output saved as ".json".
+func detectFormat(hint string, prefix []byte, opts Options) string {
+	if hint == "" {
+		return sniffFormat(prefix, opts)
+	}
+	f, ok := LookupFormat(hint)
+	if !ok || f.Sniff(prefix, opts) {
+		return hint
+	}
+	if sniffed := sniffFormat(prefix, opts); sniffed != "" && !slices.Contains(genericFormats, sniffed) {
+		return sniffed
+	}
+	return hint
+}
+
+// SniffFormat inspects the leading bytes of an input and returns the detected
+// format, or an empty string if the content is not recognized
+func SniffFormat(prefix []byte) string {
+	return sniffFormat(prefix, Options{})
+}
+
+// sniffFormat returns the first registered format recognizing the content
+func sniffFormat(prefix []byte, opts Options) string {
+	if len(sniffTrim(prefix)) == 0 {
+		return ""
+	}
+	for _, f := range formatsByPrecedence() {
+		if f.Sniff(prefix, opts) {
+			return f.Name()
+		}
+	}
+	return ""
+}
+
+// sniffTrim strips a byte order mark and leading whitespace from a prefix
+func sniffTrim(prefix []byte) []byte {
+	prefix = bytes.TrimPrefix(prefix, []byte("\xef\xbb\xbf"))
+	return bytes.TrimLeft(prefix, " \t\r\n")
+}
+
+// sniffFirstLine returns the first non-blank line of a prefix
+func sniffFirstLine(prefix []byte) string {
+	line, _, _ := bytes.Cut(sniffTrim(prefix), []byte("\n"))
+	return strings.TrimSpace(string(line))
+}
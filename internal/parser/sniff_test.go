@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestSniffFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "JSON array", data: `[1, 2, 3]`, want: FormatJSON},
+		{name: "JSON array with leading whitespace", data: "\n\t [1]", want: FormatJSON},
+		{name: "NDJSON objects", data: "{\"value\": 1}\n{\"value\": 2}\n", want: FormatNDJSON},
+		{name: "CSV with header", data: "host,value\na,1\n", want: FormatCSV},
+		{name: "single column CSV", data: "Value\n1\n2\n", want: FormatCSV},
+		{name: "quoted single column CSV", data: "\"value\"\n1\n", want: FormatCSV},
+		{name: "plain numbers", data: "1.5\n2\n3e2\n", want: FormatText},
+		{name: "whitespace separated numbers", data: "1 2 3\n4 5\n", want: FormatText},
+		{name: "leading comment", data: "# host,value\n1\n2\n", want: FormatText},
+		{name: "byte order mark", data: "\xef\xbb\xbf[1]", want: FormatJSON},
+		{name: "XML", data: "<data/>", want: ""},
+		{name: "empty", data: "", want: ""},
+		{name: "whitespace only", data: " \n\n ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SniffFormat([]byte(tt.data)); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatHint(t *testing.T) {
+	tests := []struct {
+		name        string
+		filename    string
+		contentType string
+		want        string
+	}{
+		{name: "extension wins", filename: "data.csv", contentType: "application/json", want: FormatCSV},
+		{name: "compressed extension", filename: "data.jsonl.zst", want: FormatNDJSON},
+		{name: "content type", filename: "blob", contentType: "text/csv; charset=utf-8", want: FormatCSV},
+		{name: "ndjson content type", filename: "blob", contentType: "application/x-ndjson", want: FormatNDJSON},
+		{name: "generic content type", filename: "data.txt", contentType: "text/plain", want: ""},
+		{name: "octet stream", filename: "blob", contentType: "application/octet-stream", want: ""},
+		{name: "malformed content type", filename: "blob", contentType: ";;", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatHint(tt.filename, tt.contentType); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDecode_DetectsFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		filename    string
+		contentType string
+		wantFormat  string
+		want        []float64
+	}{
+		{name: "JSON blob", data: `[1, 2, 3]`, filename: "blob", wantFormat: FormatJSON, want: []float64{1, 2, 3}},
+		{name: "CSV in .txt", data: "value\n4\n5\n", filename: "data.txt", wantFormat: FormatCSV, want: []float64{4, 5}},
+		{name: "plain numbers", data: "7\n\n8\n9\n", filename: "data.txt", wantFormat: FormatText, want: []float64{7, 8, 9}},
+		{name: "NDJSON objects", data: "{\"value\": 1}\n{\"value\": null}\n{\"value\": 2}\n", filename: "blob", wantFormat: FormatNDJSON, want: []float64{1, 2}},
+		{name: "NDJSON numbers by extension", data: "1\n2\n", filename: "data.jsonl", wantFormat: FormatNDJSON, want: []float64{1, 2}},
+		{name: "content type hint", data: "value\n6\n", filename: "blob", contentType: "text/csv", wantFormat: FormatCSV, want: []float64{6}},
+		{name: "byte order mark CSV", data: "\xef\xbb\xbfvalue\n3\n", filename: "data.csv", wantFormat: FormatCSV, want: []float64{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Decode(bytes.NewReader([]byte(tt.data)), tt.filename, Options{ContentType: tt.contentType})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Format != tt.wantFormat {
+				t.Errorf("expected format %q, got %q", tt.wantFormat, res.Format)
+			}
+			if !slices.Equal(res.Values, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, res.Values)
+			}
+		})
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		filename string
+	}{
+		{name: "undetectable content", data: "<data/>", filename: "blob"},
+		{name: "unrecognized text file", data: "test", filename: "data.txt"},
+		{name: "invalid plain number", data: "1\nabc\n", filename: "data.txt"},
+		{name: "invalid NDJSON record", data: "{\"value\": 1}\n{bad}\n", filename: "data.ndjson"},
+		{name: "NDJSON non-numeric string value", data: "{\"value\": \"fast\"}\n", filename: "data.ndjson"},
+		{name: "trailing data after JSON array", data: "[1] [2]", filename: "data.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(bytes.NewReader([]byte(tt.data)), tt.filename, Options{}); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// textComment starts a comment that runs to the end of the line
+	textComment = "#"
+
+	// maxTextLineLength bounds a single line so whitespace-separated values on
+	// one long line (e.g. `seq -s ' '` output) still parse
+	maxTextLineLength = 64 << 20
+)
+
+// readTextFromReader reads plain-text numbers separated by newlines or any other
+// whitespace. Blank lines and "#" comments are ignored.
+func readTextFromReader(r io.Reader, vp *ValueParser, rep *report) ([]float64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTextLineLength)
+
+	var values []float64
+	for line := 1; scanner.Scan(); line++ {
+		for i, field := range textFields(scanner.Text()) {
+			value, err := vp.Parse(field)
+			if err != nil {
+				if err := rep.add(line, strconv.Itoa(i+1), field, err.Error()); err != nil {
+					return nil, fmt.Errorf("invalid text record: %w", err)
+				}
+				continue
+			}
+			if err := rep.count(1); err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read text input: %w", err)
+	}
+
+	return values, nil
+}
+
+// textFields splits a line into whitespace-separated fields after removing any
+// trailing comment
+func textFields(line string) []string {
+	if i := strings.Index(line, textComment); i >= 0 {
+		line = line[:i]
+	}
+	return strings.Fields(line)
+}
+
+// isTextLine reports whether a line consists only of numbers (with optional
+// units) written in loc, and comments
+func isTextLine(line string, loc Locale) bool {
+	for _, field := range textFields(line) {
+		if _, err := (&ValueParser{locale: loc}).Parse(field); err != nil {
+			return false
+		}
+	}
+	return true
+}
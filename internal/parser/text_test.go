@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadTextBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []float64
+		wantErr bool
+	}{
+		{
+			name: "one value per line",
+			data: "1\n2\n3\n",
+			want: []float64{1, 2, 3},
+		},
+		{
+			name: "whitespace separated",
+			data: "1 2\t3\n4   5\n",
+			want: []float64{1, 2, 3, 4, 5},
+		},
+		{
+			name: "comments and blank lines",
+			data: "# latency in ms\n1.5\n\n2.5 # slow request\n   # indented comment\n3\n",
+			want: []float64{1.5, 2.5, 3},
+		},
+		{
+			name: "windows line endings",
+			data: "1\r\n2\r\n",
+			want: []float64{1, 2},
+		},
+		{
+			name: "scientific and negative",
+			data: "-1e3 2.5e-1\n",
+			want: []float64{-1000, 0.25},
+		},
+		{
+			name: "empty input",
+			data: "",
+			want: nil,
+		},
+		{
+			name:    "invalid number",
+			data:    "1\n2 x\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadTextBytes([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReadTextBytes_ErrorReportsLine(t *testing.T) {
+	_, err := ReadTextBytes([]byte("1\n2\nthree\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expected error mentioning line 3, got %v", err)
+	}
+}
+
+func TestReadTextBytes_LongLine(t *testing.T) {
+	data := strings.Repeat("1 ", 100000)
+	got, err := ReadTextBytes([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 100000 {
+		t.Errorf("expected 100000 values, got %d", len(got))
+	}
+}
+
+func TestReadTextFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "values.txt")
+	if err := os.WriteFile(path, []byte("# seq 1 3\n1\n2\n3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := ReadTextFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(values, []float64{1, 2, 3}) {
+		t.Errorf("expected [1 2 3], got %v", values)
+	}
+}
+
+func TestReadTextFile_NotFound(t *testing.T) {
+	if _, err := ReadTextFile("/nonexistent/file.txt"); err == nil {
+		t.Error("expected error for missing file, got nil")
+	}
+}
+
+func TestReadValuesFromFile_Text(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "awk-output.txt")
+	if err := os.WriteFile(path, []byte("# comment, with a comma\n10 20\n30\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := ReadValuesFromFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(values, []float64{10, 20, 30}) {
+		t.Errorf("expected [10 20 30], got %v", values)
+	}
+}
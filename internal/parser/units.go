@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Unit dimensions
+const (
+	DimensionDuration = "duration"
+	DimensionBytes    = "bytes"
+)
+
+// Unit is a measurement unit that values can be converted between
+type Unit struct {
+	// Name is the canonical symbol, e.g. "ms" or "KiB"
+	Name string
+	// Dimension is DimensionDuration or DimensionBytes
+	Dimension string
+	// Factor converts one of this unit into the dimension's base unit
+	// (seconds or bytes)
+	Factor float64
+}
+
+// unitSeconds is the base duration unit, used for compound Go durations
+var unitSeconds = Unit{Name: "s", Dimension: DimensionDuration, Factor: 1}
+
+var units = []Unit{
+	{Name: "ns", Dimension: DimensionDuration, Factor: 1e-9},
+	{Name: "us", Dimension: DimensionDuration, Factor: 1e-6},
+	{Name: "ms", Dimension: DimensionDuration, Factor: 1e-3},
+	unitSeconds,
+	{Name: "m", Dimension: DimensionDuration, Factor: 60},
+	{Name: "h", Dimension: DimensionDuration, Factor: 3600},
+	{Name: "B", Dimension: DimensionBytes, Factor: 1},
+	{Name: "KB", Dimension: DimensionBytes, Factor: 1e3},
+	{Name: "MB", Dimension: DimensionBytes, Factor: 1e6},
+	{Name: "GB", Dimension: DimensionBytes, Factor: 1e9},
+	{Name: "TB", Dimension: DimensionBytes, Factor: 1e12},
+	{Name: "PB", Dimension: DimensionBytes, Factor: 1e15},
+	{Name: "KiB", Dimension: DimensionBytes, Factor: 1 << 10},
+	{Name: "MiB", Dimension: DimensionBytes, Factor: 1 << 20},
+	{Name: "GiB", Dimension: DimensionBytes, Factor: 1 << 30},
+	{Name: "TiB", Dimension: DimensionBytes, Factor: 1 << 40},
+	{Name: "PiB", Dimension: DimensionBytes, Factor: 1 << 50},
+}
+
+// unitAliases maps lowercase spellings to canonical unit names
+var unitAliases = map[string]string{
+	"nanosecond": "ns", "nanoseconds": "ns",
+	"µs": "us", "μs": "us", "microsecond": "us", "microseconds": "us",
+	"millisecond": "ms", "milliseconds": "ms",
+	"sec": "s", "secs": "s", "second": "s", "seconds": "s",
+	"min": "m", "mins": "m", "minute": "m", "minutes": "m",
+	"hr": "h", "hrs": "h", "hour": "h", "hours": "h",
+	"byte": "B", "bytes": "B",
+}
+
+// symbolAliases maps other conventional spellings of symbols, matched
+// exactly, to canonical unit names
+var symbolAliases = map[string]string{
+	"kB": "KB",
+}
+
+// valueWithUnit splits a number from a trailing unit suffix such as "1.5 MB"
+var valueWithUnit = regexp.MustCompile(`^([+-]?(?:\d+\.?\d*|\.\d+)(?:[eE][+-]?\d+)?)\s*([a-zA-Zµμ]+)$`)
+
+// ParseUnit looks up a unit by symbol or by name. Symbols are case-sensitive,
+// so "m" is minutes and "M" no unit, while names are matched in any case.
+func ParseUnit(name string) (Unit, error) {
+	key := strings.TrimSpace(name)
+	if alias, ok := symbolAliases[key]; ok {
+		key = alias
+	} else if alias, ok := unitAliases[strings.ToLower(key)]; ok {
+		key = alias
+	}
+
+	i := slices.IndexFunc(units, func(u Unit) bool {
+		return u.Name == key
+	})
+	if i < 0 {
+		return Unit{}, fmt.Errorf("unknown unit %q (supported: ns, us, ms, s, m, h, B, KB or kB, MB, GB, TB, PB, KiB, MiB, GiB, TiB, PiB)", name)
+	}
+	return units[i], nil
+}
+
+// ValueParser parses numbers with optional duration or byte-size suffixes and
+// converts them into a common target unit. Plain numbers are taken to already
+// be in the target unit. When no target is given, the unit of the first
+// suffixed value becomes the target.
+type ValueParser struct {
+	target *Unit
+	locale Locale
+}
+
+// NewValueParser creates a ValueParser converting into the named unit, or
+// inferring one from the data when target is empty
+func NewValueParser(target string) (*ValueParser, error) {
+	if target == "" {
+		return &ValueParser{}, nil
+	}
+
+	u, err := ParseUnit(target)
+	if err != nil {
+		return nil, err
+	}
+	return &ValueParser{target: &u}, nil
+}
+
+// WithLocale sets the locale numbers are written in and returns p
+func (p *ValueParser) WithLocale(l Locale) *ValueParser {
+	p.locale = l
+	return p
+}
+
+// Locale returns the locale numbers are parsed in
+func (p *ValueParser) Locale() Locale {
+	return p.locale
+}
+
+// Unit returns the name of the target unit, or an empty string if no unit was
+// requested or seen
+func (p *ValueParser) Unit() string {
+	if p.target == nil {
+		return ""
+	}
+	return p.target.Name
+}
+
+// Parse parses a plain number ("12.5"), a number with a unit ("120ms",
+// "1.5 MB", "3KiB") or a compound Go duration ("1m30s"), with numbers
+// written in the parser's locale
+func (p *ValueParser) Parse(input string) (float64, error) {
+	s := input
+	if !p.locale.isDefault() {
+		s = p.locale.normalize(s)
+	}
+
+	if value, err := strconv.ParseFloat(s, 64); err == nil {
+		return value, nil
+	}
+
+	if m := valueWithUnit.FindStringSubmatch(s); m != nil {
+		if u, err := ParseUnit(m[2]); err == nil {
+			value, err := strconv.ParseFloat(m[1], 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid number %q", input)
+			}
+			return p.convert(value, u)
+		}
+	}
+
+	if d, err := time.ParseDuration(strings.ReplaceAll(s, " ", "")); err == nil {
+		return p.convert(d.Seconds(), unitSeconds)
+	}
+
+	return 0, fmt.Errorf("invalid number %q", input)
+}
+
+// Convert converts a plain value expressed in the named unit into the target unit
+func (p *ValueParser) Convert(value float64, from string) (float64, error) {
+	u, err := ParseUnit(from)
+	if err != nil {
+		return 0, err
+	}
+	return p.convert(value, u)
+}
+
+func (p *ValueParser) convert(value float64, from Unit) (float64, error) {
+	if p.target == nil {
+		p.target = &from
+		return value, nil
+	}
+	if p.target.Dimension != from.Dimension {
+		return 0, fmt.Errorf("cannot convert %s (%s) to %s (%s)", from.Name, from.Dimension, p.target.Name, p.target.Dimension)
+	}
+	if p.target.Name == from.Name {
+		return value, nil
+	}
+	return value * from.Factor / p.target.Factor, nil
+}
@@ -0,0 +1,204 @@
+package parser
+
+import (
+	"bytes"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseUnit(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "ms", want: "ms"},
+		{name: "µs", want: "us"},
+		{name: "seconds", want: "s"},
+		{name: "Seconds", want: "s"},
+		{name: "MIN", want: "m"},
+		{name: "KiB", want: "KiB"},
+		{name: "MB", want: "MB"},
+		{name: "kB", want: "KB"},
+		{name: "bytes", want: "B"},
+		{name: "Bytes", want: "B"},
+		// Symbols are case-sensitive
+		{name: "MS", wantErr: true},
+		{name: "Ms", wantErr: true},
+		{name: "M", wantErr: true},
+		{name: "b", wantErr: true},
+		{name: "kib", wantErr: true},
+		{name: "kb", wantErr: true},
+		{name: "mB", wantErr: true},
+		{name: "parsecs", wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := ParseUnit(tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if u.Name != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, u.Name)
+			}
+		})
+	}
+}
+
+func TestValueParser_Parse(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		input   string
+		want    float64
+		wantErr bool
+	}{
+		{name: "plain number", target: "ms", input: "12.5", want: 12.5},
+		{name: "same unit", target: "ms", input: "120ms", want: 120},
+		{name: "seconds to ms", target: "ms", input: "1.5s", want: 1500},
+		{name: "microseconds to ms", target: "ms", input: "250µs", want: 0.25},
+		{name: "space before unit", target: "ms", input: "2 sec", want: 2000},
+		{name: "compound duration", target: "s", input: "1m30s", want: 90},
+		{name: "hours to minutes", target: "m", input: "2h", want: 120},
+		{name: "SI bytes", target: "B", input: "1.5 MB", want: 1.5e6},
+		{name: "SI kilobytes with lowercase k", target: "B", input: "1kB", want: 1000},
+		{name: "IEC bytes", target: "KiB", input: "3MiB", want: 3072},
+		{name: "SI to IEC", target: "KiB", input: "1024B", want: 1},
+		{name: "exponent with unit", target: "ms", input: "1e3us", want: 1},
+		{name: "dimension mismatch", target: "ms", input: "3KB", wantErr: true},
+		{name: "unknown unit", target: "ms", input: "3 parsecs", wantErr: true},
+		{name: "not a number", target: "ms", input: "fast", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vp, err := NewValueParser(tt.target)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := vp.Parse(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValueParser_InfersUnit(t *testing.T) {
+	vp, err := NewValueParser("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vp.Unit() != "" {
+		t.Errorf("expected no unit, got %q", vp.Unit())
+	}
+
+	var got []float64
+	for _, s := range []string{"5", "100ms", "1s", "500us"} {
+		value, err := vp.Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %v", s, err)
+		}
+		got = append(got, value)
+	}
+
+	if vp.Unit() != "ms" {
+		t.Errorf("expected unit ms, got %q", vp.Unit())
+	}
+	if want := []float64{5, 100, 1000, 0.5}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if _, err := vp.Parse("1GB"); err == nil {
+		t.Error("expected error mixing durations and byte sizes, got nil")
+	}
+}
+
+func TestNewValueParser_InvalidUnit(t *testing.T) {
+	if _, err := NewValueParser("furlongs"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestDecode_Units(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		filename string
+		opts     Options
+		want     []float64
+		wantUnit string
+	}{
+		{name: "CSV", data: "value\n120ms\n1.2s\n80\n", filename: "data.csv", opts: Options{Unit: "ms"}, want: []float64{120, 1200, 80}, wantUnit: "ms"},
+		{name: "JSON strings", data: `["1s", 250, "0.5s", null]`, filename: "data.json", opts: Options{Unit: "ms"}, want: []float64{1000, 250, 500}, wantUnit: "ms"},
+		{name: "NDJSON", data: "{\"value\": \"2KiB\"}\n\"512B\"\n", filename: "data.ndjson", opts: Options{Unit: "KiB"}, want: []float64{2, 0.5}, wantUnit: "KiB"},
+		{name: "text", data: "1m 30s\n2m\n", filename: "data.txt", opts: Options{Unit: "s"}, want: []float64{60, 30, 120}, wantUnit: "s"},
+		{name: "inferred unit", data: "value\n1s\n500ms\n", filename: "data.csv", want: []float64{1, 0.5}, wantUnit: "s"},
+		{name: "no units", data: "value\n1\n2\n", filename: "data.csv", want: []float64{1, 2}},
+		{name: "nginx request time", data: nginxLog, opts: Options{Unit: "ms", AccessLog: AccessLogOptions{Format: "nginx"}}, want: []float64{120, 450, 2, 1500}, wantUnit: "ms"},
+		{name: "apache microseconds", data: apacheLog, opts: Options{Unit: "ms", AccessLog: AccessLogOptions{Format: "apache"}}, want: []float64{1.2, 98}, wantUnit: "ms"},
+		{name: "access log field unit", data: nginxLog, opts: Options{AccessLog: AccessLogOptions{Format: "nginx"}}, want: []float64{0.120, 0.450, 0.002, 1.500}, wantUnit: "s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Decode(strings.NewReader(tt.data), tt.filename, tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Unit != tt.wantUnit {
+				t.Errorf("expected unit %q, got %q", tt.wantUnit, res.Unit)
+			}
+			if len(res.Values) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, res.Values)
+			}
+			for i := range tt.want {
+				if math.Abs(res.Values[i]-tt.want[i]) > 1e-9 {
+					t.Errorf("expected %v, got %v", tt.want, res.Values)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestDecode_UnitErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		filename string
+		opts     Options
+	}{
+		{name: "invalid target unit", data: "value\n1\n", filename: "data.csv", opts: Options{Unit: "furlongs"}},
+		{name: "dimension mismatch", data: "value\n1KB\n", filename: "data.csv", opts: Options{Unit: "ms"}},
+		{name: "mixed dimensions", data: `["1s", "1KB"]`, filename: "data.json"},
+		{name: "access log field mismatch", data: nginxLog, opts: Options{Unit: "KB", AccessLog: AccessLogOptions{Format: "nginx"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(bytes.NewReader([]byte(tt.data)), tt.filename, tt.opts); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
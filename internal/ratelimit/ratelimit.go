@@ -0,0 +1,197 @@
+// Package ratelimit limits the request rate of each client with token buckets
+// and bounds concurrent work with a waiting queue.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// sweepInterval is how often idle buckets are dropped
+const sweepInterval = time.Minute
+
+// Limit is a token bucket refilled at Rate tokens per second up to Burst.
+// A zero Rate is unlimited.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Validate reports limits that would reject every request
+func (l Limit) Validate() error {
+	switch {
+	case l.Rate < 0:
+		return fmt.Errorf("rate %v must not be negative", l.Rate)
+	case l.Rate > 0 && l.Burst < 1:
+		return fmt.Errorf("burst %d must be at least 1", l.Burst)
+	}
+	return nil
+}
+
+// Limiter holds a token bucket per key, such as a client
+type Limiter struct {
+	limit Limit
+	// idle is how long a bucket takes to refill, after which it is
+	// equivalent to a new one and can be dropped
+	idle time.Duration
+	now  func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+// NewLimiter creates a limiter giving each key a bucket of limit
+func NewLimiter(limit Limit) *Limiter {
+	l := &Limiter{limit: limit, now: time.Now, buckets: make(map[string]*bucket)}
+	if limit.Rate > 0 {
+		l.idle = time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second))
+	}
+	return l
+}
+
+// Allow takes a token from the bucket of key. If it is empty, Allow returns
+// false and how long until a token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.limit.Rate == 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(l.limit.Rate), l.limit.Burst)}
+		l.buckets[key] = b
+	}
+	b.seen = now
+
+	r := b.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Check reports whether the bucket of key has a token, without taking it. If
+// it is empty, Check returns false and how long until a token is available.
+func (l *Limiter) Check(key string) (bool, time.Duration) {
+	if l.limit.Rate == 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return true, 0
+	}
+	now := l.now()
+	r := b.limiter.ReserveN(now, 1)
+	delay := r.DelayFrom(now)
+	r.CancelAt(now)
+	if delay > 0 {
+		return false, delay
+	}
+	return true, 0
+}
+
+// sweep drops the buckets that have refilled since they were last used
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.seen) >= l.idle {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Errors returned by Gate.Acquire
+var (
+	ErrQueueFull    = errors.New("too many calculations waiting")
+	ErrQueueTimeout = errors.New("timed out waiting for a calculation slot")
+)
+
+// Gate bounds the work in progress, queueing further work until a slot is
+// free
+type Gate struct {
+	slots    chan struct{}
+	queued   atomic.Int64
+	maxQueue int64
+	timeout  time.Duration
+}
+
+// NewGate creates a gate of maxConcurrent slots, queueing up to maxQueue
+// callers for up to timeout
+func NewGate(maxConcurrent, maxQueue int, timeout time.Duration) *Gate {
+	return &Gate{
+		slots:    make(chan struct{}, maxConcurrent),
+		maxQueue: int64(maxQueue),
+		timeout:  timeout,
+	}
+}
+
+// Acquire takes a slot, waiting in the queue if none is free. It fails with
+// ErrQueueFull if the queue is full, ErrQueueTimeout once the timeout passes,
+// or the context's error. Callers release the slot with the returned function.
+func (g *Gate) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case g.slots <- struct{}{}:
+		return g.release, nil
+	default:
+	}
+
+	if g.queued.Add(1) > g.maxQueue {
+		g.queued.Add(-1)
+		return nil, ErrQueueFull
+	}
+	defer g.queued.Add(-1)
+
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+	select {
+	case g.slots <- struct{}{}:
+		return g.release, nil
+	case <-timer.C:
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (g *Gate) release() {
+	<-g.slots
+}
+
+// InProgress returns the number of slots taken
+func (g *Gate) InProgress() int {
+	return len(g.slots)
+}
+
+// Queued returns the number of callers waiting for a slot
+func (g *Gate) Queued() int {
+	return int(g.queued.Load())
+}
+
+// Timeout returns how long callers wait in the queue
+func (g *Gate) Timeout() time.Duration {
+	return g.timeout
+}
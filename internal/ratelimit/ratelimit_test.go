@@ -0,0 +1,180 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(limit Limit) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	l := NewLimiter(limit)
+	l.now = clock.now
+	return l, clock
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l, clock := newTestLimiter(Limit{Rate: 2, Burst: 3})
+
+	for i := range 3 {
+		if ok, _ := l.Allow("alice"); !ok {
+			t.Fatalf("expected request %d of the burst to be allowed", i+1)
+		}
+	}
+	ok, retry := l.Allow("alice")
+	if ok {
+		t.Fatal("expected the request after the burst to be rejected")
+	}
+	if retry != 500*time.Millisecond {
+		t.Errorf("expected a retry after 500ms, got %v", retry)
+	}
+
+	// Each client has its own bucket
+	if ok, _ := l.Allow("bob"); !ok {
+		t.Error("expected another client to be allowed")
+	}
+
+	// Rejected requests take no token
+	clock.advance(500 * time.Millisecond)
+	if ok, _ := l.Allow("alice"); !ok {
+		t.Error("expected a request once a token was refilled")
+	}
+	if ok, _ := l.Allow("alice"); ok {
+		t.Error("expected the refilled token to be used up")
+	}
+}
+
+func TestLimiter_Check(t *testing.T) {
+	l, clock := newTestLimiter(Limit{Rate: 1, Burst: 2})
+
+	for range 3 {
+		if ok, _ := l.Check("alice"); !ok {
+			t.Fatal("expected a full bucket to be allowed")
+		}
+	}
+	l.Allow("alice")
+	l.Allow("alice")
+	ok, retry := l.Check("alice")
+	if ok || retry != time.Second {
+		t.Errorf("expected an empty bucket with a retry after 1s, got %v and %v", ok, retry)
+	}
+
+	// Checks take no token
+	clock.advance(time.Second)
+	if ok, _ := l.Check("alice"); !ok {
+		t.Error("expected a check once a token was refilled")
+	}
+	if ok, _ := l.Allow("alice"); !ok {
+		t.Error("expected the refilled token to be available")
+	}
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l, _ := newTestLimiter(Limit{})
+	for range 1000 {
+		if ok, _ := l.Allow("alice"); !ok {
+			t.Fatal("expected a zero rate to be unlimited")
+		}
+	}
+	if len(l.buckets) != 0 {
+		t.Errorf("expected no buckets, got %d", len(l.buckets))
+	}
+}
+
+func TestLimiter_SweepsIdleBuckets(t *testing.T) {
+	l, clock := newTestLimiter(Limit{Rate: 1, Burst: 5})
+	l.Allow("alice")
+	l.Allow("bob")
+
+	clock.advance(sweepInterval)
+	l.Allow("bob")
+	if _, ok := l.buckets["alice"]; ok {
+		t.Error("expected the refilled bucket of alice to be dropped")
+	}
+	if _, ok := l.buckets["bob"]; !ok {
+		t.Error("expected the bucket of bob to be kept")
+	}
+}
+
+func TestLimit_Validate(t *testing.T) {
+	tests := []struct {
+		limit Limit
+		valid bool
+	}{
+		{limit: Limit{}, valid: true},
+		{limit: Limit{Rate: 0.5, Burst: 1}, valid: true},
+		{limit: Limit{Rate: -1, Burst: 1}},
+		{limit: Limit{Rate: 1}},
+	}
+	for _, tt := range tests {
+		if err := tt.limit.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: expected valid %v, got %v", tt.limit, tt.valid, err)
+		}
+	}
+}
+
+func TestGate(t *testing.T) {
+	g := NewGate(1, 1, time.Hour)
+
+	release, err := g.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g.InProgress() != 1 {
+		t.Errorf("expected 1 in progress, got %d", g.InProgress())
+	}
+
+	// The next caller queues until the slot is released
+	acquired := make(chan error)
+	go func() {
+		release, err := g.Acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	for g.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := g.Acquire(context.Background()); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+
+	release()
+	if err := <-acquired; err != nil {
+		t.Errorf("expected the queued caller to get the slot, got %v", err)
+	}
+	if g.InProgress() != 0 || g.Queued() != 0 {
+		t.Errorf("expected the gate to be idle, got %d in progress and %d queued", g.InProgress(), g.Queued())
+	}
+}
+
+func TestGate_Timeout(t *testing.T) {
+	g := NewGate(1, 1, 10*time.Millisecond)
+	release, err := g.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer release()
+
+	if _, err := g.Acquire(context.Background()); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("expected ErrQueueTimeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := g.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if g.Queued() != 0 {
+		t.Errorf("expected an empty queue, got %d", g.Queued())
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wingnut128/outlier-go/internal/auth"
+	"github.com/wingnut128/outlier-go/pkg/api"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// routeScope returns the scope a route requires, or "" if any authenticated
+// client may use it
+func (s *Server) routeScope(route string) string {
+	switch {
+	case strings.HasPrefix(route, "/calculate"):
+		return auth.ScopeCalculate
+	case strings.HasPrefix(route, "/docs/"):
+		return auth.ScopeDocs
+	case s.metrics != nil && route == s.config.Metrics.Path:
+		return auth.ScopeMetrics
+	}
+	return ""
+}
+
+// authenticate rejects requests without valid credentials with 401 and those
+// lacking the scope of their route with 403. The principal of accepted
+// requests is added to the request's context.
+func (s *Server) authenticate(a *auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.config.Auth.ExemptHealth && c.FullPath() == "/health" {
+			c.Next()
+			return
+		}
+
+		p, err := a.Authenticate(c.Request)
+		if err != nil {
+			// Challenge for bearer tokens, flagging rejected credentials
+			challenge := `Bearer realm="outlier"`
+			if !errors.Is(err, auth.ErrNoCredentials) {
+				challenge += `, error="invalid_token"`
+			}
+			c.Header("WWW-Authenticate", challenge)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, api.ErrorResponse{
+				Error: fmt.Sprintf("Unauthorized: %v", err),
+			})
+			return
+		}
+		if scope := s.routeScope(c.FullPath()); scope != "" && !p.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, api.ErrorResponse{
+				Error: fmt.Sprintf("Forbidden: %q lacks the %s scope", p.Name, scope),
+			})
+			return
+		}
+
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("enduser.id", p.Name))
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+		c.Next()
+	}
+}
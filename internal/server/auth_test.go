@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wingnut128/outlier-go/internal/auth"
+	"github.com/wingnut128/outlier-go/internal/config"
+	"github.com/wingnut128/outlier-go/pkg/api"
+)
+
+// newAuthServer creates a server accepting the keys "calc-key", with the
+// calculate scope, and "ops-key", with the metrics scope, and tokens signed
+// with "jwt-secret", serving metrics
+func newAuthServer(t *testing.T, exemptHealth bool) *Server {
+	t.Helper()
+	t.Setenv("OUTLIER_JWT_SECRET", "jwt-secret")
+	t.Setenv("OUTLIER_API_KEYS", "")
+	cfg := config.DefaultConfig()
+	cfg.Auth = config.AuthConfig{
+		Enabled:      true,
+		ExemptHealth: exemptHealth,
+		Keys: []config.APIKeyConfig{
+			{Name: "calc", Hash: auth.HashKey("calc-key"), Scopes: []string{auth.ScopeCalculate}},
+			{Name: "ops", Hash: auth.HashKey("ops-key"), Scopes: []string{auth.ScopeMetrics}},
+		},
+		JWT: config.JWTConfig{Audience: "outlier"},
+	}
+	cfg.Metrics.Enabled = true
+	return newConfiguredServer(t, cfg)
+}
+
+func TestAuth(t *testing.T) {
+	srv := newAuthServer(t, true)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   "alice",
+		"aud":   "outlier",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "calculate",
+	}).SignedString([]byte("jwt-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		header    string
+		value     string
+		want      int
+		challenge string
+	}{
+		{name: "health is exempt", method: http.MethodGet, path: "/health", want: http.StatusOK},
+		{name: "no credentials", method: http.MethodPost, path: "/calculate", want: http.StatusUnauthorized, challenge: `Bearer realm="outlier"`},
+		{name: "invalid key", method: http.MethodPost, path: "/calculate", header: auth.APIKeyHeader, value: "nope", want: http.StatusUnauthorized, challenge: `Bearer realm="outlier", error="invalid_token"`},
+		{name: "invalid token", method: http.MethodPost, path: "/calculate", header: "Authorization", value: "Bearer a.b.c", want: http.StatusUnauthorized, challenge: `Bearer realm="outlier", error="invalid_token"`},
+		{name: "key with scope", method: http.MethodPost, path: "/calculate", header: auth.APIKeyHeader, value: "calc-key", want: http.StatusOK},
+		{name: "key as bearer token", method: http.MethodPost, path: "/calculate", header: "Authorization", value: "Bearer calc-key", want: http.StatusOK},
+		{name: "key without scope", method: http.MethodPost, path: "/calculate", header: auth.APIKeyHeader, value: "ops-key", want: http.StatusForbidden},
+		{name: "metrics without credentials", method: http.MethodGet, path: "/metrics", want: http.StatusUnauthorized, challenge: `Bearer realm="outlier"`},
+		{name: "metrics scope", method: http.MethodGet, path: "/metrics", header: auth.APIKeyHeader, value: "ops-key", want: http.StatusOK},
+		{name: "metrics without scope", method: http.MethodGet, path: "/metrics", header: auth.APIKeyHeader, value: "calc-key", want: http.StatusForbidden},
+		{name: "docs without scope", method: http.MethodGet, path: "/docs/index.html", header: auth.APIKeyHeader, value: "calc-key", want: http.StatusForbidden},
+		{name: "token with scope", method: http.MethodPost, path: "/calculate", header: "Authorization", value: "Bearer " + token, want: http.StatusOK},
+		{name: "token without scope", method: http.MethodGet, path: "/metrics", header: "Authorization", value: "Bearer " + token, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body *strings.Reader
+			if tt.method == http.MethodPost {
+				body = strings.NewReader(`{"values": [1, 2, 3]}`)
+			} else {
+				body = strings.NewReader("")
+			}
+			req := httptest.NewRequest(tt.method, tt.path, body)
+			req.Header.Set("Content-Type", "application/json")
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			srv.router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.challenge {
+				t.Errorf("expected challenge %q, got %q", tt.challenge, got)
+			}
+			if w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden {
+				var resp api.ErrorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+					t.Errorf("expected an ErrorResponse, got %s", w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAuth_HealthNotExempt(t *testing.T) {
+	srv := newAuthServer(t, false)
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", http.NoBody))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+
+	// Any authenticated client may check health
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/health", http.NoBody)
+	req.Header.Set(auth.APIKeyHeader, "ops-key")
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+}
+
+func TestAuth_LogsPrincipal(t *testing.T) {
+	logs := captureLogs(t)
+	srv := newAuthServer(t, true)
+
+	req := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(`{"values": [1]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(auth.APIKeyHeader, "calc-key")
+	srv.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if entries := logs(); len(entries) != 1 || entries[0]["principal"] != "calc" {
+		t.Errorf("expected the request logged with principal calc, got %v", entries)
+	}
+}
+
+func TestNewServer_InvalidAuth(t *testing.T) {
+	t.Setenv("OUTLIER_JWT_SECRET", "")
+	t.Setenv("OUTLIER_API_KEYS", "")
+	cfg := config.DefaultConfig()
+	cfg.Auth.Enabled = true
+	if _, err := NewServer(cfg); err == nil {
+		t.Error("expected an error without keys or a JWT secret")
+	}
+}
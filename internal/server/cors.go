@@ -0,0 +1,97 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/wingnut128/outlier-go/internal/config"
+)
+
+// originPattern matches the origins of a scheme and port whose host is the
+// pattern's domain or, for wildcard patterns, one of its subdomains
+type originPattern struct {
+	scheme   string
+	host     string // domain of wildcard patterns, without the leading "*."
+	port     string
+	wildcard bool
+}
+
+// parseOriginPattern parses an exact origin such as "https://example.com" or
+// a wildcard subdomain pattern such as "https://*.example.com:8443"
+func parseOriginPattern(s string) (originPattern, error) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.User != nil || strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+		return originPattern{}, fmt.Errorf("CORS origin %q must be scheme://host[:port] with http or https", s)
+	}
+	p := originPattern{scheme: u.Scheme, host: strings.ToLower(u.Hostname()), port: u.Port()}
+	if rest, ok := strings.CutPrefix(p.host, "*."); ok {
+		p.host, p.wildcard = rest, true
+	}
+	if p.host == "" || strings.Contains(p.host, "*") {
+		return originPattern{}, fmt.Errorf("CORS origin %q may only use * as the first label of the host, as in https://*.example.com", s)
+	}
+	return p, nil
+}
+
+func (p originPattern) matches(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme != p.scheme || u.Port() != p.port {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if p.wildcard {
+		sub, ok := strings.CutSuffix(host, "."+p.host)
+		return ok && sub != ""
+	}
+	return host == p.host
+}
+
+// newCORS creates the CORS middleware of a policy, or returns nil if the
+// policy allows no origins, leaving cross-origin requests without CORS
+// headers for browsers to block
+func newCORS(cfg config.CORSConfig) (gin.HandlerFunc, error) {
+	if cfg.AllowAllOrigins {
+		if len(cfg.AllowedOrigins) > 0 {
+			return nil, errors.New("CORS allow_all_origins cannot be combined with allowed_origins")
+		}
+		if cfg.AllowCredentials {
+			return nil, errors.New("CORS allow_credentials cannot be combined with allow_all_origins")
+		}
+	} else if len(cfg.AllowedOrigins) == 0 {
+		return nil, nil
+	}
+
+	patterns := make([]originPattern, 0, len(cfg.AllowedOrigins))
+	for _, origin := range cfg.AllowedOrigins {
+		p, err := parseOriginPattern(origin)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+
+	corsConfig := cors.Config{
+		AllowAllOrigins:  cfg.AllowAllOrigins,
+		AllowMethods:     cfg.AllowedMethods,
+		AllowHeaders:     cfg.AllowedHeaders,
+		ExposeHeaders:    cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge.Duration,
+	}
+	if !cfg.AllowAllOrigins {
+		corsConfig.AllowOriginFunc = func(origin string) bool {
+			for _, p := range patterns {
+				if p.matches(origin) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return cors.New(corsConfig), nil
+}
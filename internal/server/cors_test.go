@@ -0,0 +1,180 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wingnut128/outlier-go/internal/config"
+)
+
+func newCORSServer(t *testing.T, configure func(*config.CORSConfig)) *Server {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	configure(&cfg.CORS)
+	return newConfiguredServer(t, cfg)
+}
+
+func preflight(srv *Server, origin, method string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/calculate", http.NoBody)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	req.Header.Set("Access-Control-Request-Headers", "content-type")
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	srv := newCORSServer(t, func(c *config.CORSConfig) {
+		c.AllowedOrigins = []string{"https://app.example.com", "https://*.example.org", "http://localhost:5173"}
+		c.AllowCredentials = true
+		c.MaxAge = config.Duration{Duration: 10 * time.Minute}
+	})
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{origin: "https://app.example.com", allowed: true},
+		{origin: "https://APP.example.com", allowed: true},
+		{origin: "https://a.example.org", allowed: true},
+		{origin: "https://a.b.example.org", allowed: true},
+		{origin: "http://localhost:5173", allowed: true},
+		{origin: "https://example.org"},
+		{origin: "https://evilexample.org"},
+		{origin: "https://example.org.evil.com"},
+		{origin: "http://a.example.org"},
+		{origin: "https://a.example.org:8443"},
+		{origin: "http://localhost:3001"},
+		{origin: "https://app.example.com.evil.com"},
+		{origin: "null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			w := preflight(srv, tt.origin, http.MethodPost)
+			if !tt.allowed {
+				if w.Code != http.StatusForbidden {
+					t.Errorf("expected 403, got %d", w.Code)
+				}
+				if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+					t.Errorf("expected no Access-Control-Allow-Origin, got %q", got)
+				}
+				return
+			}
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("expected 204, got %d", w.Code)
+			}
+			want := map[string]string{
+				"Access-Control-Allow-Origin":      tt.origin,
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Max-Age":           "600",
+				"Access-Control-Allow-Methods":     "GET,POST,OPTIONS",
+			}
+			for header, value := range want {
+				if got := w.Header().Get(header); got != value {
+					t.Errorf("expected %s %q, got %q", header, value, got)
+				}
+			}
+			if got := w.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Content-Type") {
+				t.Errorf("expected Content-Type to be allowed, got %q", got)
+			}
+		})
+	}
+}
+
+func TestCORS_ActualRequest(t *testing.T) {
+	srv := newCORSServer(t, func(c *config.CORSConfig) {
+		c.AllowedOrigins = []string{"https://app.example.com"}
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(`{"values": [1, 2, 3]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("expected the origin to be allowed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-Id" {
+		t.Errorf("expected X-Request-ID to be exposed, got %q", got)
+	}
+	if got := w.Header().Get("Vary"); !strings.Contains(got, "Origin") {
+		t.Errorf("expected Vary: Origin, got %q", got)
+	}
+}
+
+func TestCORS_DisabledByDefault(t *testing.T) {
+	srv := newTestServer()
+
+	w := preflight(srv, "https://app.example.com", http.MethodPost)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no Access-Control-Allow-Origin, got %q", got)
+	}
+	if w.Code == http.StatusNoContent {
+		t.Error("expected the preflight not to be answered")
+	}
+}
+
+func TestCORS_AllowAllOrigins(t *testing.T) {
+	srv := newCORSServer(t, func(c *config.CORSConfig) { c.AllowAllOrigins = true })
+
+	w := preflight(srv, "https://anywhere.example", http.MethodPost)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected *, got %q", got)
+	}
+}
+
+func TestCORS_PreflightSkipsAuthentication(t *testing.T) {
+	t.Setenv("OUTLIER_API_KEYS", "ci:"+strings.Repeat("a", 64))
+	t.Setenv("OUTLIER_JWT_SECRET", "")
+	cfg := config.DefaultConfig()
+	cfg.Auth.Enabled = true
+	cfg.CORS.AllowedOrigins = []string{"https://app.example.com"}
+	srv := newConfiguredServer(t, cfg)
+
+	if w := preflight(srv, "https://app.example.com", http.MethodPost); w.Code != http.StatusNoContent {
+		t.Errorf("expected the preflight to be answered without credentials, got %d", w.Code)
+	}
+}
+
+func TestNewServer_InvalidCORS(t *testing.T) {
+	tests := []struct {
+		name string
+		cors func(*config.CORSConfig)
+	}{
+		{name: "all origins with a list", cors: func(c *config.CORSConfig) {
+			c.AllowAllOrigins = true
+			c.AllowedOrigins = []string{"https://app.example.com"}
+		}},
+		{name: "all origins with credentials", cors: func(c *config.CORSConfig) {
+			c.AllowAllOrigins = true
+			c.AllowCredentials = true
+		}},
+		{name: "missing scheme", cors: func(c *config.CORSConfig) { c.AllowedOrigins = []string{"app.example.com"} }},
+		{name: "path", cors: func(c *config.CORSConfig) { c.AllowedOrigins = []string{"https://app.example.com/api"} }},
+		{name: "inner wildcard", cors: func(c *config.CORSConfig) { c.AllowedOrigins = []string{"https://app.*.example.com"} }},
+		{name: "bare wildcard", cors: func(c *config.CORSConfig) { c.AllowedOrigins = []string{"*"} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			tt.cors(&cfg.CORS)
+			if _, err := NewServer(cfg); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
@@ -1,10 +1,14 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wingnut128/outlier-go/internal/calculator"
@@ -21,6 +25,68 @@ func badRequest(c *gin.Context, format string, args ...any) {
 	})
 }
 
+// accessLogOptions reads access log parsing options from the multipart form
+func accessLogOptions(c *gin.Context) parser.AccessLogOptions {
+	var statuses []string
+	for _, field := range c.PostFormArray("log_status") {
+		for _, status := range strings.Split(field, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				statuses = append(statuses, status)
+			}
+		}
+	}
+
+	return parser.AccessLogOptions{
+		Format:     c.PostForm("log_format"),
+		Pattern:    c.PostForm("log_pattern"),
+		Field:      c.PostForm("log_field"),
+		Statuses:   statuses,
+		PathPrefix: c.PostForm("log_path_prefix"),
+	}
+}
+
+// harOptions reads the HAR timing phase and filters from the multipart form
+func harOptions(c *gin.Context) parser.HAROptions {
+	return parser.HAROptions{
+		Phase: c.PostForm("har_phase"),
+		URL:   c.PostForm("har_url"),
+		Host:  c.PostForm("har_host"),
+		MIME:  c.PostForm("har_mime"),
+	}
+}
+
+// otlpOptions reads the span filters from the multipart form
+func otlpOptions(c *gin.Context) parser.OTLPOptions {
+	return parser.OTLPOptions{
+		Attributes: c.PostFormArray("span_attr"),
+		Status:     c.PostForm("span_status"),
+	}
+}
+
+// localeOptions reads the number format from the multipart form
+func localeOptions(c *gin.Context) (parser.Locale, error) {
+	var strip bool
+	if v := c.PostForm("strip_symbols"); v != "" {
+		var err error
+		if strip, err = strconv.ParseBool(v); err != nil {
+			return parser.Locale{}, fmt.Errorf("invalid strip_symbols value: %w", err)
+		}
+	}
+	return parser.NewLocale(c.PostForm("locale"), c.PostForm("decimal_separator"), c.PostForm("grouping_separator"), strip)
+}
+
+// recordErrors converts parser record errors for the API response
+func recordErrors(errs []parser.RecordError) []api.RecordError {
+	if len(errs) == 0 {
+		return nil
+	}
+	out := make([]api.RecordError, len(errs))
+	for i, e := range errs {
+		out[i] = api.RecordError{Line: e.Line, Column: e.Column, Value: e.Value, Reason: e.Reason, Source: e.Source}
+	}
+	return out
+}
+
 // handleHealth handles GET /health
 // @Summary Health check
 // @Description Check if the service is healthy
@@ -45,22 +111,60 @@ func handleHealth(c *gin.Context) {
 // @Param request body api.CalculateRequest true "Calculate Request"
 // @Success 200 {object} api.CalculateResponse
 // @Failure 400 {object} api.ErrorResponse
+// @Failure 401 {object} api.ErrorResponse
+// @Failure 403 {object} api.ErrorResponse
+// @Failure 408 {object} api.ErrorResponse
+// @Failure 413 {object} api.ErrorResponse
+// @Failure 429 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /calculate [post]
-func handleCalculate(c *gin.Context) {
+func (s *Server) handleCalculate(c *gin.Context) {
 	var req api.CalculateRequest
 
+	_, span := startSpan(c, "parse", attrFormat.String(parser.FormatJSON))
 	if err := c.ShouldBindJSON(&req); err != nil {
+		endSpan(span, err)
+		if bodyTooLarge(c, err) || s.timedOut(c) {
+			return
+		}
+		observeParseError(c, parser.FormatJSON)
 		badRequest(c, "Invalid request: %v", err)
 		return
 	}
+	span.SetAttributes(attrValues.Int(len(req.Values)))
+	span.End()
+	observeValues(c, parser.FormatJSON, len(req.Values))
+	if s.tooManyValues(c, len(req.Values)) {
+		return
+	}
 
 	// Default percentile to 95 if not provided
 	if req.Percentile == 0 {
 		req.Percentile = defaultPercentile
 	}
 
+	if req.Unit != "" {
+		u, err := parser.ParseUnit(req.Unit)
+		if err != nil {
+			badRequest(c, "Invalid request: %v", err)
+			return
+		}
+		req.Unit = u.Name
+	}
+
 	// Calculate percentile
-	result, err := calculator.CalculatePercentile(req.Values, req.Percentile)
+	_, span = startSpan(c, "calculate", attrValues.Int(len(req.Values)), attrPercentile.Float64(req.Percentile))
+	var result float64
+	err := calculate(c, func(context.Context) (err error) {
+		result, err = s.percentile(req.Values, req.Percentile)
+		return err
+	})
+	endSpan(span, err)
+	if s.timedOut(c) {
+		return
+	}
 	if err != nil {
 		badRequest(c, "%s", err.Error())
 		return
@@ -70,42 +174,152 @@ func handleCalculate(c *gin.Context) {
 		Count:      len(req.Values),
 		Percentile: req.Percentile,
 		Result:     result,
+		Unit:       req.Unit,
 	})
 }
 
 // handleCalculateFile handles POST /calculate/file
 // @Summary Calculate percentile from file
-// @Description Upload a JSON or CSV file and calculate percentile
+// @Description Upload a JSON, NDJSON, CSV, Parquet, Go benchmark, JUnit XML, HAR, OTLP trace or plain-text file (optionally gzip, zstd or bzip2 compressed) and calculate percentile. The format is detected from the filename, the part's Content-Type or the content itself. Repeat the file field to pool several files; per-file results are then reported in sources.
 // @Tags calculate
 // @Accept multipart/form-data
 // @Produce json
-// @Param file formData file true "Data file (JSON or CSV)"
+// @Param file formData file true "Data file (JSON, NDJSON, CSV, Parquet or newline-separated numbers); may be repeated"
 // @Param percentile formData number false "Percentile to calculate (default: 95)"
+// @Param column formData string false "CSV or Parquet column holding the values (default: value)"
+// @Param unit formData string false "Convert values with duration or size suffixes (e.g. 120ms, 3KiB) into this unit"
+// @Param mode formData string false "strict (default) fails on the first invalid record; lenient skips and reports them"
+// @Param non_finite formData string false "NaN and ±Inf handling: reject, drop or clamp (default from server config)"
+// @Param locale formData string false "Number format of the file, e.g. de (1.234,56) or fr (1 234,56)"
+// @Param decimal_separator formData string false "Decimal separator, '.' or ',' (overrides locale)"
+// @Param grouping_separator formData string false "Thousands separator to ignore (overrides locale)"
+// @Param strip_symbols formData boolean false "Strip currency symbols and percent signs from values"
+// @Param metric formData string false "Go benchmark metric to read (default: ns/op)"
+// @Param group_by formData string false "Grouping of labelled inputs: suite, class or name for JUnit reports, host or mime for HAR files, span, service or name for OTLP traces, name for Go benchmarks, or none"
+// @Param har_phase formData string false "HAR timing phase: blocked, dns, connect, ssl, send, wait, receive or total (default)"
+// @Param har_url formData string false "Only include HAR entries whose URL contains this string"
+// @Param har_host formData string false "Only include HAR entries for this host or its subdomains"
+// @Param har_mime formData string false "Only include HAR entries whose response MIME type has this prefix"
+// @Param span_attr formData string false "Only include OTLP spans with this span or resource attribute, as key=value or key; may be repeated"
+// @Param span_status formData string false "Only include OTLP spans with this status: unset, ok or error"
+// @Param log_format formData string false "Parse as an access log: nginx, apache, or a custom format string"
+// @Param log_pattern formData string false "Parse as a log using a regex with a named 'value' group"
+// @Param log_field formData string false "Log format variable holding the value"
+// @Param log_status formData string false "Comma-separated status codes or classes to include (e.g. 200,5xx)"
+// @Param log_path_prefix formData string false "Only include log lines whose request path has this prefix"
 // @Success 200 {object} api.CalculateResponse
 // @Failure 400 {object} api.ErrorResponse
+// @Failure 401 {object} api.ErrorResponse
+// @Failure 403 {object} api.ErrorResponse
+// @Failure 408 {object} api.ErrorResponse
+// @Failure 413 {object} api.ErrorResponse
+// @Failure 429 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /calculate/file [post]
-func handleCalculateFile(c *gin.Context) {
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
+func (s *Server) handleCalculateFile(c *gin.Context) {
+	// Get uploaded files
+	form, err := c.MultipartForm()
 	if err != nil {
+		if bodyTooLarge(c, err) || s.timedOut(c) {
+			return
+		}
 		badRequest(c, "Failed to read file: %v", err)
 		return
 	}
-	defer file.Close()
+	headers := form.File["file"]
+	if len(headers) == 0 {
+		badRequest(c, "Failed to read file: %v", http.ErrMissingFile)
+		return
+	}
+	sources := make([]parser.Source, len(headers))
+	for i, header := range headers {
+		sources[i] = parser.Source{
+			Name:        header.Filename,
+			ContentType: header.Header.Get("Content-Type"),
+			Open: func() (io.ReadCloser, error) {
+				f, err := header.Open()
+				if err != nil {
+					return nil, err
+				}
+				// Stop parsing once the calculation deadline passes
+				return contextReadCloser{ctx: c.Request.Context(), ReadCloser: f}, nil
+			},
+		}
+	}
 
-	// Read file contents
-	data, err := io.ReadAll(file)
+	nonFinite := c.PostForm("non_finite")
+	if nonFinite == "" {
+		nonFinite = s.config.Server.NonFinite
+	}
+	policy, err := parser.ParseNonFinitePolicy(nonFinite)
 	if err != nil {
-		badRequest(c, "Failed to read file contents: %v", err)
+		badRequest(c, "%s", err.Error())
+		return
+	}
+	loc, err := localeOptions(c)
+	if err != nil {
+		badRequest(c, "%s", err.Error())
 		return
 	}
 
-	// Parse values from file
-	values, err := parser.ReadValuesFromBytes(data, header.Filename)
+	// Parse values from the files concurrently, decompressing on the fly
+	_, span := startSpan(c, "parse", attrFiles.Int(len(sources)))
+	results, err := parser.DecodeSources(sources, parser.Options{
+		MaxDecompressedSize: s.config.Server.MaxDecompressedSize,
+		MaxValues:           s.config.Server.MaxValues,
+		Column:              c.PostForm("column"),
+		Unit:                c.PostForm("unit"),
+		Mode:                c.PostForm("mode"),
+		NonFinite:           policy,
+		Locale:              loc,
+		Metric:              c.PostForm("metric"),
+		GroupBy:             c.PostForm("group_by"),
+		AccessLog:           accessLogOptions(c),
+		HAR:                 harOptions(c),
+		OTLP:                otlpOptions(c),
+	})
+	if err != nil {
+		endSpan(span, err)
+		if s.timedOut(c) {
+			return
+		}
+		observeParseError(c, parseErrorFormat(err))
+	}
+	if errors.Is(err, parser.ErrDecompressedSizeExceeded) {
+		c.JSON(http.StatusRequestEntityTooLarge, api.ErrorResponse{
+			Error: fmt.Sprintf("Failed to parse file: %v", err),
+		})
+		return
+	}
+	if errors.Is(err, parser.ErrTooManyValues) {
+		c.JSON(http.StatusRequestEntityTooLarge, api.ErrorResponse{
+			Error: fmt.Sprintf("Too many values: the upload exceeds the limit of %d per request", s.config.Server.MaxValues),
+		})
+		return
+	}
 	if err != nil {
 		badRequest(c, "Failed to parse file: %v", err)
 		return
 	}
+	parsed := results[0]
+	if len(results) > 1 {
+		if parsed, err = parser.Merge(results, 0); err != nil {
+			endSpan(span, err)
+			badRequest(c, "%s", err.Error())
+			return
+		}
+	}
+	span.SetAttributes(
+		attrFormat.String(parsed.Format),
+		attrUnit.String(parsed.Unit),
+		attrValues.Int(len(parsed.Values)),
+		attrSkipped.Int(parsed.Skipped),
+		attrGroups.Int(len(parsed.Groups)),
+	)
+	span.End()
+	observeValues(c, parsed.Format, len(parsed.Values))
 
 	// Get percentile from form or default to 95
 	percentile := defaultPercentile
@@ -119,16 +333,127 @@ func handleCalculateFile(c *gin.Context) {
 		percentile = p
 	}
 
-	// Calculate percentile
-	result, err := calculator.CalculatePercentile(values, percentile)
+	// Calculate the percentile along with per-file and per-group results
+	_, span = startSpan(c, "calculate",
+		attrValues.Int(len(parsed.Values)),
+		attrPercentile.Float64(percentile),
+		attrGroups.Int(len(parsed.Groups)),
+	)
+	var (
+		result    float64
+		perSource []api.SourceResult
+		groups    []api.GroupResult
+	)
+	err = calculate(c, func(ctx context.Context) (err error) {
+		if result, err = s.percentile(parsed.Values, percentile); err != nil {
+			return err
+		}
+		if perSource, err = s.sourceResults(ctx, results, percentile); err != nil {
+			return err
+		}
+		groups, err = s.groupResults(ctx, parsed.Groups, percentile)
+		return err
+	})
+	endSpan(span, err)
+	if s.timedOut(c) {
+		return
+	}
 	if err != nil {
 		badRequest(c, "%s", err.Error())
 		return
 	}
 
 	c.JSON(http.StatusOK, api.CalculateResponse{
-		Count:      len(values),
+		Count:      len(parsed.Values),
 		Percentile: percentile,
 		Result:     result,
+		Format:     parsed.Format,
+		Unit:       parsed.Unit,
+		Skipped:    parsed.Skipped,
+		Errors:     recordErrors(parsed.Errors),
+		Dropped:    parsed.NonFinite.Dropped,
+		Clamped:    parsed.NonFinite.Clamped,
+		Sources:    perSource,
+		Groups:     groups,
 	})
 }
+
+// groupResults reports the percentile and outliers of each labelled series,
+// giving up with the context's error once it is done
+func (s *Server) groupResults(ctx context.Context, groups []parser.Group, percentile float64) ([]api.GroupResult, error) {
+	if len(groups) == 0 {
+		return nil, nil
+	}
+	out := make([]api.GroupResult, len(groups))
+	for i, g := range groups {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		out[i] = api.GroupResult{
+			Name:    g.Name,
+			Count:   len(g.Values),
+			Dropped: g.NonFinite.Dropped,
+			Clamped: g.NonFinite.Clamped,
+		}
+		result, err := s.percentile(g.Values, percentile)
+		if err != nil {
+			out[i].Error = err.Error()
+			continue
+		}
+		o, err := calculator.DetectOutliers(g.Values)
+		if err != nil {
+			out[i].Error = err.Error()
+			continue
+		}
+		out[i].Result = result
+		out[i].Outliers = o.Count()
+		out[i].LowerFence = o.LowerFence
+		out[i].UpperFence = o.UpperFence
+		out[i].OutlierValues = outlierValues(g, o)
+	}
+	return out, nil
+}
+
+// outlierValues lists the named outliers of a group, largest first
+func outlierValues(g parser.Group, o calculator.Outliers) []api.NamedValue {
+	if g.Names == nil {
+		return nil
+	}
+	var out []api.NamedValue
+	for i, v := range g.Values {
+		if o.IsOutlier(v) {
+			out = append(out, api.NamedValue{Name: g.Names[i], Value: v})
+		}
+	}
+	sort.SliceStable(out, func(a, b int) bool { return out[a].Value > out[b].Value })
+	return out
+}
+
+// sourceResults reports the percentile of each uploaded file, or nil when a
+// single file was uploaded, giving up with the context's error once it is done
+func (s *Server) sourceResults(ctx context.Context, results []*parser.Result, percentile float64) ([]api.SourceResult, error) {
+	if len(results) < 2 {
+		return nil, nil
+	}
+	out := make([]api.SourceResult, len(results))
+	for i, res := range results {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		out[i] = api.SourceResult{
+			Source:  res.Source,
+			Format:  res.Format,
+			Count:   len(res.Values),
+			Skipped: res.Skipped,
+			Dropped: res.NonFinite.Dropped,
+			Clamped: res.NonFinite.Clamped,
+		}
+		result, err := s.percentile(res.Values, percentile)
+		if err != nil {
+			out[i].Error = err.Error()
+			continue
+		}
+		out[i].Result = result
+	}
+	return out, nil
+}
@@ -2,18 +2,40 @@ package server
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/parquet-go/parquet-go"
 	"github.com/wingnut128/outlier-go/internal/config"
+	"github.com/wingnut128/outlier-go/internal/logging"
 	"github.com/wingnut128/outlier-go/pkg/api"
 )
 
 func newTestServer() *Server {
-	return NewServer(config.DefaultConfig())
+	srv, err := NewServer(config.DefaultConfig())
+	if err != nil {
+		panic(err)
+	}
+	return srv
+}
+
+func newConfiguredServer(tb testing.TB, cfg *config.Config) *Server {
+	tb.Helper()
+	srv, err := NewServer(cfg)
+	if err != nil {
+		tb.Fatalf("failed to create server: %v", err)
+	}
+	return srv
 }
 
 // --- Health endpoint ---
@@ -69,6 +91,40 @@ func TestHandleCalculate_Success(t *testing.T) {
 	}
 }
 
+func TestHandleCalculate_Unit(t *testing.T) {
+	srv := newTestServer()
+	body := `{"values":[1,2,3],"percentile":50,"unit":"Milliseconds"}`
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/calculate", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Unit != "ms" {
+		t.Errorf("expected unit ms, got %q", resp.Unit)
+	}
+}
+
+func TestHandleCalculate_InvalidUnit(t *testing.T) {
+	srv := newTestServer()
+	body := `{"values":[1,2,3],"unit":"furlongs"}`
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/calculate", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 func TestHandleCalculate_DefaultPercentile(t *testing.T) {
 	srv := newTestServer()
 	body := `{"values":[1,2,3,4,5]}`
@@ -197,6 +253,31 @@ func createMultipartRequest(t *testing.T, filename string, content []byte, perce
 	return req
 }
 
+func createMultipartRequestWithFields(t *testing.T, filename string, content []byte, fields map[string]string) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatalf("failed to write file content: %v", err)
+	}
+
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatalf("failed to write %s field: %v", name, err)
+		}
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/calculate/file", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
 func TestHandleCalculateFile_JSON(t *testing.T) {
 	srv := newTestServer()
 	w := httptest.NewRecorder()
@@ -316,12 +397,636 @@ func TestHandleCalculateFile_EmptyValues(t *testing.T) {
 	}
 }
 
+func TestHandleCalculateFile_SniffedFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		filename    string
+		contentType string
+		content     string
+		wantFormat  string
+	}{
+		{name: "JSON blob", filename: "blob", contentType: "application/octet-stream", content: `[1,2,3]`, wantFormat: "json"},
+		{name: "CSV as data.txt", filename: "data.txt", contentType: "text/plain", content: "value\n1\n2\n3\n", wantFormat: "csv"},
+		{name: "plain numbers", filename: "data.txt", contentType: "text/plain", content: "1\n2\n3\n", wantFormat: "text"},
+		{name: "whitespace separated with comments", filename: "data.txt", contentType: "text/plain", content: "# awk output\n1 2 # two\n3\n", wantFormat: "text"},
+		{name: "content type hint", filename: "blob", contentType: "application/x-ndjson", content: "1\n2\n3\n", wantFormat: "ndjson"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer()
+
+			var buf bytes.Buffer
+			writer := multipart.NewWriter(&buf)
+			h := make(textproto.MIMEHeader)
+			h.Set("Content-Disposition", `form-data; name="file"; filename="`+tt.filename+`"`)
+			h.Set("Content-Type", tt.contentType)
+			part, err := writer.CreatePart(h)
+			if err != nil {
+				t.Fatalf("failed to create part: %v", err)
+			}
+			if _, err := part.Write([]byte(tt.content)); err != nil {
+				t.Fatalf("failed to write part: %v", err)
+			}
+			writer.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/calculate/file", &buf)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			w := httptest.NewRecorder()
+			srv.router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp api.CalculateResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Format != tt.wantFormat {
+				t.Errorf("expected format %q, got %q", tt.wantFormat, resp.Format)
+			}
+			if resp.Count != 3 {
+				t.Errorf("expected count 3, got %d", resp.Count)
+			}
+		})
+	}
+}
+
+func TestHandleCalculateFile_ParquetColumn(t *testing.T) {
+	type row struct {
+		Host      string  `parquet:"host"`
+		LatencyMs float64 `parquet:"latency_ms"`
+	}
+	var data bytes.Buffer
+	rows := []row{{"a", 10}, {"b", 20}, {"c", 30}}
+	if err := parquet.Write(&data, rows); err != nil {
+		t.Fatalf("failed to write Parquet data: %v", err)
+	}
+
+	srv := newTestServer()
+	req := createMultipartRequestWithFields(t, "extract.parquet", data.Bytes(), map[string]string{
+		"column":     "latency_ms",
+		"percentile": "50",
+	})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Format != "parquet" || resp.Count != 3 || resp.Result != 20 {
+		t.Errorf("expected parquet with count 3 and result 20, got %+v", resp)
+	}
+}
+
+func TestHandleCalculateFile_AccessLog(t *testing.T) {
+	log := `10.0.0.1 - - [10/Oct/2025:13:55:36 +0000] "GET /api/a HTTP/1.1" 200 5 "-" "curl" 0.100
+10.0.0.1 - - [10/Oct/2025:13:55:37 +0000] "GET /api/b HTTP/1.1" 500 5 "-" "curl" 0.900
+10.0.0.1 - - [10/Oct/2025:13:55:38 +0000] "GET /health HTTP/1.1" 200 5 "-" "curl" 0.001
+10.0.0.1 - - [10/Oct/2025:13:55:39 +0000] "GET /api/c HTTP/1.1" 204 5 "-" "curl" 0.300
+`
+	srv := newTestServer()
+	req := createMultipartRequestWithFields(t, "access.log", []byte(log), map[string]string{
+		"log_format":      "nginx",
+		"log_status":      "2xx, 3xx",
+		"log_path_prefix": "/api/",
+		"percentile":      "50",
+	})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Format != "accesslog" || resp.Count != 2 || resp.Result != 0.2 {
+		t.Errorf("expected accesslog with count 2 and result 0.2, got %+v", resp)
+	}
+}
+
+func TestHandleCalculateFile_InvalidLogPattern(t *testing.T) {
+	srv := newTestServer()
+	req := createMultipartRequestWithFields(t, "access.log", []byte("x"), map[string]string{
+		"log_pattern": "(?P<value>[",
+	})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleCalculateFile_Units(t *testing.T) {
+	srv := newTestServer()
+	req := createMultipartRequestWithFields(t, "data.csv", []byte("value\n100ms\n0.2s\n300\n"), map[string]string{
+		"unit":       "ms",
+		"percentile": "50",
+	})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Unit != "ms" || resp.Count != 3 || resp.Result != 200 {
+		t.Errorf("expected unit ms with count 3 and result 200, got %+v", resp)
+	}
+}
+
+func TestHandleCalculateFile_InvalidUnit(t *testing.T) {
+	srv := newTestServer()
+	req := createMultipartRequestWithFields(t, "data.csv", []byte("value\n1\n"), map[string]string{
+		"unit": "furlongs",
+	})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleCalculateFile_Lenient(t *testing.T) {
+	srv := newTestServer()
+	req := createMultipartRequestWithFields(t, "data.csv", []byte("host,value\na,10\nb,N/A\nc\nd,30\n"), map[string]string{
+		"mode":       "lenient",
+		"percentile": "50",
+	})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Count != 2 || resp.Result != 20 {
+		t.Errorf("expected count 2 and result 20, got %+v", resp)
+	}
+	if resp.Skipped != 2 || len(resp.Errors) != 2 {
+		t.Fatalf("expected 2 skipped records, got %+v", resp)
+	}
+	if want := (api.RecordError{Line: 3, Column: "value", Value: "N/A", Reason: `invalid number "N/A"`}); resp.Errors[0] != want {
+		t.Errorf("expected %+v, got %+v", want, resp.Errors[0])
+	}
+}
+
+func TestHandleCalculateFile_StrictReportsPosition(t *testing.T) {
+	srv := newTestServer()
+	req := createMultipartRequest(t, "data.csv", []byte("host,value\na,10\nb,N/A\n"), "50")
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !strings.Contains(resp.Error, `line 3, column "value"`) {
+		t.Errorf("expected error with position, got %q", resp.Error)
+	}
+}
+
+func TestHandleCalculateFile_NonFinite(t *testing.T) {
+	content := []byte("value\n10\nNaN\n30\nInf\n")
+
+	tests := []struct {
+		name        string
+		policy      string
+		wantCode    int
+		wantCount   int
+		wantDropped int
+		wantClamped int
+	}{
+		{name: "default rejects", wantCode: http.StatusBadRequest},
+		{name: "drop", policy: "drop", wantCode: http.StatusOK, wantCount: 2, wantDropped: 2},
+		{name: "clamp", policy: "clamp", wantCode: http.StatusOK, wantCount: 3, wantDropped: 1, wantClamped: 1},
+		{name: "unknown policy", policy: "ignore", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer()
+			fields := map[string]string{"percentile": "50"}
+			if tt.policy != "" {
+				fields["non_finite"] = tt.policy
+			}
+			req := createMultipartRequestWithFields(t, "data.csv", content, fields)
+			w := httptest.NewRecorder()
+			srv.router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var resp api.CalculateResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Count != tt.wantCount || resp.Dropped != tt.wantDropped || resp.Clamped != tt.wantClamped {
+				t.Errorf("expected count %d, dropped %d, clamped %d, got %+v", tt.wantCount, tt.wantDropped, tt.wantClamped, resp)
+			}
+		})
+	}
+}
+
+func TestHandleCalculateFile_NonFiniteConfigDefault(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.NonFinite = "drop"
+	srv := newConfiguredServer(t, cfg)
+
+	w := httptest.NewRecorder()
+	req := createMultipartRequest(t, "data.csv", []byte("value\n10\nNaN\n30\n"), "50")
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleCalculateFile_Locale(t *testing.T) {
+	srv := newTestServer()
+	content := []byte("Datum;Betrag\n01.03.2026;1.234,50 €\n02.03.2026;99,50 €\n")
+	req := createMultipartRequestWithFields(t, "export.csv", content, map[string]string{
+		"column":        "Betrag",
+		"locale":        "de_DE",
+		"strip_symbols": "true",
+		"percentile":    "100",
+	})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Count != 2 || resp.Result != 1234.5 {
+		t.Errorf("expected count 2 and result 1234.5, got %+v", resp)
+	}
+}
+
+func TestHandleCalculateFile_InvalidLocale(t *testing.T) {
+	tests := map[string]map[string]string{
+		"unknown locale":        {"locale": "klingon"},
+		"invalid separator":     {"decimal_separator": ";"},
+		"invalid strip_symbols": {"strip_symbols": "maybe"},
+	}
+
+	for name, fields := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := newTestServer()
+			req := createMultipartRequestWithFields(t, "data.csv", []byte("value\n1\n"), fields)
+			w := httptest.NewRecorder()
+			srv.router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func gzipContent(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("failed to gzip content: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestHandleCalculateFile_GzipCSV(t *testing.T) {
+	srv := newTestServer()
+	w := httptest.NewRecorder()
+	req := createMultipartRequest(t, "data.csv.gz", gzipContent(t, []byte("value\n10\n20\n30\n")), "50")
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Count != 3 || resp.Result != 20 {
+		t.Errorf("expected count 3 and result 20, got %d and %f", resp.Count, resp.Result)
+	}
+}
+
+func TestHandleCalculateFile_DecompressionLimit(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.MaxDecompressedSize = 64
+	srv := newConfiguredServer(t, cfg)
+
+	content := append([]byte("value\n"), bytes.Repeat([]byte("1\n"), 1000)...)
+	w := httptest.NewRecorder()
+	req := createMultipartRequest(t, "data.csv.gz", gzipContent(t, content), "50")
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 // --- Server setup ---
 
+func TestHandleCalculateFile_GoBench(t *testing.T) {
+	srv := newTestServer()
+	bench := "goos: linux\n" +
+		"BenchmarkEncode-8\t100\t1000 ns/op\t64 B/op\n" +
+		"BenchmarkEncode-8\t100\t1100 ns/op\t64 B/op\n" +
+		"BenchmarkEncode-8\t100\t1050 ns/op\t64 B/op\n" +
+		"BenchmarkEncode-8\t100\t1020 ns/op\t64 B/op\n" +
+		"BenchmarkEncode-8\t100\t9000 ns/op\t64 B/op\n" +
+		"BenchmarkDecode-8\t100\t500 ns/op\t32 B/op\n"
+	req := createMultipartRequestWithFields(t, "bench.txt", []byte(bench), map[string]string{
+		"percentile": "50",
+	})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Format != "gobench" || resp.Unit != "ns" || resp.Count != 6 {
+		t.Errorf("expected 6 gobench values in ns, got %+v", resp)
+	}
+	if len(resp.Groups) != 2 {
+		t.Fatalf("expected 2 groups, got %+v", resp.Groups)
+	}
+	if g := resp.Groups[0]; g.Name != "BenchmarkEncode-8" || g.Count != 5 || g.Result != 1050 || g.Outliers != 1 {
+		t.Errorf("expected BenchmarkEncode-8 with median 1050 ns and one outlier, got %+v", g)
+	}
+	if g := resp.Groups[1]; g.Name != "BenchmarkDecode-8" || g.Count != 1 || g.Result != 500 {
+		t.Errorf("expected BenchmarkDecode-8 with 500 ns, got %+v", g)
+	}
+
+	req = createMultipartRequestWithFields(t, "bench.txt", []byte(bench), map[string]string{"metric": "B/op"})
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || resp.Unit != "B" || resp.Result != 64 {
+		t.Errorf("expected 64 B, got %d %+v", w.Code, resp)
+	}
+}
+
+func TestHandleCalculateFile_JUnit(t *testing.T) {
+	srv := newTestServer()
+	report := `<testsuites>
+  <testsuite name="api">
+    <testcase classname="api.Users" name="TestA" time="0.10"/>
+    <testcase classname="api.Users" name="TestB" time="0.11"/>
+    <testcase classname="api.Users" name="TestC" time="0.12"/>
+    <testcase classname="api.Users" name="TestD" time="0.13"/>
+    <testcase classname="api.Orders" name="TestSlow" time="4.00"/>
+  </testsuite>
+  <testsuite name="db">
+    <testcase classname="db.Pool" name="TestAcquire" time="0.50"/>
+  </testsuite>
+</testsuites>`
+	req := createMultipartRequestWithFields(t, "report.xml", []byte(report), map[string]string{"percentile": "50"})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Format != "junit" || resp.Unit != "s" || resp.Count != 6 {
+		t.Errorf("expected 6 junit values in s, got %+v", resp)
+	}
+	if len(resp.Groups) != 2 || resp.Groups[0].Name != "api" || resp.Groups[1].Name != "db" {
+		t.Fatalf("expected api and db suites, got %+v", resp.Groups)
+	}
+	want := []api.NamedValue{{Name: "api.Orders.TestSlow", Value: 4}}
+	if g := resp.Groups[0]; g.Outliers != 1 || !slices.Equal(g.OutlierValues, want) {
+		t.Errorf("expected TestSlow as the only outlier, got %+v", g)
+	}
+
+	req = createMultipartRequestWithFields(t, "report.xml", []byte(report), map[string]string{"group_by": "class"})
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	resp = api.CalculateResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Groups) != 3 || resp.Groups[1].Name != "api.Orders" {
+		t.Errorf("expected groups by class, got %+v", resp.Groups)
+	}
+
+	req = createMultipartRequestWithFields(t, "report.xml", []byte(report), map[string]string{"group_by": "host"})
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown grouping, got %d", w.Code)
+	}
+}
+
+func TestHandleCalculateFile_HAR(t *testing.T) {
+	srv := newTestServer()
+	har := `{"log": {"version": "1.2", "entries": [
+  {"time": 100, "request": {"url": "https://a.example.com/"}, "response": {"content": {"mimeType": "text/html"}}, "timings": {"dns": 5, "wait": 80}},
+  {"time": 50, "request": {"url": "https://a.example.com/app.js"}, "response": {"content": {"mimeType": "application/javascript"}}, "timings": {"dns": -1, "wait": 30}},
+  {"time": 300, "request": {"url": "https://b.example.net/api"}, "response": {"content": {"mimeType": "application/json"}}, "timings": {"dns": 20, "wait": 250}}
+]}}`
+	req := createMultipartRequestWithFields(t, "session.har", []byte(har), map[string]string{
+		"percentile": "50",
+		"har_phase":  "wait",
+		"har_host":   "example.com",
+	})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Format != "har" || resp.Unit != "ms" || resp.Count != 2 || resp.Result != 55 {
+		t.Errorf("expected median wait of 55 ms over 2 entries, got %+v", resp)
+	}
+	if len(resp.Groups) != 1 || resp.Groups[0].Name != "a.example.com" {
+		t.Errorf("expected one host group, got %+v", resp.Groups)
+	}
+
+	req = createMultipartRequestWithFields(t, "session.har", []byte(har), map[string]string{"har_phase": "tls"})
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown phase, got %d", w.Code)
+	}
+}
+
+func TestHandleCalculateFile_OTLP(t *testing.T) {
+	srv := newTestServer()
+	traces := `{"resourceSpans": [{"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "api"}}]}, "scopeSpans": [{"spans": [
+  {"traceId": "t1", "spanId": "a", "name": "GET /items", "startTimeUnixNano": "1000000000", "endTimeUnixNano": "1040000000", "attributes": [{"key": "http.request.method", "value": {"stringValue": "GET"}}]},
+  {"traceId": "t2", "spanId": "b", "name": "GET /items", "startTimeUnixNano": "2000000000", "endTimeUnixNano": "2060000000", "attributes": [{"key": "http.request.method", "value": {"stringValue": "GET"}}]},
+  {"traceId": "t3", "spanId": "c", "name": "POST /items", "startTimeUnixNano": "3000000000", "endTimeUnixNano": "3900000000", "attributes": [{"key": "http.request.method", "value": {"stringValue": "POST"}}], "status": {"code": 2}}
+]}]}]}`
+	req := createMultipartRequestWithFields(t, "traces.json", []byte(traces), map[string]string{
+		"percentile": "50",
+		"span_attr":  "http.request.method=GET",
+	})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Format != "otlp" || resp.Unit != "ms" || resp.Count != 2 || resp.Result != 50 {
+		t.Errorf("expected median duration of 50 ms over 2 spans, got %+v", resp)
+	}
+	if len(resp.Groups) != 1 || resp.Groups[0].Name != "api/GET /items" {
+		t.Errorf("expected one service/span group, got %+v", resp.Groups)
+	}
+
+	req = createMultipartRequestWithFields(t, "traces.json", []byte(traces), map[string]string{"span_status": "failed"})
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown status, got %d", w.Code)
+	}
+}
+
+func createMultiFileRequest(t *testing.T, files map[string]string, fields map[string]string) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		part, err := writer.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatalf("failed to create form file: %v", err)
+		}
+		if _, err := part.Write([]byte(files[name])); err != nil {
+			t.Fatalf("failed to write file content: %v", err)
+		}
+	}
+
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatalf("failed to write %s field: %v", name, err)
+		}
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/calculate/file", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestHandleCalculateFile_MultipleFiles(t *testing.T) {
+	srv := newTestServer()
+	req := createMultiFileRequest(t, map[string]string{
+		"a.csv":  "value\n1\n2\nx\n",
+		"b.json": "[3, 4, 5]",
+	}, map[string]string{"percentile": "50", "mode": "lenient"})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Count != 5 || resp.Result != 3 || resp.Format != "mixed" {
+		t.Errorf("expected 5 pooled values with median 3 and mixed format, got %+v", resp)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Source != "a.csv" {
+		t.Errorf("expected one error tagged with a.csv, got %+v", resp.Errors)
+	}
+	want := []api.SourceResult{
+		{Source: "a.csv", Format: "csv", Count: 2, Result: 1.5, Skipped: 1},
+		{Source: "b.json", Format: "json", Count: 3, Result: 4},
+	}
+	if !slices.Equal(resp.Sources, want) {
+		t.Errorf("expected sources %+v, got %+v", want, resp.Sources)
+	}
+}
+
+func TestHandleCalculateFile_MultipleFilesError(t *testing.T) {
+	srv := newTestServer()
+	req := createMultiFileRequest(t, map[string]string{
+		"a.csv": "value\n1\n",
+		"b.csv": "value\nx\n",
+	}, nil)
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "b.csv") {
+		t.Errorf("expected error naming b.csv, got %s", w.Body.String())
+	}
+}
+
 func TestNewServer_DebugMode(t *testing.T) {
 	cfg := config.DefaultConfig()
 	cfg.Logging.Level = "debug"
-	srv := NewServer(cfg)
+	srv := newConfiguredServer(t, cfg)
 	if srv == nil {
 		t.Fatal("expected non-nil server")
 	}
@@ -330,7 +1035,7 @@ func TestNewServer_DebugMode(t *testing.T) {
 func TestNewServer_ReleaseMode(t *testing.T) {
 	cfg := config.DefaultConfig()
 	cfg.Logging.Level = "info"
-	srv := NewServer(cfg)
+	srv := newConfiguredServer(t, cfg)
 	if srv == nil {
 		t.Fatal("expected non-nil server")
 	}
@@ -341,7 +1046,7 @@ func TestNewServer_ReleaseMode(t *testing.T) {
 func TestRequestLogger_DefaultFormat(t *testing.T) {
 	cfg := config.DefaultConfig()
 	cfg.Logging.Format = "text"
-	srv := NewServer(cfg)
+	srv := newConfiguredServer(t, cfg)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/health", http.NoBody)
@@ -355,7 +1060,7 @@ func TestRequestLogger_DefaultFormat(t *testing.T) {
 func TestRequestLogger_JSONFormat(t *testing.T) {
 	cfg := config.DefaultConfig()
 	cfg.Logging.Format = "json"
-	srv := NewServer(cfg)
+	srv := newConfiguredServer(t, cfg)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/health", http.NoBody)
@@ -368,7 +1073,7 @@ func TestRequestLogger_JSONFormat(t *testing.T) {
 
 func TestRequestLogger_WithQueryString(t *testing.T) {
 	cfg := config.DefaultConfig()
-	srv := NewServer(cfg)
+	srv := newConfiguredServer(t, cfg)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/health?foo=bar", http.NoBody)
@@ -378,3 +1083,129 @@ func TestRequestLogger_WithQueryString(t *testing.T) {
 		t.Fatalf("expected 200, got %d", w.Code)
 	}
 }
+
+// captureLogs routes the default logger through a JSON logger writing to a
+// temporary file for the duration of a test, returning a function reading
+// the logged entries
+func captureLogs(t *testing.T) func() []map[string]any {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "outlier.log")
+	logger, closer, err := logging.New(config.LoggingConfig{Level: "info", Format: logging.FormatJSON, Output: logging.OutputFile, File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+		_ = closer.Close()
+	})
+
+	return func() []map[string]any {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries []map[string]any
+		for line := range strings.Lines(string(data)) {
+			var entry map[string]any
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("expected a JSON log line, got %q: %v", line, err)
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+}
+
+func TestRequestLogger_EscapesJSON(t *testing.T) {
+	logs := captureLogs(t)
+	recorder := recordSpans(t)
+	srv := newTestServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/health", http.NoBody)
+	req.URL.RawQuery = `q="quoted"` // broke the hand-built JSON log line
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	entries := logs()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log line, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry["path"] != `/health?q="quoted"` || entry["status"] != float64(http.StatusOK) || entry["level"] != "INFO" {
+		t.Errorf("unexpected entry %v", entry)
+	}
+	if entry[logging.RequestIDKey] != w.Header().Get(requestIDHeader) {
+		t.Errorf("expected request_id %q, got %v", w.Header().Get(requestIDHeader), entry[logging.RequestIDKey])
+	}
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("expected the health check to be untraced, got %d spans", len(spans))
+	}
+}
+
+func TestRequestLogger_TraceIDsAndLevels(t *testing.T) {
+	logs := captureLogs(t)
+	recorder := recordSpans(t)
+	srv := newTestServer()
+
+	req := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(`{"values": []}`))
+	req.Header.Set("Content-Type", "application/json")
+	srv.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log line, got %d", len(entries))
+	}
+	spans := spansByName(recorder)
+	server := spans["POST /calculate"]
+	if server == nil {
+		t.Fatal("expected a server span")
+	}
+	if got := entries[0][logging.TraceIDKey]; got != server.SpanContext().TraceID().String() {
+		t.Errorf("expected trace_id %s, got %v", server.SpanContext().TraceID(), got)
+	}
+	if got := entries[0]["level"]; got != "WARN" {
+		t.Errorf("expected a client error to log at WARN, got %v", got)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	srv := newTestServer()
+
+	tests := []struct {
+		name     string
+		incoming string
+		echoed   bool
+	}{
+		{name: "generated", incoming: ""},
+		{name: "propagated", incoming: "abc-123", echoed: true},
+		{name: "too long", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "control characters", incoming: "abc\x01"},
+		{name: "spaces", incoming: "abc 123"},
+	}
+
+	seen := make(map[string]bool)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/health", http.NoBody)
+			if tt.incoming != "" {
+				req.Header.Set(requestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			srv.router.ServeHTTP(w, req)
+
+			id := w.Header().Get(requestIDHeader)
+			switch {
+			case tt.echoed && id != tt.incoming:
+				t.Errorf("expected %q to be echoed, got %q", tt.incoming, id)
+			case !tt.echoed && (id == "" || id == tt.incoming):
+				t.Errorf("expected a generated request ID, got %q", id)
+			case seen[id]:
+				t.Errorf("expected a unique request ID, got %q twice", id)
+			}
+			seen[id] = true
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// meterName is the instrumentation scope of the server's OTel metrics
+const meterName = "github.com/wingnut128/outlier-go/internal/server"
+
+// Outcomes of a calculation request
+const (
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+)
+
+var attrOutcome = attribute.Key("outlier.outcome")
+
+// instruments holds the OTel metric instruments of a server. Request
+// durations are recorded by otelgin as http.server.request.duration; these
+// cover what the HTTP semantic conventions do not.
+type instruments struct {
+	calculations metric.Int64Counter
+	values       metric.Int64Histogram
+}
+
+// newInstruments creates the instruments from the global meter provider,
+// which is a no-op until telemetry exports metrics
+func newInstruments() *instruments {
+	meter := otel.Meter(meterName)
+	// Creation only fails on invalid names, in which case the instrument is
+	// a no-op
+	calculations, _ := meter.Int64Counter("outlier.calculations",
+		metric.WithDescription("Calculation requests by route, format and outcome."),
+		metric.WithUnit("{calculation}"),
+	)
+	values, _ := meter.Int64Histogram("outlier.calculation.values",
+		metric.WithDescription("Values read per calculation request."),
+		metric.WithUnit("{value}"),
+		metric.WithExplicitBucketBoundaries(1, 10, 100, 1e3, 1e4, 1e5, 1e6, 1e7),
+	)
+	return &instruments{calculations: calculations, values: values}
+}
+
+// middleware records the calculation requests reported by handlers through
+// observeValues and observeParseError
+func (in *instruments) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		format, ok := c.Get(formatKey)
+		if !ok {
+			format, ok = c.Get(parseFormatKey)
+		}
+		if !ok {
+			return
+		}
+		outcome := outcomeSuccess
+		if c.Writer.Status() >= 400 {
+			outcome = outcomeError
+		}
+		attrs := []attribute.KeyValue{
+			attribute.String("http.route", c.FullPath()),
+			attrFormat.String(format.(string)),
+		}
+		ctx := c.Request.Context()
+		in.calculations.Add(ctx, 1, metric.WithAttributes(append(attrs, attrOutcome.String(outcome))...))
+		if n, ok := c.Get(valuesKey); ok {
+			in.values.Record(ctx, int64(n.(int)), metric.WithAttributes(attrs...))
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// recordMetrics installs a global meter provider read on demand for the
+// duration of a test
+func recordMetrics(t *testing.T) *sdkmetric.ManualReader {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(prev) })
+	return reader
+}
+
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	data := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			data[m.Name] = m.Data
+		}
+	}
+	return data
+}
+
+func TestInstruments_Calculations(t *testing.T) {
+	reader := recordMetrics(t)
+	srv := newTestServer()
+
+	for _, body := range []string{`{"values": [1, 2, 3]}`, `{"values": [1, 2, 3, 4]}`, `{"values": `} {
+		req := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		srv.router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	srv.router.ServeHTTP(httptest.NewRecorder(), createMultipartRequest(t, "data.csv", []byte("value\n1\n2\n"), "50"))
+	srv.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", http.NoBody))
+
+	data := collectMetrics(t, reader)
+
+	calculations, ok := data["outlier.calculations"].(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("expected an outlier.calculations sum, got %T", data["outlier.calculations"])
+	}
+	counts := make(map[string]int64)
+	for _, dp := range calculations.DataPoints {
+		route, _ := dp.Attributes.Value("http.route")
+		format, _ := dp.Attributes.Value(attrFormat)
+		outcome, _ := dp.Attributes.Value(attrOutcome)
+		counts[route.AsString()+" "+format.AsString()+" "+outcome.AsString()] = dp.Value
+	}
+	want := map[string]int64{
+		"/calculate json success":     2,
+		"/calculate json error":       1,
+		"/calculate/file csv success": 1,
+	}
+	if len(counts) != len(want) {
+		t.Errorf("expected %v, got %v", want, counts)
+	}
+	for key, n := range want {
+		if counts[key] != n {
+			t.Errorf("expected %d calculations for %s, got %d", n, key, counts[key])
+		}
+	}
+
+	values, ok := data["outlier.calculation.values"].(metricdata.Histogram[int64])
+	if !ok {
+		t.Fatalf("expected an outlier.calculation.values histogram, got %T", data["outlier.calculation.values"])
+	}
+	for _, dp := range values.DataPoints {
+		if dp.Attributes.HasValue(attrOutcome) {
+			t.Error("expected dataset sizes without an outcome")
+		}
+		if route, _ := dp.Attributes.Value("http.route"); route.AsString() == "/calculate" && (dp.Count != 2 || dp.Sum != 7) {
+			t.Errorf("expected 2 JSON datasets of 7 values, got %d of %d", dp.Count, dp.Sum)
+		}
+	}
+
+	if _, ok := data["http.server.request.duration"]; !ok {
+		t.Error("expected request durations from the HTTP instrumentation")
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wingnut128/outlier-go/pkg/api"
+)
+
+// limitBody rejects requests declaring a body over max bytes with 413, and
+// fails reads past max bytes of the others
+func limitBody(max int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > max {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, api.ErrorResponse{
+				Error: fmt.Sprintf("Request body of %d bytes exceeds the limit of %d bytes", c.Request.ContentLength, max),
+			})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max)
+		c.Next()
+	}
+}
+
+// bodyTooLarge answers 413 if err stems from reading a body over the limit
+func bodyTooLarge(c *gin.Context, err error) bool {
+	var maxErr *http.MaxBytesError
+	if !errors.As(err, &maxErr) {
+		return false
+	}
+	c.JSON(http.StatusRequestEntityTooLarge, api.ErrorResponse{
+		Error: fmt.Sprintf("Request body exceeds the limit of %d bytes", maxErr.Limit),
+	})
+	return true
+}
+
+// tooManyValues answers 413 if a request has more values than the limit
+func (s *Server) tooManyValues(c *gin.Context, n int) bool {
+	limit := s.config.Server.MaxValues
+	if limit <= 0 || n <= limit {
+		return false
+	}
+	c.JSON(http.StatusRequestEntityTooLarge, api.ErrorResponse{
+		Error: fmt.Sprintf("Too many values: %d exceeds the limit of %d per request", n, limit),
+	})
+	return true
+}
+
+// calculationDeadline bounds the rest of a request, from reading its body to
+// calculating, by the calculation timeout. Reads of the body and of uploaded
+// files fail once the deadline passes.
+func (s *Server) calculationDeadline() gin.HandlerFunc {
+	timeout := s.config.Server.CalculationTimeout.Duration
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Request.Body = contextReadCloser{ctx: ctx, ReadCloser: c.Request.Body}
+		c.Next()
+	}
+}
+
+// timedOut answers 408 if the request's calculation deadline has passed or
+// the client went away
+func (s *Server) timedOut(c *gin.Context) bool {
+	err := c.Request.Context().Err()
+	if err == nil {
+		return false
+	}
+	msg := "Request canceled"
+	if errors.Is(err, context.DeadlineExceeded) {
+		msg = fmt.Sprintf("Request timeout: the calculation did not finish within %s", s.config.Server.CalculationTimeout)
+	}
+	c.JSON(http.StatusRequestTimeout, api.ErrorResponse{Error: msg})
+	return true
+}
+
+// calculationsKey holds the *sync.WaitGroup of the calculations started for a
+// request, which limitConcurrency waits for before releasing its slot
+const calculationsKey = "outlier.calculations"
+
+// calculate runs fn in the background and waits for it to finish or for the
+// request's context to be done, whichever comes first, so that a request past
+// its deadline is answered at once. An abandoned fn stops at its next check
+// of the context and keeps the request's calculation slot until it returns,
+// so that abandoned work still counts against the concurrency cap.
+func calculate(c *gin.Context, fn func(context.Context) error) error {
+	ctx := c.Request.Context()
+	pending, _ := c.Value(calculationsKey).(*sync.WaitGroup)
+	if pending != nil {
+		pending.Add(1)
+	}
+	done := make(chan error, 1)
+	go func() {
+		if pending != nil {
+			defer pending.Done()
+		}
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// contextReadCloser fails reads with the context's error once it is done
+type contextReadCloser struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (r contextReadCloser) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.ReadCloser.Read(p)
+}
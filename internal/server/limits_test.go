@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wingnut128/outlier-go/internal/calculator"
+	"github.com/wingnut128/outlier-go/internal/config"
+	"github.com/wingnut128/outlier-go/internal/parser"
+	"github.com/wingnut128/outlier-go/pkg/api"
+)
+
+func newLimitServer(t *testing.T, configure func(*config.ServerConfig)) *Server {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	configure(&cfg.Server)
+	return newConfiguredServer(t, cfg)
+}
+
+// expectError checks a response's status and that its ErrorResponse mentions
+// want
+func expectError(t *testing.T, w *httptest.ResponseRecorder, status int, want string) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("expected %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+	var resp api.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("expected an ErrorResponse, got %s", w.Body.String())
+	}
+	if !strings.Contains(resp.Error, want) {
+		t.Errorf("expected an error mentioning %q, got %q", want, resp.Error)
+	}
+}
+
+// unsizedBody hides the length of a body, as with chunked requests
+type unsizedBody struct{ io.Reader }
+
+func postJSON(srv *Server, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/calculate", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMaxBodySize(t *testing.T) {
+	srv := newLimitServer(t, func(c *config.ServerConfig) { c.MaxBodySize = 64 })
+	large := `{"values": [` + strings.Repeat("1, ", 40) + `1]}`
+
+	if w := postJSON(srv, strings.NewReader(`{"values": [1, 2, 3]}`)); w.Code != http.StatusOK {
+		t.Errorf("expected a small body to be accepted, got %d", w.Code)
+	}
+	expectError(t, postJSON(srv, strings.NewReader(large)), http.StatusRequestEntityTooLarge, "exceeds the limit of 64 bytes")
+	expectError(t, postJSON(srv, unsizedBody{strings.NewReader(large)}), http.StatusRequestEntityTooLarge, "exceeds the limit of 64 bytes")
+
+	upload := createMultipartRequest(t, "data.csv", []byte("value\n"+strings.Repeat("1\n", 64)), "")
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, upload)
+	expectError(t, w, http.StatusRequestEntityTooLarge, "exceeds the limit of 64 bytes")
+
+	upload = createMultipartRequest(t, "data.csv", []byte("value\n"+strings.Repeat("1\n", 64)), "")
+	upload.Body = io.NopCloser(unsizedBody{upload.Body})
+	upload.ContentLength = -1
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, upload)
+	expectError(t, w, http.StatusRequestEntityTooLarge, "exceeds the limit of 64 bytes")
+}
+
+func TestMaxValues(t *testing.T) {
+	srv := newLimitServer(t, func(c *config.ServerConfig) { c.MaxValues = 3 })
+
+	if w := postJSON(srv, strings.NewReader(`{"values": [1, 2, 3]}`)); w.Code != http.StatusOK {
+		t.Errorf("expected 3 values to be accepted, got %d", w.Code)
+	}
+	expectError(t, postJSON(srv, strings.NewReader(`{"values": [1, 2, 3, 4]}`)), http.StatusRequestEntityTooLarge, "4 exceeds the limit of 3")
+
+	// Uploads stop being decoded once they exceed the limit, counting every file
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, createMultipartRequest(t, "data.csv", []byte("value\n1\n2\n3\n4\n"), ""))
+	expectError(t, w, http.StatusRequestEntityTooLarge, "the upload exceeds the limit of 3 per request")
+
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, createMultiFileRequest(t, map[string]string{"a.txt": "1\n2\n", "b.txt": "3\n4\n"}, nil))
+	expectError(t, w, http.StatusRequestEntityTooLarge, "the upload exceeds the limit of 3 per request")
+}
+
+// slowReader returns its data after a delay
+type slowReader struct {
+	delay time.Duration
+	r     io.Reader
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.r.Read(p)
+}
+
+func TestCalculationTimeout(t *testing.T) {
+	srv := newLimitServer(t, func(c *config.ServerConfig) {
+		c.CalculationTimeout = config.Duration{Duration: 20 * time.Millisecond}
+	})
+
+	body := &slowReader{delay: 50 * time.Millisecond, r: strings.NewReader(`{"values": [1, 2, 3]}`)}
+	expectError(t, postJSON(srv, unsizedBody{body}), http.StatusRequestTimeout, "did not finish within 20ms")
+
+	upload := createMultipartRequest(t, "data.csv", []byte("value\n1\n2\n"), "")
+	upload.Body = io.NopCloser(&slowReader{delay: 50 * time.Millisecond, r: upload.Body})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, upload)
+	expectError(t, w, http.StatusRequestTimeout, "did not finish within 20ms")
+
+	if w := postJSON(srv, strings.NewReader(`{"values": [1, 2, 3]}`)); w.Code != http.StatusOK {
+		t.Errorf("expected a prompt request to be served, got %d", w.Code)
+	}
+}
+
+func TestCalculationTimeout_AbandonsCalculation(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.CalculationTimeout = config.Duration{Duration: 20 * time.Millisecond}
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Rate = 0
+	cfg.RateLimit.MaxConcurrent = 1
+	srv := newConfiguredServer(t, cfg)
+
+	requests := map[string]func() *httptest.ResponseRecorder{
+		"/calculate": func() *httptest.ResponseRecorder {
+			return postJSON(srv, strings.NewReader(`{"values": [1, 2, 3]}`))
+		},
+		"/calculate/file": func() *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			srv.router.ServeHTTP(w, createMultipartRequest(t, "data.csv", []byte("value\n1\n2\n"), ""))
+			return w
+		},
+	}
+	for route, request := range requests {
+		// The calculation blocks until it is let go
+		started, unblock := make(chan struct{}, 1), make(chan struct{})
+		srv.percentile = func(values []float64, percentile float64) (float64, error) {
+			started <- struct{}{}
+			<-unblock
+			return calculator.CalculatePercentile(values, percentile)
+		}
+
+		start := time.Now()
+		w := request()
+		<-started
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: expected an answer soon after the deadline, took %v", route, elapsed)
+		}
+		expectError(t, w, http.StatusRequestTimeout, "did not finish within 20ms")
+
+		// The abandoned calculation keeps its slot until it returns
+		if n := srv.gate.InProgress(); n != 1 {
+			t.Errorf("%s: expected the running calculation to hold its slot, got %d in progress", route, n)
+		}
+		close(unblock)
+		for deadline := time.Now().Add(time.Second); srv.gate.InProgress() != 0; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: expected the slot to be released once the calculation returned", route)
+			}
+		}
+	}
+}
+
+func TestGroupResults_Canceled(t *testing.T) {
+	srv := newConfiguredServer(t, config.DefaultConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	groups := []parser.Group{{Name: "a", Values: []float64{1, 2, 3}}}
+	if _, err := srv.groupResults(ctx, groups, 50); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from groupResults, got %v", err)
+	}
+	results := []*parser.Result{{Values: []float64{1}}, {Values: []float64{2}}}
+	if _, err := srv.sourceResults(ctx, results, 50); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from sourceResults, got %v", err)
+	}
+}
+
+func TestNewHTTPServer_Timeouts(t *testing.T) {
+	srv := newLimitServer(t, func(c *config.ServerConfig) {
+		c.ReadHeaderTimeout = config.Duration{Duration: time.Second}
+		c.ReadTimeout = config.Duration{Duration: 2 * time.Second}
+		c.WriteTimeout = config.Duration{Duration: 3 * time.Second}
+		c.IdleTimeout = config.Duration{Duration: 4 * time.Second}
+		c.MaxMultipartMemory = 1 << 20
+	})
+
+	hs := srv.newHTTPServer("127.0.0.1:0")
+	if hs.ReadHeaderTimeout != time.Second || hs.ReadTimeout != 2*time.Second || hs.WriteTimeout != 3*time.Second || hs.IdleTimeout != 4*time.Second {
+		t.Errorf("expected the configured timeouts, got %v, %v, %v, %v", hs.ReadHeaderTimeout, hs.ReadTimeout, hs.WriteTimeout, hs.IdleTimeout)
+	}
+	if srv.router.MaxMultipartMemory != 1<<20 {
+		t.Errorf("expected the configured multipart memory, got %d", srv.router.MaxMultipartMemory)
+	}
+}
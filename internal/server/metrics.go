@@ -0,0 +1,176 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/wingnut128/outlier-go/internal/parser"
+	"github.com/wingnut128/outlier-go/internal/ratelimit"
+)
+
+// Context keys handlers use to report request details to the metrics
+// middleware
+const (
+	valuesKey      = "outlier.values"
+	formatKey      = "outlier.format"
+	parseFormatKey = "outlier.parse_error_format"
+)
+
+// unmatchedRoute labels requests that matched no route, keeping the label set
+// bounded
+const unmatchedRoute = "unmatched"
+
+// metrics holds the Prometheus collectors of a server. Each server has its own
+// registry so that servers, such as those created by tests, share no state.
+type metrics struct {
+	registry     *prometheus.Registry
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	inFlight     prometheus.Gauge
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+	values       *prometheus.HistogramVec
+	parseErrors  *prometheus.CounterVec
+	rejections   *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	sizeBuckets := prometheus.ExponentialBuckets(256, 4, 10) // 256 B to 64 MiB
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "outlier",
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "outlier",
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "outlier",
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being served.",
+		}),
+		requestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "outlier",
+			Name:      "http_request_size_bytes",
+			Help:      "HTTP request body sizes by route.",
+			Buckets:   sizeBuckets,
+		}, []string{"route"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "outlier",
+			Name:      "http_response_size_bytes",
+			Help:      "HTTP response body sizes by route.",
+			Buckets:   sizeBuckets,
+		}, []string{"route"}),
+		values: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "outlier",
+			Name:      "values_per_request",
+			Help:      "Values read per calculation request by route.",
+			Buckets:   prometheus.ExponentialBuckets(1, 10, 8), // 1 to 10M
+		}, []string{"route"}),
+		parseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "outlier",
+			Name:      "parse_errors_total",
+			Help:      "Inputs that failed to parse by format.",
+		}, []string{"format"}),
+		rejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "outlier",
+			Name:      "rejected_requests_total",
+			Help:      "Requests rejected by rate or concurrency limits by route and reason.",
+		}, []string{"route", "reason"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests, m.duration, m.inFlight, m.requestSize, m.responseSize, m.values, m.parseErrors, m.rejections,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// observeGate reports the calculations in progress and queued at a gate
+func (m *metrics) observeGate(g *ratelimit.Gate) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "outlier",
+			Name:      "calculations_in_progress",
+			Help:      "Calculations holding one of the concurrent calculation slots.",
+		}, func() float64 { return float64(g.InProgress()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "outlier",
+			Name:      "calculations_queued",
+			Help:      "Calculations waiting for a concurrent calculation slot.",
+		}, func() float64 { return float64(g.Queued()) }),
+	)
+}
+
+// handler serves the collected metrics in the Prometheus exposition format
+func (m *metrics) handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry}))
+}
+
+// middleware records the count, latency and sizes of every request, along with
+// the values, parse errors and rejections reported by handlers
+func (m *metrics) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		m.requests.WithLabelValues(c.Request.Method, route, status).Inc()
+		m.duration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+		if c.Request.ContentLength >= 0 {
+			m.requestSize.WithLabelValues(route).Observe(float64(c.Request.ContentLength))
+		}
+		if size := c.Writer.Size(); size >= 0 {
+			m.responseSize.WithLabelValues(route).Observe(float64(size))
+		}
+		if n, ok := c.Get(valuesKey); ok {
+			m.values.WithLabelValues(route).Observe(float64(n.(int)))
+		}
+		if format, ok := c.Get(parseFormatKey); ok {
+			m.parseErrors.WithLabelValues(format.(string)).Inc()
+		}
+		if reason, ok := c.Get(rejectedKey); ok {
+			m.rejections.WithLabelValues(route, reason.(string)).Inc()
+		}
+	}
+}
+
+// observeValues reports the format and number of values a request calculated
+// over
+func observeValues(c *gin.Context, format string, n int) {
+	c.Set(valuesKey, n)
+	c.Set(formatKey, format)
+}
+
+// observeParseError reports an input of a format that failed to parse
+func observeParseError(c *gin.Context, format string) {
+	c.Set(parseFormatKey, format)
+}
+
+// parseErrorFormat returns the format of an input that failed to decode, or
+// "unknown" when the failure preceded format detection
+func parseErrorFormat(err error) string {
+	var formatErr *parser.FormatError
+	if errors.As(err, &formatErr) {
+		return formatErr.Format
+	}
+	return "unknown"
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wingnut128/outlier-go/internal/config"
+)
+
+func scrapeMetrics(t *testing.T, srv *Server, path string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 from %s, got %d", path, w.Code)
+	}
+	return w.Body.String()
+}
+
+func newMetricsServer(t *testing.T) *Server {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.Metrics.Enabled = true
+	return newConfiguredServer(t, cfg)
+}
+
+func TestMetrics(t *testing.T) {
+	srv := newMetricsServer(t)
+
+	valid := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(`{"values": [1, 2, 3, 4, 5], "percentile": 50}`))
+	valid.Header.Set("Content-Type", "application/json")
+	malformed := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(`{"values": [1, 2`))
+	malformed.Header.Set("Content-Type", "application/json")
+
+	for _, req := range []*http.Request{
+		valid,
+		malformed,
+		createMultipartRequest(t, "data.csv", []byte("value\n1\nabc\n"), ""),
+		httptest.NewRequest(http.MethodGet, "/no/such/route", http.NoBody),
+	} {
+		srv.router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	body := scrapeMetrics(t, srv, "/metrics")
+	for _, want := range []string{
+		`outlier_http_requests_total{method="POST",route="/calculate",status="200"} 1`,
+		`outlier_http_requests_total{method="POST",route="/calculate",status="400"} 1`,
+		`outlier_http_requests_total{method="POST",route="/calculate/file",status="400"} 1`,
+		`outlier_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`outlier_http_request_duration_seconds_count{method="POST",route="/calculate",status="200"} 1`,
+		`outlier_http_requests_in_flight 1`,
+		`outlier_http_request_size_bytes_count{route="/calculate"} 2`,
+		`outlier_http_response_size_bytes_count{route="/calculate/file"} 1`,
+		`outlier_values_per_request_sum{route="/calculate"} 5`,
+		`outlier_parse_errors_total{format="csv"} 1`,
+		`outlier_parse_errors_total{format="json"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+}
+
+func TestMetrics_Config(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Metrics.Enabled = true
+	cfg.Metrics.Path = "/internal/metrics"
+	srv := newConfiguredServer(t, cfg)
+	if body := scrapeMetrics(t, srv, "/internal/metrics"); !strings.Contains(body, "outlier_http_requests_in_flight") {
+		t.Error("expected metrics at the configured path")
+	}
+
+	// Metrics are off by default
+	srv = newTestServer()
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 with metrics disabled, got %d", w.Code)
+	}
+}
+
+func TestNewServer_MetricsPathCollision(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Metrics.Enabled = true
+	cfg.Metrics.Path = "/health"
+	if _, err := NewServer(cfg); err == nil {
+		t.Error("expected error for a metrics path used by the API, got nil")
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wingnut128/outlier-go/internal/auth"
+	"github.com/wingnut128/outlier-go/internal/config"
+	"github.com/wingnut128/outlier-go/internal/ratelimit"
+	"github.com/wingnut128/outlier-go/pkg/api"
+)
+
+// Reasons for rejecting requests, reported to the metrics middleware under
+// rejectedKey
+const (
+	rejectedKey        = "outlier.rejected"
+	reasonRateLimit    = "rate_limit"
+	reasonQueueFull    = "queue_full"
+	reasonQueueTimeout = "queue_timeout"
+)
+
+// busyRetryAfter is the delay suggested to clients turned away because every
+// calculation slot and the queue are taken
+const busyRetryAfter = time.Second
+
+// rateLimits holds the token buckets of each route
+type rateLimits struct {
+	defaults *ratelimit.Limiter // keyed by route and client
+	routes   map[string]*ratelimit.Limiter
+}
+
+// newRateLimits validates the rate limiting settings and creates the limiters
+// of the default and overridden routes
+func newRateLimits(cfg config.RateLimitConfig) (*rateLimits, error) {
+	def := ratelimit.Limit{Rate: cfg.Rate, Burst: cfg.Burst}
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.MaxConcurrent < 0 || cfg.MaxQueue < 0 {
+		return nil, errors.New("max_concurrent and max_queue must not be negative")
+	}
+	if cfg.MaxConcurrent > 0 && cfg.QueueTimeout.Duration <= 0 {
+		return nil, errors.New("queue_timeout must be positive")
+	}
+
+	l := &rateLimits{
+		defaults: ratelimit.NewLimiter(def),
+		routes:   make(map[string]*ratelimit.Limiter),
+	}
+	for _, r := range cfg.Routes {
+		limit := ratelimit.Limit{Rate: r.Rate, Burst: r.Burst}
+		if err := limit.Validate(); err != nil {
+			return nil, fmt.Errorf("route %q: %w", r.Route, err)
+		}
+		if _, ok := l.routes[r.Route]; ok {
+			return nil, fmt.Errorf("route %q is configured twice", r.Route)
+		}
+		l.routes[r.Route] = ratelimit.NewLimiter(limit)
+	}
+	return l, nil
+}
+
+// checkRoutes reports overrides of routes the router does not serve
+func (l *rateLimits) checkRoutes(routes gin.RoutesInfo) error {
+	for route := range l.routes {
+		if !slices.ContainsFunc(routes, func(r gin.RouteInfo) bool { return r.Path == route }) {
+			return fmt.Errorf("route %q matches no route of the server", route)
+		}
+	}
+	return nil
+}
+
+// clientKey identifies the client of a request by its authenticated name, or
+// else its IP address
+func clientKey(c *gin.Context) string {
+	if p := auth.PrincipalFromContext(c.Request.Context()); p != nil {
+		return "principal:" + p.Name
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// rateLimit rejects requests of clients that used up the token bucket of
+// their route with 429
+func rateLimit(l *rateLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+
+		limiter, key := l.routes[route], clientKey(c)
+		if limiter == nil {
+			limiter, key = l.defaults, route+" "+key
+		}
+		if ok, retry := limiter.Allow(key); !ok {
+			tooManyRequests(c, retry)
+			return
+		}
+		c.Next()
+	}
+}
+
+// limitFailedAuth counts requests failing authentication against the
+// default token bucket of the client's IP address. Clients that used it up
+// get 429 before their credentials are checked, so credentials cannot be
+// guessed faster than the default rate.
+func limitFailedAuth(l *rateLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := "auth ip:" + c.ClientIP()
+		if ok, retry := l.defaults.Check(key); !ok {
+			tooManyRequests(c, retry)
+			return
+		}
+		c.Next()
+		if c.Writer.Status() == http.StatusUnauthorized {
+			l.defaults.Allow(key)
+		}
+	}
+}
+
+// tooManyRequests rejects a request with 429, to be retried after retry
+func tooManyRequests(c *gin.Context, retry time.Duration) {
+	c.Set(rejectedKey, reasonRateLimit)
+	c.Header("Retry-After", retryAfter(retry))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, api.ErrorResponse{
+		Error: fmt.Sprintf("Rate limit exceeded: retry after %s", retry.Round(time.Millisecond)),
+	})
+}
+
+// limitConcurrency holds a calculation slot of the gate for the rest of the
+// request, answering 503 when none frees up in time
+func limitConcurrency(g *ratelimit.Gate) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		release, err := g.Acquire(c.Request.Context())
+		if err != nil {
+			reason := reasonQueueTimeout
+			if errors.Is(err, ratelimit.ErrQueueFull) {
+				reason = reasonQueueFull
+			}
+			c.Set(rejectedKey, reason)
+			c.Header("Retry-After", retryAfter(busyRetryAfter))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, api.ErrorResponse{
+				Error: fmt.Sprintf("Server busy: %v", err),
+			})
+			return
+		}
+		pending := new(sync.WaitGroup)
+		c.Set(calculationsKey, pending)
+		c.Next()
+		// Calculations abandoned at their deadline keep the slot until they
+		// return, without holding up the response
+		go func() {
+			pending.Wait()
+			release()
+		}()
+	}
+}
+
+// retryAfter formats a delay as Retry-After seconds, rounded up
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(d.Seconds()))))
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wingnut128/outlier-go/internal/auth"
+	"github.com/wingnut128/outlier-go/internal/config"
+	"github.com/wingnut128/outlier-go/pkg/api"
+)
+
+func newRateLimitServer(t *testing.T, configure func(*config.RateLimitConfig)) *Server {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.RateLimit.Enabled = true
+	cfg.Metrics.Enabled = true
+	configure(&cfg.RateLimit)
+	return newConfiguredServer(t, cfg)
+}
+
+// calculateFrom posts a calculation from a client address
+func calculateFrom(srv *Server, remoteAddr string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(`{"values": [1, 2, 3]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteAddr
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	return w
+}
+
+func expectRejected(t *testing.T, w *httptest.ResponseRecorder, status int, retryAfter string) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("expected %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Retry-After"); got != retryAfter {
+		t.Errorf("expected Retry-After %q, got %q", retryAfter, got)
+	}
+	var resp api.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+		t.Errorf("expected an ErrorResponse, got %s", w.Body.String())
+	}
+}
+
+func TestRateLimit_PerClient(t *testing.T) {
+	srv := newRateLimitServer(t, func(c *config.RateLimitConfig) {
+		c.Rate = 0.5
+		c.Burst = 2
+	})
+
+	for i := range 2 {
+		if w := calculateFrom(srv, "192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("expected request %d of the burst to succeed, got %d", i+1, w.Code)
+		}
+	}
+	expectRejected(t, calculateFrom(srv, "192.0.2.1:1234"), http.StatusTooManyRequests, "2")
+
+	if w := calculateFrom(srv, "192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected another client to be served, got %d", w.Code)
+	}
+
+	// Each route has its own bucket
+	req := httptest.NewRequest(http.MethodGet, "/health", http.NoBody)
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected /health to be served, got %d", w.Code)
+	}
+
+	body := scrapeMetrics(t, srv, "/metrics")
+	for _, want := range []string{
+		`outlier_rejected_requests_total{reason="rate_limit",route="/calculate"} 1`,
+		`outlier_http_requests_total{method="POST",route="/calculate",status="429"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+}
+
+func TestRateLimit_IgnoresForwardedForFromUntrustedPeers(t *testing.T) {
+	srv := newRateLimitServer(t, func(c *config.RateLimitConfig) {
+		c.Rate = 1
+		c.Burst = 1
+	})
+
+	if w := calculateFrom(srv, "192.0.2.1:1234", "X-Forwarded-For", "198.51.100.1"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	// A new forwarded address per request does not give the client a new bucket
+	expectRejected(t, calculateFrom(srv, "192.0.2.1:1234", "X-Forwarded-For", "198.51.100.2"), http.StatusTooManyRequests, "1")
+	expectRejected(t, calculateFrom(srv, "192.0.2.1:1234", "X-Real-IP", "198.51.100.3"), http.StatusTooManyRequests, "1")
+}
+
+func TestRateLimit_TrustedProxy(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.TrustedProxies = []string{"192.0.2.0/24"}
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Rate = 1
+	cfg.RateLimit.Burst = 1
+	srv := newConfiguredServer(t, cfg)
+
+	// Clients behind the proxy are limited by their forwarded address
+	if w := calculateFrom(srv, "192.0.2.1:1234", "X-Forwarded-For", "198.51.100.1"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if w := calculateFrom(srv, "192.0.2.1:1234", "X-Forwarded-For", "198.51.100.2"); w.Code != http.StatusOK {
+		t.Fatalf("expected another client behind the proxy to be served, got %d", w.Code)
+	}
+	expectRejected(t, calculateFrom(srv, "192.0.2.1:1234", "X-Forwarded-For", "198.51.100.1"), http.StatusTooManyRequests, "1")
+}
+
+func TestNewServer_InvalidTrustedProxies(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.TrustedProxies = []string{"not-an-ip"}
+	if _, err := NewServer(cfg); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestRateLimit_RouteOverride(t *testing.T) {
+	srv := newRateLimitServer(t, func(c *config.RateLimitConfig) {
+		c.Rate = 1
+		c.Burst = 1
+		c.Routes = []config.RouteRateLimitConfig{{Route: "/calculate"}}
+	})
+
+	for range 5 {
+		if w := calculateFrom(srv, "192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("expected the unlimited route to be served, got %d", w.Code)
+		}
+	}
+}
+
+func TestRateLimit_ByPrincipal(t *testing.T) {
+	t.Setenv("OUTLIER_JWT_SECRET", "")
+	t.Setenv("OUTLIER_API_KEYS", "a:"+auth.HashKey("key-a")+":calculate;b:"+auth.HashKey("key-b")+":calculate")
+	cfg := config.DefaultConfig()
+	cfg.Auth.Enabled = true
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Rate = 1
+	cfg.RateLimit.Burst = 1
+	srv := newConfiguredServer(t, cfg)
+
+	// Clients behind one address are limited separately by key
+	if w := calculateFrom(srv, "192.0.2.1:1234", auth.APIKeyHeader, "key-a"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if w := calculateFrom(srv, "192.0.2.1:1234", auth.APIKeyHeader, "key-b"); w.Code != http.StatusOK {
+		t.Fatalf("expected another key to be served, got %d", w.Code)
+	}
+	expectRejected(t, calculateFrom(srv, "192.0.2.2:1234", auth.APIKeyHeader, "key-a"), http.StatusTooManyRequests, "1")
+}
+
+func TestRateLimit_FailedAuth(t *testing.T) {
+	t.Setenv("OUTLIER_JWT_SECRET", "")
+	t.Setenv("OUTLIER_API_KEYS", "a:"+auth.HashKey("key-a")+":calculate")
+	cfg := config.DefaultConfig()
+	cfg.Auth.Enabled = true
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Rate = 1
+	cfg.RateLimit.Burst = 2
+	srv := newConfiguredServer(t, cfg)
+
+	for i := range 2 {
+		if w := calculateFrom(srv, "192.0.2.1:1234", auth.APIKeyHeader, "guess"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected guess %d to get 401, got %d", i+1, w.Code)
+		}
+	}
+	// Once its failures used up the bucket, the address is turned away
+	// before its credentials are checked, even valid ones
+	expectRejected(t, calculateFrom(srv, "192.0.2.1:1234", auth.APIKeyHeader, "guess"), http.StatusTooManyRequests, "1")
+	expectRejected(t, calculateFrom(srv, "192.0.2.1:1234", auth.APIKeyHeader, "key-a"), http.StatusTooManyRequests, "1")
+
+	// Other addresses and successful requests are unaffected
+	if w := calculateFrom(srv, "192.0.2.2:1234", auth.APIKeyHeader, "key-a"); w.Code != http.StatusOK {
+		t.Fatalf("expected another address to be served, got %d", w.Code)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	srv := newRateLimitServer(t, func(c *config.RateLimitConfig) {
+		c.Rate = 0
+		c.MaxConcurrent = 1
+		c.MaxQueue = 0
+	})
+
+	// Take the only calculation slot
+	release, err := srv.gate.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRejected(t, calculateFrom(srv, "192.0.2.1:1234"), http.StatusServiceUnavailable, "1")
+	if body := scrapeMetrics(t, srv, "/metrics"); !strings.Contains(body, "outlier_calculations_in_progress 1") ||
+		!strings.Contains(body, `outlier_rejected_requests_total{reason="queue_full",route="/calculate"} 1`) {
+		t.Errorf("expected the slot and the rejection in metrics, got %s", body)
+	}
+
+	release()
+	if w := calculateFrom(srv, "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected 200 once the slot was released, got %d", w.Code)
+	}
+}
+
+func TestConcurrencyLimit_QueueTimeout(t *testing.T) {
+	srv := newRateLimitServer(t, func(c *config.RateLimitConfig) {
+		c.MaxConcurrent = 1
+		c.MaxQueue = 1
+		c.QueueTimeout = config.Duration{Duration: 10 * time.Millisecond}
+	})
+
+	release, err := srv.gate.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	expectRejected(t, calculateFrom(srv, "192.0.2.1:1234"), http.StatusServiceUnavailable, "1")
+
+	// Health checks take no slot
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", http.NoBody))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected /health to be served, got %d", w.Code)
+	}
+}
+
+func TestNewServer_InvalidRateLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*config.RateLimitConfig)
+	}{
+		{name: "negative rate", configure: func(c *config.RateLimitConfig) { c.Rate = -1 }},
+		{name: "zero burst", configure: func(c *config.RateLimitConfig) { c.Burst = 0 }},
+		{name: "negative concurrency", configure: func(c *config.RateLimitConfig) { c.MaxConcurrent = -1 }},
+		{name: "zero queue timeout", configure: func(c *config.RateLimitConfig) { c.QueueTimeout = config.Duration{} }},
+		{name: "route with zero burst", configure: func(c *config.RateLimitConfig) {
+			c.Routes = []config.RouteRateLimitConfig{{Route: "/calculate", Rate: 1}}
+		}},
+		{name: "duplicate route", configure: func(c *config.RateLimitConfig) {
+			c.Routes = []config.RouteRateLimitConfig{{Route: "/calculate"}, {Route: "/calculate"}}
+		}},
+		{name: "unknown route", configure: func(c *config.RateLimitConfig) {
+			c.Routes = []config.RouteRateLimitConfig{{Route: "/calculate/files"}}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.RateLimit.Enabled = true
+			tt.configure(&cfg.RateLimit)
+			if _, err := NewServer(cfg); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
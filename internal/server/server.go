@@ -2,31 +2,49 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	_ "github.com/wingnut128/outlier-go/docs" // swagger docs
+	"github.com/wingnut128/outlier-go/internal/auth"
+	"github.com/wingnut128/outlier-go/internal/calculator"
 	"github.com/wingnut128/outlier-go/internal/config"
+	"github.com/wingnut128/outlier-go/internal/logging"
+	"github.com/wingnut128/outlier-go/internal/ratelimit"
+	"github.com/wingnut128/outlier-go/internal/telemetry"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // Server represents the HTTP server
 type Server struct {
-	config *config.Config
-	router *gin.Engine
+	config  *config.Config
+	router  *gin.Engine
+	logger  *slog.Logger
+	metrics *metrics      // nil when the metrics endpoint is disabled
+	tls     *certReloader // nil when serving plain HTTP
+	// gate caps concurrent calculations; nil when they are unbounded
+	gate *ratelimit.Gate
+	// percentile calculates percentiles, replaced in tests to slow them down
+	percentile func(values []float64, percentile float64) (float64, error)
 }
 
 // NewServer creates a new HTTP server with the given configuration
-func NewServer(cfg *config.Config) *Server {
+func NewServer(cfg *config.Config) (*Server, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Set Gin mode based on log level
 	if cfg.Logging.Level == "debug" || cfg.Logging.Level == "trace" {
 		gin.SetMode(gin.DebugMode)
@@ -35,42 +53,170 @@ func NewServer(cfg *config.Config) *Server {
 	}
 
 	router := gin.New()
+	logger := slog.Default()
+
+	// Forwarded client IPs are only believed from trusted proxies, so that
+	// clients cannot pick the IP they are logged and rate limited by
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted_proxies: %w", err)
+	}
+
+	// Metrics come first so that requests recovered from panics are counted
+	var m *metrics
+	if cfg.Metrics.Enabled {
+		m = newMetrics()
+		router.Use(m.middleware())
+	}
 
 	// Add middleware
 	router.Use(gin.Recovery())
-	router.Use(requestLogger(cfg))
+	router.Use(otelgin.Middleware(telemetry.ServiceName(),
+		otelgin.WithPropagators(telemetry.Propagator()),
+		otelgin.WithFilter(func(r *http.Request) bool {
+			// Skip probes and scrapes, which would drown out real traffic
+			return r.URL.Path != "/health" && r.URL.Path != cfg.Metrics.Path
+		}),
+	))
+	router.Use(newInstruments().middleware())
+	router.Use(requestID())
+	router.Use(requestLogger(logger))
 
-	// CORS configuration
-	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowAllOrigins = true
-	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
-	router.Use(cors.New(corsConfig))
+	// CORS answers preflight requests before they reach authentication
+	corsMiddleware, err := newCORS(cfg.CORS)
+	if err != nil {
+		return nil, err
+	}
+	if corsMiddleware != nil {
+		router.Use(corsMiddleware)
+	}
 
-	// Body size limit (100MB)
-	router.MaxMultipartMemory = 100 << 20 // 100 MB
+	// Uploads beyond the memory limit are spooled to temporary files
+	router.MaxMultipartMemory = cfg.Server.MaxMultipartMemory
+	if cfg.Server.MaxBodySize > 0 {
+		router.Use(limitBody(cfg.Server.MaxBodySize))
+	}
 
 	s := &Server{
-		config: cfg,
-		router: router,
+		config:     cfg,
+		router:     router,
+		logger:     logger,
+		metrics:    m,
+		percentile: calculator.CalculatePercentile,
+	}
+
+	if cfg.Server.TLS.Enabled {
+		s.tls, err = newCertReloader(cfg.Server.TLS, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure TLS: %w", err)
+		}
+	}
+
+	var limits *rateLimits
+	if cfg.RateLimit.Enabled {
+		limits, err = newRateLimits(cfg.RateLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure rate limiting: %w", err)
+		}
+	}
+
+	// Authentication follows CORS so that preflight requests, which carry no
+	// credentials, are answered. Failed attempts are limited by IP address
+	// first, so that credentials cannot be guessed at will.
+	if cfg.Auth.Enabled {
+		if len(cfg.Auth.Clients) > 0 && (s.tls == nil || cfg.Server.TLS.ClientAuth == "none") {
+			return nil, errors.New("auth clients require TLS with client_auth optional or require")
+		}
+		authenticator, err := auth.New(cfg.Auth, os.Getenv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure authentication: %w", err)
+		}
+		if limits != nil {
+			router.Use(limitFailedAuth(limits))
+		}
+		router.Use(s.authenticate(authenticator))
+	}
+
+	// Rate limits follow authentication so that authenticated clients are
+	// limited by name rather than IP address
+	if limits != nil {
+		router.Use(rateLimit(limits))
+		if cfg.RateLimit.MaxConcurrent > 0 {
+			s.gate = ratelimit.NewGate(cfg.RateLimit.MaxConcurrent, cfg.RateLimit.MaxQueue, cfg.RateLimit.QueueTimeout.Duration)
+			if m != nil {
+				m.observeGate(s.gate)
+			}
+		}
 	}
 
 	s.setupRoutes()
-	return s
+	if limits != nil {
+		if err := limits.checkRoutes(router.Routes()); err != nil {
+			return nil, fmt.Errorf("failed to configure rate limiting: %w", err)
+		}
+	}
+	return s, nil
 }
 
 // setupRoutes configures all HTTP routes
 func (s *Server) setupRoutes() {
 	s.router.GET("/health", handleHealth)
-	s.router.POST("/calculate", handleCalculate)
-	s.router.POST("/calculate/file", handleCalculateFile)
+	// Calculations wait for a slot when their concurrency is capped
+	calculate := s.router.Group("")
+	if s.gate != nil {
+		calculate.Use(limitConcurrency(s.gate))
+	}
+	// The deadline starts once a slot is taken
+	if s.config.Server.CalculationTimeout.Duration > 0 {
+		calculate.Use(s.calculationDeadline())
+	}
+	calculate.POST("/calculate", s.handleCalculate)
+	calculate.POST("/calculate/file", s.handleCalculateFile)
+
+	if s.metrics != nil {
+		s.router.GET(s.config.Metrics.Path, s.metrics.handler())
+	}
 
 	// Swagger documentation
 	s.router.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }
 
-// requestLogger creates a custom logger middleware based on config
-func requestLogger(cfg *config.Config) gin.HandlerFunc {
+// requestIDHeader carries the ID correlating a request's log lines
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the incoming request IDs that are propagated
+const maxRequestIDLength = 128
+
+// requestID propagates the request's X-Request-ID, or a generated one if it
+// has none or an invalid one, into the response and the request's context
+func requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = rand.Text()
+		}
+		c.Header(requestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// validRequestID reports whether an incoming request ID is safe to echo and
+// log: non-empty, bounded and printable ASCII without spaces
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestLogger logs every request once served, at warn level for client
+// errors and error level for server errors
+func requestLogger(logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
@@ -78,50 +224,80 @@ func requestLogger(cfg *config.Config) gin.HandlerFunc {
 
 		c.Next()
 
-		latency := time.Since(start)
-		statusCode := c.Writer.Status()
-		method := c.Request.Method
-		clientIP := c.ClientIP()
-
 		if raw != "" {
 			path = path + "?" + raw
 		}
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
 
-		// Format log based on config
-		switch cfg.Logging.Format {
-		case "json":
-			log.Printf(`{"time":"%s","status":%d,"latency":"%s","ip":"%s","method":"%s","path":"%s"}`,
-				start.Format(time.RFC3339),
-				statusCode,
-				latency,
-				clientIP,
-				method,
-				path,
-			)
-		default:
-			log.Printf("[%s] %d %s %s %s %s",
-				start.Format("2006-01-02 15:04:05"),
-				statusCode,
-				latency,
-				clientIP,
-				method,
-				path,
-			)
+		attrs := []slog.Attr{
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("ip", c.ClientIP()),
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
 		}
+		if p := auth.PrincipalFromContext(c.Request.Context()); p != nil {
+			attrs = append(attrs, slog.String("principal", p.Name))
+		}
+		if name := auth.ClientCertName(c.Request.TLS); name != "" {
+			attrs = append(attrs, slog.String("client_cert", name))
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
 	}
 }
 
+// newHTTPServer creates the HTTP server serving the router
+func (s *Server) newHTTPServer(addr string) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.router,
+		ReadHeaderTimeout: s.config.Server.ReadHeaderTimeout.Duration,
+		ReadTimeout:       s.config.Server.ReadTimeout.Duration,
+		WriteTimeout:      s.config.Server.WriteTimeout.Duration,
+		IdleTimeout:       s.config.Server.IdleTimeout.Duration,
+		// Connection errors such as failed TLS handshakes are warnings
+		ErrorLog: slog.NewLogLogger(s.logger.Handler(), slog.LevelWarn),
+	}
+	if s.tls != nil {
+		srv.TLSConfig = s.tls.config()
+	}
+	return srv
+}
+
+// serve serves requests from a listener, over TLS when configured, until the
+// server is shut down
+func (s *Server) serve(srv *http.Server, ln net.Listener) error {
+	if s.tls != nil {
+		return srv.ServeTLS(ln, "", "")
+	}
+	return srv.Serve(ln)
+}
+
 // Start starts the HTTP server
 func (s *Server) Start() error {
 	addr := fmt.Sprintf("%s:%d", s.config.Server.BindIP, s.config.Server.Port)
+	srv := s.newHTTPServer(addr)
 
-	srv := &http.Server{
-		Addr:              addr,
-		Handler:           s.router,
-		ReadHeaderTimeout: 10 * time.Second,
-		ReadTimeout:       30 * time.Second,
-		WriteTimeout:      30 * time.Second,
-		IdleTimeout:       120 * time.Second,
+	scheme := "http"
+	if s.tls != nil {
+		scheme = "https"
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if err := s.tls.watch(ctx); err != nil {
+			return fmt.Errorf("failed to watch TLS certificates: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("server error: %w", err)
 	}
 
 	// Channel to listen for errors from the server
@@ -129,8 +305,8 @@ func (s *Server) Start() error {
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Outlier API server listening on http://%s\n", addr)
-		serverErrors <- srv.ListenAndServe()
+		s.logger.Info("Outlier API server listening", "address", scheme+"://"+addr)
+		serverErrors <- s.serve(srv, ln)
 	}()
 
 	// Channel to listen for interrupt signals
@@ -144,16 +320,16 @@ func (s *Server) Start() error {
 			return fmt.Errorf("server error: %w", err)
 		}
 	case sig := <-shutdown:
-		log.Printf("Received signal %v, shutting down gracefully...\n", sig)
+		s.logger.Info("Received signal, shutting down gracefully", "signal", sig.String())
 
 		// Graceful shutdown with timeout
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.Server.ShutdownTimeout.Duration)
 		defer cancel()
 
 		if err := srv.Shutdown(ctx); err != nil {
 			return fmt.Errorf("server shutdown error: %w", err)
 		}
-		log.Println("Server stopped gracefully")
+		s.logger.Info("Server stopped gracefully")
 	}
 
 	return nil
@@ -22,7 +22,7 @@ func TestStress_ConcurrentAPIRequests(t *testing.T) {
 	}
 
 	cfg := config.DefaultConfig()
-	srv := NewServer(cfg)
+	srv := newConfiguredServer(t, cfg)
 
 	const (
 		numGoroutines        = 50
@@ -106,7 +106,7 @@ func TestStress_LargePayloads(t *testing.T) {
 	}
 
 	cfg := config.DefaultConfig()
-	srv := NewServer(cfg)
+	srv := newConfiguredServer(t, cfg)
 
 	sizes := []int{1_000, 10_000, 100_000, 1_000_000}
 
@@ -162,7 +162,7 @@ func TestStress_MixedOperations(t *testing.T) {
 	}
 
 	cfg := config.DefaultConfig()
-	srv := NewServer(cfg)
+	srv := newConfiguredServer(t, cfg)
 
 	const (
 		numGoroutines          = 30
@@ -251,7 +251,7 @@ func TestStress_MixedOperations(t *testing.T) {
 // BenchmarkHealthEndpoint benchmarks the health endpoint
 func BenchmarkHealthEndpoint(b *testing.B) {
 	cfg := config.DefaultConfig()
-	srv := NewServer(cfg)
+	srv := newConfiguredServer(b, cfg)
 
 	httpReq := httptest.NewRequest("GET", "/health", http.NoBody)
 
@@ -265,7 +265,7 @@ func BenchmarkHealthEndpoint(b *testing.B) {
 // BenchmarkCalculateEndpoint benchmarks the calculate endpoint
 func BenchmarkCalculateEndpoint(b *testing.B) {
 	cfg := config.DefaultConfig()
-	srv := NewServer(cfg)
+	srv := newConfiguredServer(b, cfg)
 
 	sizes := []int{100, 1_000, 10_000}
 
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/wingnut128/outlier-go/internal/config"
+)
+
+// tlsVersions maps the supported min_version settings to TLS versions
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// clientAuthTypes maps the client_auth settings to client certificate policies
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":     tls.NoClientCert,
+	"optional": tls.VerifyClientCertIfGiven,
+	"require":  tls.RequireAndVerifyClientCert,
+}
+
+// reloadDelay batches the file events of a certificate rotation, such as the
+// certificate and key being written one after the other, into one reload
+const reloadDelay = 100 * time.Millisecond
+
+// certReloader holds the TLS configuration of the current certificate, key
+// and client CA bundle, and reloads it when their files change
+type certReloader struct {
+	cfg     config.TLSConfig
+	base    *tls.Config // settings other than the certificate and client CAs
+	current atomic.Pointer[tls.Config]
+	logger  *slog.Logger
+}
+
+// newCertReloader validates the TLS settings and loads the certificate, key
+// and client CA bundle
+func newCertReloader(cfg config.TLSConfig, logger *slog.Logger) (*certReloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("TLS requires cert_file and key_file")
+	}
+	minVersion, ok := tlsVersions[cfg.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS min_version %q (supported: 1.2, 1.3)", cfg.MinVersion)
+	}
+	clientAuth, ok := clientAuthTypes[cfg.ClientAuth]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS client_auth %q (supported: none, optional, require)", cfg.ClientAuth)
+	}
+	if clientAuth != tls.NoClientCert && cfg.ClientCAFile == "" {
+		return nil, fmt.Errorf("TLS client_auth %q requires client_ca_file", cfg.ClientAuth)
+	}
+	suites, err := cipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &certReloader{
+		cfg: cfg,
+		base: &tls.Config{
+			MinVersion:   minVersion,
+			CipherSuites: suites,
+			ClientAuth:   clientAuth,
+			NextProtos:   []string{"h2", "http/1.1"},
+		},
+		logger: logger,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// cipherSuites returns the IDs of TLS 1.2 cipher suites named as in Go's
+// crypto/tls, rejecting those Go considers insecure
+func cipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(tls.CipherSuites(), func(s *tls.CipherSuite) bool { return s.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+		}
+		suite := tls.CipherSuites()[i]
+		if !slices.Contains(suite.SupportedVersions, tls.VersionTLS12) {
+			return nil, fmt.Errorf("TLS cipher suite %q is not configurable: TLS 1.3 suites are fixed", name)
+		}
+		ids = append(ids, suite.ID)
+	}
+	return ids, nil
+}
+
+// reload loads the certificate, key and client CA bundle into a new
+// configuration, leaving the current one in place on failure
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	c := r.base.Clone()
+	c.Certificates = []tls.Certificate{cert}
+
+	if r.base.ClientAuth != tls.NoClientCert {
+		data, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("client CA file %s contains no PEM certificates", r.cfg.ClientCAFile)
+		}
+		c.ClientCAs = pool
+	}
+
+	r.current.Store(c)
+	return nil
+}
+
+// config returns the TLS configuration of the server, which resolves each
+// handshake to the configuration current at that time
+func (r *certReloader) config() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.base.MinVersion,
+		NextProtos: r.base.NextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// watch reloads the configuration when its files change, until ctx is done.
+// The files' directories are watched rather than the files themselves so that
+// files replaced by a rename, as with Kubernetes secret volumes, are followed.
+func (r *certReloader) watch(ctx context.Context) error {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.base.ClientAuth != tls.NoClientCert {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	watched := make(map[string]bool)
+	for i, file := range files {
+		files[i] = filepath.Clean(file)
+		dir := filepath.Dir(files[i])
+		if watched[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+		watched[dir] = true
+	}
+
+	go func() {
+		defer func() { _ = watcher.Close() }()
+		var pending <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// Kubernetes swaps the ..data symlink the files point through
+				if slices.Contains(files, filepath.Clean(event.Name)) || filepath.Base(event.Name) == "..data" {
+					pending = time.After(reloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.logger.Warn("TLS certificate watcher error", "error", err)
+			case <-pending:
+				pending = nil
+				if err := r.reload(); err != nil {
+					r.logger.Error("Failed to reload TLS certificate, keeping the previous one", "error", err)
+					continue
+				}
+				r.logger.Info("TLS certificate reloaded", "cert_file", r.cfg.CertFile)
+			}
+		}
+	}()
+	return nil
+}
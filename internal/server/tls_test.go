@@ -0,0 +1,366 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wingnut128/outlier-go/internal/auth"
+	"github.com/wingnut128/outlier-go/internal/config"
+)
+
+// testCA issues certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// issue returns the PEM certificate and key of a server certificate for
+// 127.0.0.1, or of a client certificate when client is set
+func (ca *testCA) issue(t *testing.T, commonName string, serial int64, client bool) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if client {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		template.IPAddresses = nil
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// clientCert returns a client certificate issued by ca
+func (ca *testCA) clientCert(t *testing.T, commonName string) tls.Certificate {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, commonName, 2, true)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tlsConfig writes a server certificate issued by ca and returns TLS settings
+// serving it, verifying client certificates against ca
+func tlsConfig(t *testing.T, ca *testCA, clientAuth string) config.TLSConfig {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.DefaultConfig().Server.TLS
+	cfg.Enabled = true
+	cfg.CertFile = filepath.Join(dir, "tls.crt")
+	cfg.KeyFile = filepath.Join(dir, "tls.key")
+	cfg.ClientAuth = clientAuth
+	cfg.ClientCAFile = filepath.Join(dir, "ca.crt")
+
+	certPEM, keyPEM := ca.issue(t, "outlier", 1, false)
+	writeFile(t, cfg.CertFile, certPEM)
+	writeFile(t, cfg.KeyFile, keyPEM)
+	writeFile(t, cfg.ClientCAFile, ca.pem)
+	return cfg
+}
+
+// serveTLS serves srv on a loopback port as Start does and returns its URL
+func serveTLS(t *testing.T, srv *Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := srv.newHTTPServer(ln.Addr().String())
+	go func() { _ = srv.serve(hs, ln) }()
+	t.Cleanup(func() { _ = hs.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := srv.tls.watch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return "https://" + ln.Addr().String()
+}
+
+// tlsClient trusts roots, presents cert if given, even to a server that does
+// not accept its issuer, and opens a connection per request
+func tlsClient(roots *x509.CertPool, cert ...tls.Certificate) *http.Client {
+	c := &tls.Config{RootCAs: roots}
+	if len(cert) > 0 {
+		c.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &cert[0], nil
+		}
+	}
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig:   c,
+			DisableKeepAlives: true,
+			ForceAttemptHTTP2: true,
+		},
+	}
+}
+
+func TestTLS_ServesAndReloadsCertificate(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	cfg := config.DefaultConfig()
+	cfg.Server.TLS = tlsConfig(t, ca, "none")
+	url := serveTLS(t, newConfiguredServer(t, cfg))
+	client := tlsClient(ca.pool())
+
+	serial := func() int64 {
+		t.Helper()
+		resp, err := client.Get(url + "/health")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		if resp.ProtoMajor != 2 {
+			t.Errorf("expected HTTP/2, got %s", resp.Proto)
+		}
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+	if got := serial(); got != 1 {
+		t.Fatalf("expected certificate 1, got %d", got)
+	}
+
+	certPEM, keyPEM := ca.issue(t, "outlier", 2, false)
+	writeFile(t, cfg.Server.TLS.CertFile, certPEM)
+	writeFile(t, cfg.Server.TLS.KeyFile, keyPEM)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for serial() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the rotated certificate to be served")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestTLS_FailedReloadKeepsCertificate(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	cfg := tlsConfig(t, ca, "none")
+	r, err := newCertReloader(cfg, slog.Default())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	before := r.current.Load()
+
+	writeFile(t, cfg.KeyFile, []byte("not a key"))
+	if err := r.reload(); err == nil {
+		t.Error("expected a mismatched key to fail")
+	}
+	if r.current.Load() != before {
+		t.Error("expected the previous certificate to be kept")
+	}
+}
+
+func TestTLS_ClientCertificates(t *testing.T) {
+	logs := captureLogs(t)
+	t.Setenv("OUTLIER_API_KEYS", "")
+	t.Setenv("OUTLIER_JWT_SECRET", "")
+	ca := newTestCA(t, "test CA")
+	cfg := config.DefaultConfig()
+	cfg.Server.TLS = tlsConfig(t, ca, "require")
+	cfg.Auth.Enabled = true
+	cfg.Auth.Clients = []config.ClientCertConfig{{Name: "billing", Scopes: []string{auth.ScopeCalculate}}}
+	url := serveTLS(t, newConfiguredServer(t, cfg))
+
+	calculate := func(client *http.Client) (int, error) {
+		resp, err := client.Post(url+"/calculate", "application/json", strings.NewReader(`{"values": [1, 2, 3]}`))
+		if err != nil {
+			return 0, err
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	if code, err := calculate(tlsClient(ca.pool(), ca.clientCert(t, "billing"))); err != nil || code != http.StatusOK {
+		t.Errorf("expected 200 for the billing certificate, got %d, %v", code, err)
+	}
+	if code, err := calculate(tlsClient(ca.pool(), ca.clientCert(t, "stranger"))); err != nil || code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an unauthorized certificate, got %d, %v", code, err)
+	}
+	if _, err := calculate(tlsClient(ca.pool())); err == nil {
+		t.Error("expected the handshake to fail without a client certificate")
+	}
+	other := newTestCA(t, "other CA")
+	if _, err := calculate(tlsClient(ca.pool(), other.clientCert(t, "billing"))); err == nil {
+		t.Error("expected the handshake to fail with a certificate from another CA")
+	}
+
+	var entries []map[string]any
+	for _, entry := range logs() {
+		if entry["msg"] == "request" {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) != 2 || entries[0]["principal"] != "billing" || entries[0]["client_cert"] != "billing" || entries[1]["client_cert"] != "stranger" {
+		t.Errorf("expected the client certificates logged, got %v", entries)
+	}
+}
+
+func TestTLS_OptionalClientCertificates(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	cfg := config.DefaultConfig()
+	cfg.Server.TLS = tlsConfig(t, ca, "optional")
+	url := serveTLS(t, newConfiguredServer(t, cfg))
+
+	resp, err := tlsClient(ca.pool()).Get(url + "/health")
+	if err != nil {
+		t.Fatalf("expected a connection without a client certificate, got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	other := newTestCA(t, "other CA")
+	if _, err := tlsClient(ca.pool(), other.clientCert(t, "billing")).Get(url + "/health"); err == nil {
+		t.Error("expected an unverifiable client certificate to be rejected")
+	}
+}
+
+func TestTLS_MinVersionAndCipherSuites(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	cfg := config.DefaultConfig()
+	cfg.Server.TLS = tlsConfig(t, ca, "none")
+	cfg.Server.TLS.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}
+	url := serveTLS(t, newConfiguredServer(t, cfg))
+
+	connect := func(c *tls.Config) error {
+		c.RootCAs = ca.pool()
+		conn, err := tls.Dial("tcp", strings.TrimPrefix(url, "https://"), c)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	if err := connect(&tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}}); err != nil {
+		t.Errorf("expected the configured cipher suite to be accepted, got %v", err)
+	}
+	if err := connect(&tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}}); err == nil {
+		t.Error("expected another cipher suite to be rejected")
+	}
+	if err := connect(&tls.Config{MaxVersion: tls.VersionTLS11}); err == nil {
+		t.Error("expected TLS 1.1 to be rejected")
+	}
+
+	cfg.Server.TLS.MinVersion = "1.3"
+	cfg.Server.TLS.CipherSuites = nil
+	url = serveTLS(t, newConfiguredServer(t, cfg))
+	if err := connect(&tls.Config{MaxVersion: tls.VersionTLS12}); err == nil {
+		t.Error("expected TLS 1.2 to be rejected with min_version 1.3")
+	}
+	if err := connect(&tls.Config{}); err != nil {
+		t.Errorf("expected TLS 1.3 to be accepted, got %v", err)
+	}
+}
+
+func TestNewServer_InvalidTLS(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	tests := []struct {
+		name      string
+		configure func(*config.Config)
+	}{
+		{name: "missing certificate", configure: func(c *config.Config) { c.Server.TLS.CertFile = "" }},
+		{name: "unreadable certificate", configure: func(c *config.Config) { c.Server.TLS.CertFile = filepath.Join(t.TempDir(), "missing") }},
+		{name: "unsupported version", configure: func(c *config.Config) { c.Server.TLS.MinVersion = "1.1" }},
+		{name: "unknown client auth", configure: func(c *config.Config) { c.Server.TLS.ClientAuth = "always" }},
+		{name: "client auth without CA", configure: func(c *config.Config) {
+			c.Server.TLS.ClientAuth = "require"
+			c.Server.TLS.ClientCAFile = ""
+		}},
+		{name: "CA without certificates", configure: func(c *config.Config) {
+			c.Server.TLS.ClientAuth = "require"
+			c.Server.TLS.ClientCAFile = c.Server.TLS.KeyFile
+		}},
+		{name: "unknown cipher suite", configure: func(c *config.Config) { c.Server.TLS.CipherSuites = []string{"TLS_FAST"} }},
+		{name: "insecure cipher suite", configure: func(c *config.Config) { c.Server.TLS.CipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"} }},
+		{name: "TLS 1.3 cipher suite", configure: func(c *config.Config) { c.Server.TLS.CipherSuites = []string{"TLS_AES_128_GCM_SHA256"} }},
+		{name: "auth clients without client auth", configure: func(c *config.Config) {
+			c.Auth.Enabled = true
+			c.Auth.Clients = []config.ClientCertConfig{{Name: "billing"}}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Server.TLS = tlsConfig(t, ca, "none")
+			tt.configure(cfg)
+			if _, err := NewServer(cfg); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
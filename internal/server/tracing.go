@@ -0,0 +1,40 @@
+package server
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the spans created by handlers
+const tracerName = "github.com/wingnut128/outlier-go/internal/server"
+
+// Attributes describing the parse and calculate spans
+const (
+	attrFormat     = attribute.Key("outlier.format")
+	attrUnit       = attribute.Key("outlier.unit")
+	attrFiles      = attribute.Key("outlier.files")
+	attrValues     = attribute.Key("outlier.values.count")
+	attrSkipped    = attribute.Key("outlier.values.skipped")
+	attrGroups     = attribute.Key("outlier.groups")
+	attrPercentile = attribute.Key("outlier.percentile")
+)
+
+// startSpan starts a child of the request's span. The tracer is looked up on
+// every call so that spans follow the current global tracer provider.
+func startSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(c.Request.Context(), name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends a span, recording err as its error status if set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a global tracer provider recording every span for the
+// duration of a test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return recorder
+}
+
+func spansByName(recorder *tracetest.SpanRecorder) map[string]sdktrace.ReadOnlySpan {
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	return spans
+}
+
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing_Calculate(t *testing.T) {
+	recorder := recordSpans(t)
+	srv := newTestServer()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(`{"values": [1, 2, 3, 4, 5], "percentile": 90}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	spans := spansByName(recorder)
+	server, ok := spans["POST /calculate"]
+	if !ok {
+		t.Fatalf("expected a server span, got %v", spans)
+	}
+	if got := server.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("expected the incoming trace %s to continue, got %s", traceID, got)
+	}
+	if got := server.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("expected the incoming span as parent, got %s", got)
+	}
+	if got := spanAttr(server, "http.route").AsString(); got != "/calculate" {
+		t.Errorf("expected http.route /calculate, got %q", got)
+	}
+	if got := spanAttr(server, "http.response.status_code").AsInt64(); got != http.StatusOK {
+		t.Errorf("expected http.response.status_code 200, got %d", got)
+	}
+
+	for _, name := range []string{"parse", "calculate"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("expected a %s span", name)
+		}
+		if span.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("expected %s to be a child of the server span", name)
+		}
+		if got := spanAttr(span, attrValues).AsInt64(); got != 5 {
+			t.Errorf("expected %s to report 5 values, got %d", name, got)
+		}
+	}
+	if got := spanAttr(spans["calculate"], attrPercentile).AsFloat64(); got != 90 {
+		t.Errorf("expected percentile 90, got %v", got)
+	}
+}
+
+func TestTracing_CalculateFile(t *testing.T) {
+	recorder := recordSpans(t)
+	srv := newTestServer()
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, createMultipartRequest(t, "data.csv", []byte("value\n1\n2\n3\n"), "50"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	parse := spansByName(recorder)["parse"]
+	if parse == nil {
+		t.Fatal("expected a parse span")
+	}
+	if got := spanAttr(parse, attrFormat).AsString(); got != "csv" {
+		t.Errorf("expected format csv, got %q", got)
+	}
+	if got := spanAttr(parse, attrFiles).AsInt64(); got != 1 {
+		t.Errorf("expected 1 file, got %d", got)
+	}
+
+	recorder.Reset()
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, createMultipartRequest(t, "data.csv", []byte("value\nabc\n"), ""))
+	spans := spansByName(recorder)
+	if spans["parse"] == nil || spans["parse"].Status().Code != codes.Error {
+		t.Error("expected the parse span to record the error")
+	}
+	if spans["POST /calculate/file"] == nil || spans["POST /calculate/file"].Status().Code != codes.Unset {
+		t.Error("expected a server span without error status for a 400 response")
+	}
+}
+
+func TestTracing_SkipsHealthAndMetrics(t *testing.T) {
+	recorder := recordSpans(t)
+	srv := newMetricsServer(t)
+
+	for _, path := range []string{"/health", "/metrics"} {
+		srv.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, http.NoBody))
+	}
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("expected no spans, got %d", len(spans))
+	}
+}
@@ -0,0 +1,119 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Stdout and file exporters write JSON to out, one object per export; OTLP
+// exporters use the OTLP settings of their signal.
+
+// otlpOptions holds the option constructors of an OTLP exporter package
+type otlpOptions[O any] struct {
+	headers     func(map[string]string) O
+	endpoint    func(string) O
+	endpointURL func(string) O
+	insecure    func() O
+	gzip        O
+}
+
+// build returns the exporter options of a signal's OTLP settings
+func (opts otlpOptions[O]) build(s settings, signal string) []O {
+	o := s.otlp[signal]
+	options := []O{opts.headers(o.headers)}
+	if endpoint := s.signalEndpoint(signal); strings.Contains(endpoint, "://") {
+		options = append(options, opts.endpointURL(endpoint))
+	} else if endpoint != "" {
+		options = append(options, opts.endpoint(endpoint))
+	}
+	if o.insecure {
+		options = append(options, opts.insecure())
+	}
+	if o.compression == "gzip" {
+		options = append(options, opts.gzip)
+	}
+	return options
+}
+
+// newTraceExporter creates the span exporter selected by the settings
+func newTraceExporter(ctx context.Context, s settings, out io.Writer) (sdktrace.SpanExporter, error) {
+	if out != nil {
+		return stdouttrace.New(stdouttrace.WithWriter(out))
+	}
+	if s.otlp[SignalTraces].protocol == ProtocolHTTP {
+		return otlptracehttp.New(ctx, otlpOptions[otlptracehttp.Option]{
+			headers:     otlptracehttp.WithHeaders,
+			endpoint:    otlptracehttp.WithEndpoint,
+			endpointURL: otlptracehttp.WithEndpointURL,
+			insecure:    otlptracehttp.WithInsecure,
+			gzip:        otlptracehttp.WithCompression(otlptracehttp.GzipCompression),
+		}.build(s, SignalTraces)...)
+	}
+	return otlptracegrpc.New(ctx, otlpOptions[otlptracegrpc.Option]{
+		headers:     otlptracegrpc.WithHeaders,
+		endpoint:    otlptracegrpc.WithEndpoint,
+		endpointURL: otlptracegrpc.WithEndpointURL,
+		insecure:    otlptracegrpc.WithInsecure,
+		gzip:        otlptracegrpc.WithCompressor("gzip"),
+	}.build(s, SignalTraces)...)
+}
+
+// newMetricExporter creates the metric exporter selected by the settings
+func newMetricExporter(ctx context.Context, s settings, out io.Writer) (sdkmetric.Exporter, error) {
+	if out != nil {
+		return stdoutmetric.New(stdoutmetric.WithWriter(out))
+	}
+	if s.otlp[SignalMetrics].protocol == ProtocolHTTP {
+		return otlpmetrichttp.New(ctx, otlpOptions[otlpmetrichttp.Option]{
+			headers:     otlpmetrichttp.WithHeaders,
+			endpoint:    otlpmetrichttp.WithEndpoint,
+			endpointURL: otlpmetrichttp.WithEndpointURL,
+			insecure:    otlpmetrichttp.WithInsecure,
+			gzip:        otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression),
+		}.build(s, SignalMetrics)...)
+	}
+	return otlpmetricgrpc.New(ctx, otlpOptions[otlpmetricgrpc.Option]{
+		headers:     otlpmetricgrpc.WithHeaders,
+		endpoint:    otlpmetricgrpc.WithEndpoint,
+		endpointURL: otlpmetricgrpc.WithEndpointURL,
+		insecure:    otlpmetricgrpc.WithInsecure,
+		gzip:        otlpmetricgrpc.WithCompressor("gzip"),
+	}.build(s, SignalMetrics)...)
+}
+
+// newLogExporter creates the log record exporter selected by the settings
+func newLogExporter(ctx context.Context, s settings, out io.Writer) (sdklog.Exporter, error) {
+	if out != nil {
+		return stdoutlog.New(stdoutlog.WithWriter(out))
+	}
+	if s.otlp[SignalLogs].protocol == ProtocolHTTP {
+		return otlploghttp.New(ctx, otlpOptions[otlploghttp.Option]{
+			headers:     otlploghttp.WithHeaders,
+			endpoint:    otlploghttp.WithEndpoint,
+			endpointURL: otlploghttp.WithEndpointURL,
+			insecure:    otlploghttp.WithInsecure,
+			gzip:        otlploghttp.WithCompression(otlploghttp.GzipCompression),
+		}.build(s, SignalLogs)...)
+	}
+	return otlploggrpc.New(ctx, otlpOptions[otlploggrpc.Option]{
+		headers:     otlploggrpc.WithHeaders,
+		endpoint:    otlploggrpc.WithEndpoint,
+		endpointURL: otlploggrpc.WithEndpointURL,
+		insecure:    otlploggrpc.WithInsecure,
+		gzip:        otlploggrpc.WithCompressor("gzip"),
+	}.build(s, SignalLogs)...)
+}
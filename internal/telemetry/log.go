@@ -0,0 +1,140 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+// logScope is the instrumentation scope of bridged log records
+const logScope = "github.com/wingnut128/outlier-go"
+
+// LogHandler returns a slog handler emitting records to the global OTel
+// logger provider. Records logged with a context carrying a span are
+// correlated with its trace. Without exported logs, the handler is disabled.
+func LogHandler() slog.Handler {
+	return &logHandler{}
+}
+
+type logHandler struct {
+	attrs []otellog.KeyValue
+	// groups is the open group prefix of attributes added after WithGroup
+	groups []string
+}
+
+func (h *logHandler) logger() otellog.Logger {
+	return global.GetLoggerProvider().Logger(logScope)
+}
+
+func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.logger().Enabled(ctx, otellog.EnabledParameters{Severity: severity(level)})
+}
+
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	var record otellog.Record
+	record.SetTimestamp(r.Time)
+	record.SetObservedTimestamp(time.Now())
+	record.SetSeverity(severity(r.Level))
+	record.SetSeverityText(r.Level.String())
+	record.SetBody(otellog.StringValue(r.Message))
+
+	attrs := make([]otellog.KeyValue, 0, len(h.attrs)+r.NumAttrs())
+	attrs = append(attrs, h.attrs...)
+	var own []otellog.KeyValue
+	r.Attrs(func(a slog.Attr) bool {
+		own = appendAttr(own, a)
+		return true
+	})
+	attrs = append(attrs, nest(h.groups, own)...)
+	record.AddAttributes(attrs...)
+
+	// The logger reads the span from ctx to correlate the record
+	h.logger().Emit(ctx, record)
+	return nil
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var own []otellog.KeyValue
+	for _, a := range attrs {
+		own = appendAttr(own, a)
+	}
+	clone := *h
+	clone.attrs = append(append([]otellog.KeyValue(nil), h.attrs...), nest(h.groups, own)...)
+	return &clone
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.groups = append(append([]string(nil), h.groups...), name)
+	return &clone
+}
+
+// severity maps a slog level to an OTel severity: DEBUG, INFO, WARN and
+// ERROR line up with the first severity of their range
+func severity(level slog.Level) otellog.Severity {
+	return otellog.Severity(level - slog.LevelInfo + slog.Level(otellog.SeverityInfo))
+}
+
+// nest wraps attributes in the open groups, innermost last
+func nest(groups []string, kvs []otellog.KeyValue) []otellog.KeyValue {
+	if len(kvs) == 0 {
+		return nil
+	}
+	for i := len(groups) - 1; i >= 0; i-- {
+		kvs = []otellog.KeyValue{{Key: groups[i], Value: otellog.MapValue(kvs...)}}
+	}
+	return kvs
+}
+
+// appendAttr converts a slog attribute, skipping empty ones and inlining
+// groups without a key as slog does
+func appendAttr(kvs []otellog.KeyValue, a slog.Attr) []otellog.KeyValue {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return kvs
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		var members []otellog.KeyValue
+		for _, member := range a.Value.Group() {
+			members = appendAttr(members, member)
+		}
+		if a.Key == "" {
+			return append(kvs, members...)
+		}
+		if len(members) == 0 {
+			return kvs
+		}
+		return append(kvs, otellog.KeyValue{Key: a.Key, Value: otellog.MapValue(members...)})
+	}
+	return append(kvs, otellog.KeyValue{Key: a.Key, Value: value(a.Value)})
+}
+
+func value(v slog.Value) otellog.Value {
+	switch v.Kind() {
+	case slog.KindString:
+		return otellog.StringValue(v.String())
+	case slog.KindInt64:
+		return otellog.Int64Value(v.Int64())
+	case slog.KindUint64:
+		return otellog.Int64Value(int64(v.Uint64()))
+	case slog.KindFloat64:
+		return otellog.Float64Value(v.Float64())
+	case slog.KindBool:
+		return otellog.BoolValue(v.Bool())
+	case slog.KindDuration:
+		return otellog.Int64Value(int64(v.Duration()))
+	case slog.KindTime:
+		return otellog.StringValue(v.Time().Format(time.RFC3339Nano))
+	}
+	if err, ok := v.Any().(error); ok {
+		return otellog.StringValue(err.Error())
+	}
+	return otellog.StringValue(fmt.Sprint(v.Any()))
+}
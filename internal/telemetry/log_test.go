@@ -0,0 +1,117 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// recordingProcessor keeps every emitted log record
+type recordingProcessor struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (p *recordingProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = append(p.records, r.Clone())
+	return nil
+}
+
+func (p *recordingProcessor) Enabled(context.Context, sdklog.EnabledParameters) bool { return true }
+func (p *recordingProcessor) Shutdown(context.Context) error                         { return nil }
+func (p *recordingProcessor) ForceFlush(context.Context) error                       { return nil }
+
+// recordLogs installs a global logger provider recording every log record for
+// the duration of a test
+func recordLogs(t *testing.T) *recordingProcessor {
+	t.Helper()
+	p := &recordingProcessor{}
+	prev := global.GetLoggerProvider()
+	global.SetLoggerProvider(sdklog.NewLoggerProvider(sdklog.WithProcessor(p)))
+	t.Cleanup(func() { global.SetLoggerProvider(prev) })
+	return p
+}
+
+func attrs(r sdklog.Record) map[string]otellog.Value {
+	m := make(map[string]otellog.Value)
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		m[kv.Key] = kv.Value
+		return true
+	})
+	return m
+}
+
+func TestLogHandler(t *testing.T) {
+	p := recordLogs(t)
+	logger := slog.New(LogHandler()).With("service", "outlier")
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "request")
+	logger.WithGroup("req").WarnContext(ctx, "slow request",
+		slog.Int("status", 200),
+		slog.Group("client", slog.String("ip", "10.0.0.1")),
+		slog.Any("err", errors.New("timeout")),
+	)
+	span.End()
+	logger.Info("no span")
+
+	if len(p.records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(p.records))
+	}
+	r := p.records[0]
+	if got := r.Body().AsString(); got != "slow request" {
+		t.Errorf("expected body %q, got %q", "slow request", got)
+	}
+	if r.Severity() != otellog.SeverityWarn || r.SeverityText() != "WARN" {
+		t.Errorf("expected severity WARN, got %v %q", r.Severity(), r.SeverityText())
+	}
+	if r.TraceID() != span.SpanContext().TraceID() || r.SpanID() != span.SpanContext().SpanID() {
+		t.Error("expected the record to be correlated with the span")
+	}
+
+	got := attrs(r)
+	if got["service"].AsString() != "outlier" {
+		t.Errorf("expected service attribute outlier, got %v", got["service"])
+	}
+	req := make(map[string]otellog.Value)
+	for _, kv := range got["req"].AsMap() {
+		req[kv.Key] = kv.Value
+	}
+	if req["status"].AsInt64() != 200 || req["err"].AsString() != "timeout" {
+		t.Errorf("expected grouped status and err attributes, got %v", got["req"])
+	}
+	if client := req["client"].AsMap(); len(client) != 1 || client[0].Value.AsString() != "10.0.0.1" {
+		t.Errorf("expected a nested client group, got %v", req["client"])
+	}
+
+	if p.records[1].TraceID().IsValid() {
+		t.Error("expected no trace ID without a span")
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  otellog.Severity
+	}{
+		{slog.LevelDebug, otellog.SeverityDebug},
+		{slog.LevelInfo, otellog.SeverityInfo},
+		{slog.LevelWarn, otellog.SeverityWarn},
+		{slog.LevelError, otellog.SeverityError},
+		{slog.LevelError + 1, otellog.SeverityError2},
+	}
+
+	for _, tt := range tests {
+		if got := severity(tt.level); got != tt.want {
+			t.Errorf("%v: expected %v, got %v", tt.level, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package telemetry
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/wingnut128/outlier-go/internal/config"
+)
+
+// Exporters
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterNone   = "none"
+)
+
+// OTLP protocols
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+// Signals
+const (
+	SignalTraces  = "traces"
+	SignalMetrics = "metrics"
+	SignalLogs    = "logs"
+)
+
+// PresetHoneycomb sends OTLP over gRPC to Honeycomb, authenticated by the
+// HONEYCOMB_API_KEY environment variable
+const PresetHoneycomb = "honeycomb"
+
+const (
+	honeycombEndpoint = "api.honeycomb.io:443"
+	honeycombHeader   = "x-honeycomb-team"
+)
+
+var (
+	exporters    = []string{ExporterOTLP, ExporterStdout, ExporterFile, ExporterNone}
+	protocols    = []string{ProtocolGRPC, ProtocolHTTP}
+	compressions = []string{"gzip", "none"}
+	signals      = []string{SignalTraces, SignalMetrics, SignalLogs}
+)
+
+// settings is a telemetry configuration with its preset and the OTEL_*
+// environment variables applied
+type settings struct {
+	serviceName string
+	// exporters maps each exported signal to its exporter
+	exporters   map[string]string
+	otlp        map[string]otlpSettings
+	sampleRatio float64
+	file        string
+}
+
+// otlpSettings are the OTLP options of one signal
+type otlpSettings struct {
+	// endpoint is a base host:port or URL, to which HTTP exporters append
+	// the signal's path; signalEndpoint, when set, is used as is
+	endpoint       string
+	signalEndpoint string
+	protocol       string
+	headers        map[string]string
+	insecure       bool
+	compression    string
+}
+
+// resolve applies the preset and environment variables to a telemetry
+// configuration and validates the result. Environment variables take
+// precedence over the configuration, and signal-specific variables such as
+// OTEL_EXPORTER_OTLP_TRACES_PROTOCOL over their general form; setting an
+// OTLP endpoint or HONEYCOMB_API_KEY enables export when the configuration
+// names no exporter.
+func resolve(cfg config.TelemetryConfig, getenv func(string) string) (settings, error) {
+	s := settings{
+		serviceName: cfg.ServiceName,
+		exporters:   make(map[string]string),
+		otlp:        make(map[string]otlpSettings),
+		sampleRatio: cfg.SampleRatio,
+		file:        cfg.File,
+	}
+	exporter := strings.ToLower(cfg.Exporter)
+	base := otlpSettings{
+		endpoint:    cfg.Endpoint,
+		protocol:    strings.ToLower(cfg.Protocol),
+		headers:     maps.Clone(cfg.Headers),
+		insecure:    cfg.Insecure,
+		compression: strings.ToLower(cfg.Compression),
+	}
+	if base.headers == nil {
+		base.headers = make(map[string]string)
+	}
+	enabled := make(map[string]bool)
+	for _, signal := range cfg.Signals {
+		signal = strings.ToLower(signal)
+		if !slices.Contains(signals, signal) {
+			return settings{}, fmt.Errorf("unknown telemetry signal %q (supported: %s)", signal, strings.Join(signals, ", "))
+		}
+		enabled[signal] = true
+	}
+
+	preset := strings.ToLower(cfg.Preset)
+	apiKey := getenv("HONEYCOMB_API_KEY")
+	if preset == "" && exporter == "" && apiKey != "" {
+		preset = PresetHoneycomb
+	}
+	switch preset {
+	case "":
+	case PresetHoneycomb:
+		if exporter == "" {
+			exporter = ExporterOTLP
+		}
+		if base.endpoint == "" {
+			base.endpoint = honeycombEndpoint
+		}
+		base.protocol = ProtocolGRPC
+		if apiKey != "" {
+			base.headers[honeycombHeader] = apiKey
+		}
+	default:
+		return settings{}, fmt.Errorf("unknown telemetry preset %q (supported: %s)", cfg.Preset, PresetHoneycomb)
+	}
+
+	if v := getenv("OTEL_SERVICE_NAME"); v != "" {
+		s.serviceName = v
+	}
+	if v := getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		base.endpoint = v
+	}
+	for _, signal := range signals {
+		o, err := resolveOTLP(base, signal, getenv)
+		if err != nil {
+			return settings{}, err
+		}
+		s.otlp[signal] = o
+		if exporter == "" && o.signalEndpoint != "" {
+			exporter = ExporterOTLP
+		}
+	}
+	if exporter == "" && base.endpoint != "" {
+		exporter = ExporterOTLP
+	}
+	if exporter == "" {
+		exporter = ExporterNone
+	}
+	// OTEL_<SIGNAL>_EXPORTER chooses the exporter of its signal, or turns
+	// it off with none
+	for _, signal := range signals {
+		signalExporter := exporter
+		switch v := strings.ToLower(getenv("OTEL_" + strings.ToUpper(signal) + "_EXPORTER")); v {
+		case "":
+		case "console":
+			signalExporter = ExporterStdout
+		default:
+			signalExporter = v
+		}
+		if !slices.Contains(exporters, signalExporter) {
+			return settings{}, fmt.Errorf("unknown telemetry exporter %q (supported: %s)", signalExporter, strings.Join(exporters, ", "))
+		}
+		if enabled[signal] && signalExporter != ExporterNone {
+			s.exporters[signal] = signalExporter
+		}
+	}
+	if err := s.applySampler(getenv("OTEL_TRACES_SAMPLER"), getenv("OTEL_TRACES_SAMPLER_ARG")); err != nil {
+		return settings{}, err
+	}
+	if disabled, _ := strconv.ParseBool(getenv("OTEL_SDK_DISABLED")); disabled {
+		clear(s.exporters)
+	}
+
+	if s.serviceName == "" {
+		s.serviceName = defaultServiceName
+	}
+	if err := s.validate(preset, exporter); err != nil {
+		return settings{}, err
+	}
+	return s, nil
+}
+
+// resolveOTLP applies the OTEL_EXPORTER_OTLP_<SIGNAL>_* variables of a
+// signal to the general OTLP settings
+func resolveOTLP(base otlpSettings, signal string, getenv func(string) string) (otlpSettings, error) {
+	prefix := "OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_"
+	o := base
+	o.headers = maps.Clone(base.headers)
+	o.signalEndpoint = getenv(prefix + "ENDPOINT")
+	if v := firstEnv(getenv, prefix+"PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL"); v != "" {
+		o.protocol = strings.ToLower(v)
+	}
+	if v := firstEnv(getenv, prefix+"COMPRESSION", "OTEL_EXPORTER_OTLP_COMPRESSION"); v != "" {
+		o.compression = strings.ToLower(v)
+	}
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_HEADERS", prefix + "HEADERS"} {
+		if err := parseHeaders(getenv(name), o.headers); err != nil {
+			return otlpSettings{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	for _, name := range []string{prefix + "INSECURE", "OTEL_EXPORTER_OTLP_INSECURE"} {
+		v := getenv(name)
+		if v == "" {
+			continue
+		}
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return otlpSettings{}, fmt.Errorf("invalid %s %q", name, v)
+		}
+		o.insecure = insecure
+		break
+	}
+	if o.protocol == "" {
+		o.protocol = ProtocolGRPC
+	}
+	return o, nil
+}
+
+// validate checks the settings; exporter is the one configured for every
+// signal, which must be usable even when each signal overrides it
+func (s settings) validate(preset, exporter string) error {
+	for _, signal := range signals {
+		o := s.otlp[signal]
+		if !slices.Contains(protocols, o.protocol) {
+			return fmt.Errorf("unsupported OTLP protocol %q (supported: %s)", o.protocol, strings.Join(protocols, ", "))
+		}
+		if o.compression != "" && !slices.Contains(compressions, o.compression) {
+			return fmt.Errorf("unsupported OTLP compression %q (supported: %s)", o.compression, strings.Join(compressions, ", "))
+		}
+		if preset == PresetHoneycomb && (exporter == ExporterOTLP || s.exporters[signal] == ExporterOTLP) && o.headers[honeycombHeader] == "" {
+			return fmt.Errorf("honeycomb preset requires HONEYCOMB_API_KEY")
+		}
+		if s.file == "" && (exporter == ExporterFile || s.exporters[signal] == ExporterFile) {
+			return fmt.Errorf("telemetry file exporter requires a file path")
+		}
+	}
+	if s.sampleRatio < 0 || s.sampleRatio > 1 {
+		return fmt.Errorf("telemetry sample ratio %v must be between 0 and 1", s.sampleRatio)
+	}
+	return nil
+}
+
+// applySampler sets the sample ratio from OTEL_TRACES_SAMPLER and its argument.
+// Every sampler respects the parent's decision, so only the ratio matters.
+func (s *settings) applySampler(sampler, arg string) error {
+	switch strings.ToLower(sampler) {
+	case "":
+		if arg == "" {
+			return nil
+		}
+	case "always_on", "parentbased_always_on":
+		s.sampleRatio = 1
+		return nil
+	case "always_off", "parentbased_always_off":
+		s.sampleRatio = 0
+		return nil
+	case "traceidratio", "parentbased_traceidratio":
+		if arg == "" {
+			s.sampleRatio = 1
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported OTEL_TRACES_SAMPLER %q", sampler)
+	}
+
+	ratio, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return fmt.Errorf("invalid OTEL_TRACES_SAMPLER_ARG %q", arg)
+	}
+	s.sampleRatio = ratio
+	return nil
+}
+
+// exports reports whether a signal is exported
+func (s settings) exports(signal string) bool {
+	return s.exporters[signal] != ""
+}
+
+// exported lists the exported signals, in the order of signals
+func (s settings) exported() []string {
+	var exported []string
+	for _, signal := range signals {
+		if s.exports(signal) {
+			exported = append(exported, signal)
+		}
+	}
+	return exported
+}
+
+// signalEndpoint returns the endpoint of a signal such as "traces": the
+// signal-specific endpoint if set, and otherwise the base endpoint with
+// "/v1/<signal>" appended for HTTP URLs
+func (s settings) signalEndpoint(signal string) string {
+	o := s.otlp[signal]
+	if o.signalEndpoint != "" {
+		return o.signalEndpoint
+	}
+	if o.protocol != ProtocolHTTP || !strings.Contains(o.endpoint, "://") {
+		return o.endpoint
+	}
+	return strings.TrimSuffix(o.endpoint, "/") + "/v1/" + signal
+}
+
+// parseHeaders adds the comma-separated key=value pairs of an
+// OTEL_EXPORTER_OTLP_HEADERS value, with URL-encoded values, to headers
+func parseHeaders(v string, headers map[string]string) error {
+	for pair := range strings.SplitSeq(v, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("header %q is not key=value", pair)
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("header %q: %w", key, err)
+		}
+		headers[key] = decoded
+	}
+	return nil
+}
+
+// firstEnv returns the first of the named environment variables that is set
+func firstEnv(getenv func(string) string, names ...string) string {
+	for _, name := range names {
+		if v := getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
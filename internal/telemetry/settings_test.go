@@ -0,0 +1,340 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wingnut128/outlier-go/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+// exportTo maps each signal, or every signal when none are given, to an
+// exporter
+func exportTo(exporter string, only ...string) map[string]string {
+	if len(only) == 0 {
+		only = signals
+	}
+	exporters := make(map[string]string)
+	for _, signal := range only {
+		exporters[signal] = exporter
+	}
+	return exporters
+}
+
+// otlpAll uses the same OTLP settings for every signal, defaulting to gRPC
+func otlpAll(o otlpSettings) map[string]otlpSettings {
+	if o.protocol == "" {
+		o.protocol = ProtocolGRPC
+	}
+	if o.headers == nil {
+		o.headers = map[string]string{}
+	}
+	all := make(map[string]otlpSettings)
+	for _, signal := range signals {
+		all[signal] = o
+	}
+	return all
+}
+
+func TestResolve(t *testing.T) {
+	defaults := config.DefaultConfig().Telemetry
+
+	tests := []struct {
+		name string
+		cfg  func(*config.TelemetryConfig)
+		env  map[string]string
+		want settings
+	}{
+		{
+			name: "disabled by default",
+			want: settings{serviceName: "outlier", sampleRatio: 1},
+		},
+		{
+			name: "config file",
+			cfg: func(c *config.TelemetryConfig) {
+				c.Exporter = "OTLP"
+				c.Endpoint = "http://collector:4318"
+				c.Protocol = ProtocolHTTP
+				c.Headers = map[string]string{"authorization": "Bearer abc"}
+				c.Compression = "gzip"
+				c.SampleRatio = 0.25
+				c.ServiceName = "percentiles"
+			},
+			want: settings{
+				serviceName: "percentiles", exporters: exportTo(ExporterOTLP), sampleRatio: 0.25,
+				otlp: otlpAll(otlpSettings{
+					endpoint: "http://collector:4318", protocol: ProtocolHTTP,
+					headers: map[string]string{"authorization": "Bearer abc"}, compression: "gzip",
+				}),
+			},
+		},
+		{
+			name: "environment overrides config",
+			cfg: func(c *config.TelemetryConfig) {
+				c.Exporter = ExporterOTLP
+				c.Endpoint = "collector:4317"
+				c.Headers = map[string]string{"x-team": "a", "x-env": "prod"}
+			},
+			env: map[string]string{
+				"OTEL_SERVICE_NAME":           "svc",
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "https://otel.example.com",
+				"OTEL_EXPORTER_OTLP_PROTOCOL": "http/protobuf",
+				"OTEL_EXPORTER_OTLP_HEADERS":  "x-team=b, api-key=a%20b",
+				"OTEL_EXPORTER_OTLP_INSECURE": "true",
+				"OTEL_TRACES_SAMPLER":         "parentbased_traceidratio",
+				"OTEL_TRACES_SAMPLER_ARG":     "0.1",
+			},
+			want: settings{
+				serviceName: "svc", exporters: exportTo(ExporterOTLP), sampleRatio: 0.1,
+				otlp: otlpAll(otlpSettings{
+					endpoint: "https://otel.example.com", protocol: ProtocolHTTP,
+					headers: map[string]string{"x-team": "b", "x-env": "prod", "api-key": "a b"}, insecure: true,
+				}),
+			},
+		},
+		{
+			name: "endpoint environment variable enables export",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://localhost:4317"},
+			want: func() settings {
+				s := settings{serviceName: "outlier", exporters: exportTo(ExporterOTLP), otlp: otlpAll(otlpSettings{}), sampleRatio: 1}
+				traces := s.otlp[SignalTraces]
+				traces.signalEndpoint = "http://localhost:4317"
+				s.otlp[SignalTraces] = traces
+				return s
+			}(),
+		},
+		{
+			name: "signal-specific OTLP variables",
+			cfg: func(c *config.TelemetryConfig) {
+				c.Exporter = ExporterOTLP
+				c.Headers = map[string]string{"x-team": "a"}
+			},
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_PROTOCOL":         "http/protobuf",
+				"OTEL_EXPORTER_OTLP_METRICS_PROTOCOL": "grpc",
+				"OTEL_EXPORTER_OTLP_LOGS_COMPRESSION": "gzip",
+				"OTEL_EXPORTER_OTLP_HEADERS":          "x-env=prod",
+				"OTEL_EXPORTER_OTLP_TRACES_HEADERS":   "x-team=b",
+				"OTEL_EXPORTER_OTLP_INSECURE":         "true",
+				"OTEL_EXPORTER_OTLP_TRACES_INSECURE":  "false",
+			},
+			want: settings{
+				serviceName: "outlier", exporters: exportTo(ExporterOTLP), sampleRatio: 1,
+				otlp: map[string]otlpSettings{
+					SignalTraces:  {protocol: ProtocolHTTP, headers: map[string]string{"x-team": "b", "x-env": "prod"}},
+					SignalMetrics: {protocol: ProtocolGRPC, headers: map[string]string{"x-team": "a", "x-env": "prod"}, insecure: true},
+					SignalLogs:    {protocol: ProtocolHTTP, headers: map[string]string{"x-team": "a", "x-env": "prod"}, insecure: true, compression: "gzip"},
+				},
+			},
+		},
+		{
+			name: "honeycomb API key selects the preset",
+			env:  map[string]string{"HONEYCOMB_API_KEY": "key"},
+			want: settings{
+				serviceName: "outlier", exporters: exportTo(ExporterOTLP), sampleRatio: 1,
+				otlp: otlpAll(otlpSettings{endpoint: "api.honeycomb.io:443", headers: map[string]string{"x-honeycomb-team": "key"}}),
+			},
+		},
+		{
+			name: "configured exporter wins over the honeycomb key",
+			cfg:  func(c *config.TelemetryConfig) { c.Exporter = ExporterStdout },
+			env:  map[string]string{"HONEYCOMB_API_KEY": "key"},
+			want: settings{serviceName: "outlier", exporters: exportTo(ExporterStdout), sampleRatio: 1},
+		},
+		{
+			name: "console exporter",
+			env:  map[string]string{"OTEL_TRACES_EXPORTER": "console", "OTEL_TRACES_SAMPLER": "always_off"},
+			want: settings{serviceName: "outlier", exporters: exportTo(ExporterStdout, SignalTraces)},
+		},
+		{
+			name: "SDK disabled",
+			cfg:  func(c *config.TelemetryConfig) { c.Exporter = ExporterOTLP },
+			env:  map[string]string{"OTEL_SDK_DISABLED": "true"},
+			want: settings{serviceName: "outlier", sampleRatio: 1},
+		},
+		{
+			name: "configured signals",
+			cfg: func(c *config.TelemetryConfig) {
+				c.Exporter = ExporterStdout
+				c.Signals = []string{"Logs", "traces"}
+			},
+			want: settings{serviceName: "outlier", exporters: exportTo(ExporterStdout, SignalTraces, SignalLogs), sampleRatio: 1},
+		},
+		{
+			name: "signal exporter environment variables",
+			cfg:  func(c *config.TelemetryConfig) { c.Exporter = ExporterOTLP },
+			env:  map[string]string{"OTEL_METRICS_EXPORTER": "none", "OTEL_LOGS_EXPORTER": "none"},
+			want: settings{serviceName: "outlier", exporters: exportTo(ExporterOTLP, SignalTraces), sampleRatio: 1},
+		},
+		{
+			name: "signal exporters are chosen separately",
+			cfg: func(c *config.TelemetryConfig) {
+				c.Exporter = ExporterOTLP
+				c.File = "telemetry.jsonl"
+			},
+			env: map[string]string{"OTEL_TRACES_EXPORTER": "console", "OTEL_LOGS_EXPORTER": "file"},
+			want: settings{
+				serviceName: "outlier", sampleRatio: 1, file: "telemetry.jsonl",
+				exporters: map[string]string{SignalTraces: ExporterStdout, SignalMetrics: ExporterOTLP, SignalLogs: ExporterFile},
+			},
+		},
+		{
+			name: "every signal disabled",
+			cfg: func(c *config.TelemetryConfig) {
+				c.Exporter = ExporterOTLP
+				c.Signals = []string{}
+			},
+			want: settings{serviceName: "outlier", sampleRatio: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaults
+			if tt.cfg != nil {
+				tt.cfg(&cfg)
+			}
+			got, err := resolve(cfg, env(tt.env))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.want.exporters == nil {
+				tt.want.exporters = map[string]string{}
+			}
+			if tt.want.otlp == nil {
+				tt.want.otlp = otlpAll(otlpSettings{})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolve_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.TelemetryConfig
+		env  map[string]string
+	}{
+		{name: "unknown exporter", cfg: config.TelemetryConfig{Exporter: "jaeger"}},
+		{name: "unknown preset", cfg: config.TelemetryConfig{Preset: "datadog"}},
+		{name: "honeycomb without key", cfg: config.TelemetryConfig{Preset: PresetHoneycomb}},
+		{name: "unsupported protocol", env: map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "http/json"}},
+		{name: "unsupported compression", cfg: config.TelemetryConfig{Compression: "zstd"}},
+		{name: "sample ratio out of range", cfg: config.TelemetryConfig{SampleRatio: 1.5}},
+		{name: "invalid sampler argument", env: map[string]string{"OTEL_TRACES_SAMPLER_ARG": "half"}},
+		{name: "unsupported sampler", env: map[string]string{"OTEL_TRACES_SAMPLER": "jaeger_remote"}},
+		{name: "malformed headers", env: map[string]string{"OTEL_EXPORTER_OTLP_HEADERS": "novalue"}},
+		{name: "invalid insecure flag", env: map[string]string{"OTEL_EXPORTER_OTLP_INSECURE": "maybe"}},
+		{name: "unsupported signal protocol", env: map[string]string{"OTEL_EXPORTER_OTLP_LOGS_PROTOCOL": "http/json"}},
+		{name: "malformed signal headers", env: map[string]string{"OTEL_EXPORTER_OTLP_METRICS_HEADERS": "novalue"}},
+		{name: "unknown signal exporter", env: map[string]string{"OTEL_METRICS_EXPORTER": "prometheus"}},
+		{name: "signal file exporter without path", cfg: config.TelemetryConfig{Signals: signals}, env: map[string]string{"OTEL_LOGS_EXPORTER": "file"}},
+		{name: "file exporter without path", cfg: config.TelemetryConfig{Exporter: ExporterFile}},
+		{name: "unknown signal", cfg: config.TelemetryConfig{Signals: []string{"profiles"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := resolve(tt.cfg, env(tt.env)); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestSignalEndpoint(t *testing.T) {
+	otlp := func(protocol, endpoint, tracesEndpoint string) settings {
+		s := settings{otlp: otlpAll(otlpSettings{protocol: protocol, endpoint: endpoint})}
+		traces := s.otlp[SignalTraces]
+		traces.signalEndpoint = tracesEndpoint
+		s.otlp[SignalTraces] = traces
+		return s
+	}
+	tests := []struct {
+		s      settings
+		signal string
+		want   string
+	}{
+		{s: otlp(ProtocolHTTP, "http://collector:4318", ""), signal: SignalTraces, want: "http://collector:4318/v1/traces"},
+		{s: otlp(ProtocolHTTP, "http://collector:4318", ""), signal: SignalMetrics, want: "http://collector:4318/v1/metrics"},
+		{s: otlp(ProtocolHTTP, "https://otel.example.com/otlp/", ""), signal: SignalLogs, want: "https://otel.example.com/otlp/v1/logs"},
+		{s: otlp(ProtocolHTTP, "collector:4318", ""), signal: SignalTraces, want: "collector:4318"},
+		{s: otlp(ProtocolGRPC, "http://collector:4317", ""), signal: SignalTraces, want: "http://collector:4317"},
+		{s: otlp(ProtocolHTTP, "http://collector:4318", "http://traces/custom"), signal: SignalTraces, want: "http://traces/custom"},
+		{s: otlp(ProtocolHTTP, "http://collector:4318", "http://traces/custom"), signal: SignalLogs, want: "http://collector:4318/v1/logs"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.s.signalEndpoint(tt.signal); got != tt.want {
+			t.Errorf("%+v %s: expected %q, got %q", tt.s.otlp[tt.signal], tt.signal, tt.want, got)
+		}
+	}
+}
+
+func TestInitTelemetry_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.json")
+	prevTracer, prevMeter, prevLogger := otel.GetTracerProvider(), otel.GetMeterProvider(), global.GetLoggerProvider()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTracer)
+		otel.SetMeterProvider(prevMeter)
+		global.SetLoggerProvider(prevLogger)
+	})
+
+	cfg := config.DefaultConfig().Telemetry
+	cfg.Exporter = ExporterFile
+	cfg.File = path
+	cfg.ServiceName = "outlier-test"
+	if err := InitTelemetry(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ServiceName(); got != "outlier-test" {
+		t.Errorf("expected service name outlier-test, got %q", got)
+	}
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "exported-span")
+	counter, err := otel.Meter("test").Int64Counter("exported.counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 3)
+	slog.New(LogHandler()).InfoContext(ctx, "exported-log")
+	span.End()
+	if err := ShutdownTelemetry(); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"Name":"exported-span"`,
+		`"Name":"exported.counter"`,
+		`"exported-log"`,
+		span.SpanContext().TraceID().String(),
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in the file, got %s", want, data)
+		}
+	}
+}
+
+func TestShutdownTelemetry_Idempotent(t *testing.T) {
+	for range 2 {
+		if err := ShutdownTelemetry(); err != nil {
+			t.Fatalf("unexpected shutdown error: %v", err)
+		}
+	}
+}
@@ -2,83 +2,204 @@ package telemetry
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/wingnut128/outlier-go/internal/config"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
-	"google.golang.org/grpc/credentials"
 )
 
-var tracerProvider *sdktrace.TracerProvider
+const defaultServiceName = "outlier"
 
-// InitTelemetry initializes OpenTelemetry tracing with Honeycomb
-func InitTelemetry() error {
-	apiKey := os.Getenv("HONEYCOMB_API_KEY")
-	if apiKey == "" {
-		// Silently skip telemetry initialization if no API key is set
-		return nil
+var (
+	tracerProvider *sdktrace.TracerProvider
+	meterProvider  *sdkmetric.MeterProvider
+	loggerProvider *sdklog.LoggerProvider
+	serviceName    string
+	// outputFile is the file exporter's output, closed on shutdown
+	outputFile io.Closer
+)
+
+// ServiceName returns the service name reported in telemetry: the one
+// resolved by InitTelemetry, or else OTEL_SERVICE_NAME, defaulting to
+// "outlier"
+func ServiceName() string {
+	if serviceName != "" {
+		return serviceName
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
 	}
+	return defaultServiceName
+}
+
+// Propagator returns the propagator of trace context and baggage across
+// service boundaries, using the W3C traceparent and baggage headers
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
 
-	serviceName := os.Getenv("OTEL_SERVICE_NAME")
-	if serviceName == "" {
-		serviceName = "outlier"
+// InitTelemetry initializes the OpenTelemetry traces, metrics and logs
+// pipelines from the telemetry configuration and the standard OTEL_*
+// environment variables. Telemetry is silently skipped when no exporter is
+// configured.
+func InitTelemetry(cfg config.TelemetryConfig) error {
+	s, err := resolve(cfg, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if len(s.exporters) == 0 {
+		return nil
 	}
 
 	ctx := context.Background()
 
-	// Create OTLP exporter with Honeycomb configuration
-	exporter, err := otlptracegrpc.New(ctx,
-		otlptracegrpc.WithEndpoint("api.honeycomb.io:443"),
-		otlptracegrpc.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, "")),
-		otlptracegrpc.WithHeaders(map[string]string{
-			"x-honeycomb-team": apiKey,
-		}),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create OTLP exporter: %w", err)
+	// Stdout and file exporters of every signal share one writer each
+	outputs := make(map[string]io.Writer)
+	for _, exporter := range s.exporters {
+		switch {
+		case outputs[exporter] != nil:
+		case exporter == ExporterStdout:
+			outputs[exporter] = os.Stdout
+		case exporter == ExporterFile:
+			f, err := os.OpenFile(s.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return fmt.Errorf("failed to open telemetry file: %w", err)
+			}
+			outputFile = f
+			outputs[exporter] = f
+		}
 	}
 
 	// Create resource with service name
 	res, err := resource.New(ctx,
 		resource.WithAttributes(
-			semconv.ServiceName(serviceName),
+			semconv.ServiceName(s.serviceName),
 		),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create resource: %w", err)
+		return errors.Join(fmt.Errorf("failed to create resource: %w", err), ShutdownTelemetry())
 	}
 
-	// Create tracer provider
-	tracerProvider = sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(res),
-	)
+	if err := initProviders(ctx, s, res, outputs); err != nil {
+		return errors.Join(err, ShutdownTelemetry())
+	}
+	serviceName = s.serviceName
+	otel.SetTextMapPropagator(Propagator())
 
-	// Set global tracer provider
-	otel.SetTracerProvider(tracerProvider)
+	slog.Info("Telemetry initialized",
+		"service", s.serviceName, "exporters", s.describe())
+	return nil
+}
+
+// initProviders creates and installs the global provider of each exported
+// signal, writing stdout and file exports to the output of their exporter
+func initProviders(ctx context.Context, s settings, res *resource.Resource, outputs map[string]io.Writer) error {
+	if s.exports(SignalTraces) {
+		exporter, err := newTraceExporter(ctx, s, outputs[s.exporters[SignalTraces]])
+		if err != nil {
+			return fmt.Errorf("failed to create %s trace exporter: %w", s.exporters[SignalTraces], err)
+		}
+		// Sample a ratio of new traces and follow the decision of remote
+		// parents
+		tracerProvider = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(res),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(s.sampleRatio))),
+		)
+		otel.SetTracerProvider(tracerProvider)
+	}
 
-	log.Printf("Telemetry initialized for service: %s\n", serviceName)
+	if s.exports(SignalMetrics) {
+		exporter, err := newMetricExporter(ctx, s, outputs[s.exporters[SignalMetrics]])
+		if err != nil {
+			return fmt.Errorf("failed to create %s metric exporter: %w", s.exporters[SignalMetrics], err)
+		}
+		// The reader's interval honors OTEL_METRIC_EXPORT_INTERVAL
+		meterProvider = sdkmetric.NewMeterProvider(
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)),
+			sdkmetric.WithResource(res),
+		)
+		otel.SetMeterProvider(meterProvider)
+	}
+
+	if s.exports(SignalLogs) {
+		exporter, err := newLogExporter(ctx, s, outputs[s.exporters[SignalLogs]])
+		if err != nil {
+			return fmt.Errorf("failed to create %s log exporter: %w", s.exporters[SignalLogs], err)
+		}
+		loggerProvider = sdklog.NewLoggerProvider(
+			sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+			sdklog.WithResource(res),
+		)
+		global.SetLoggerProvider(loggerProvider)
+	}
 	return nil
 }
 
-// ShutdownTelemetry flushes and shuts down the tracer provider
-func ShutdownTelemetry() error {
-	if tracerProvider == nil {
-		return nil
+// describe summarizes where each signal is sent, for the startup log
+func (s settings) describe() string {
+	var parts []string
+	for _, signal := range s.exported() {
+		target := s.exporters[signal]
+		switch target {
+		case ExporterOTLP:
+			endpoint := s.signalEndpoint(signal)
+			if endpoint == "" {
+				endpoint = "default endpoint"
+			}
+			target = fmt.Sprintf("otlp %s to %s", s.otlp[signal].protocol, endpoint)
+		case ExporterFile:
+			target = "file " + s.file
+		}
+		parts = append(parts, signal+": "+target)
 	}
+	return strings.Join(parts, ", ")
+}
 
+// ShutdownTelemetry flushes and shuts down the meter, logger and tracer
+// providers and closes the telemetry file
+func ShutdownTelemetry() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := tracerProvider.Shutdown(ctx); err != nil {
-		return fmt.Errorf("failed to shutdown tracer provider: %w", err)
+	var errs []error
+	if meterProvider != nil {
+		if err := meterProvider.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shutdown meter provider: %w", err))
+		}
+		meterProvider = nil
+	}
+	if loggerProvider != nil {
+		if err := loggerProvider.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shutdown logger provider: %w", err))
+		}
+		loggerProvider = nil
+	}
+	if tracerProvider != nil {
+		if err := tracerProvider.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shutdown tracer provider: %w", err))
+		}
+		tracerProvider = nil
+	}
+	if outputFile != nil {
+		if err := outputFile.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close telemetry file: %w", err))
+		}
+		outputFile = nil
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
@@ -4,6 +4,7 @@ package api
 type CalculateRequest struct {
 	Values     []float64 `json:"values" binding:"required"`
 	Percentile float64   `json:"percentile"`
+	Unit       string    `json:"unit,omitempty"` // unit the values are expressed in, echoed in the response
 }
 
 // CalculateResponse represents the result of a percentile calculation
@@ -11,6 +12,61 @@ type CalculateResponse struct {
 	Count      int     `json:"count"`
 	Percentile float64 `json:"percentile"`
 	Result     float64 `json:"result"`
+	Format     string  `json:"format,omitempty"` // detected input format for file uploads
+	Unit       string  `json:"unit,omitempty"`   // unit of the values and result
+
+	Skipped int           `json:"skipped,omitempty"` // invalid records skipped in lenient mode
+	Errors  []RecordError `json:"errors,omitempty"`  // sample of the skipped records
+
+	Dropped int `json:"dropped,omitempty"` // NaN and ±Inf values removed by the non-finite policy
+	Clamped int `json:"clamped,omitempty"` // ±Inf values replaced by the largest/smallest finite value
+
+	Sources []SourceResult `json:"sources,omitempty"` // per-file results when several files are uploaded
+	Groups  []GroupResult  `json:"groups,omitempty"`  // per-series results, e.g. per benchmark
+}
+
+// GroupResult represents the result for one labelled series of values, such
+// as a benchmark in Go benchmark output. Outliers lie outside Tukey's fences,
+// 1.5 interquartile ranges beyond the quartiles.
+type GroupResult struct {
+	Name       string  `json:"name"`
+	Count      int     `json:"count"`
+	Result     float64 `json:"result"`
+	Outliers   int     `json:"outliers"`
+	LowerFence float64 `json:"lower_fence"`
+	UpperFence float64 `json:"upper_fence"`
+	Error      string  `json:"error,omitempty"` // why the percentile could not be calculated
+	Dropped    int     `json:"dropped,omitempty"`
+	Clamped    int     `json:"clamped,omitempty"`
+
+	OutlierValues []NamedValue `json:"outlier_values,omitempty"` // named outliers, largest first
+}
+
+// NamedValue is a value identified by name, such as a test and its duration
+type NamedValue struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// SourceResult represents the result for one of several uploaded files
+type SourceResult struct {
+	Source  string  `json:"source"`
+	Format  string  `json:"format"`
+	Count   int     `json:"count"`
+	Result  float64 `json:"result"`
+	Skipped int     `json:"skipped,omitempty"`
+	Dropped int     `json:"dropped,omitempty"`
+	Clamped int     `json:"clamped,omitempty"`
+	Error   string  `json:"error,omitempty"` // why the percentile could not be calculated
+}
+
+// RecordError describes an input record skipped in lenient mode
+type RecordError struct {
+	Line   int    `json:"line,omitempty"`
+	Column string `json:"column,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+	Source string `json:"source,omitempty"` // uploaded file the record came from
 }
 
 // ErrorResponse represents an error response
@@ -0,0 +1,39 @@
+// Package format lets applications embedding outlier register their own input
+// formats. Registered formats are picked up by file decoding in both the CLI
+// and the HTTP server, and are listed by "outlier formats".
+package format
+
+import "github.com/wingnut128/outlier-go/internal/parser"
+
+// Format decodes one kind of input, see parser.Format
+type Format = parser.Format
+
+// FallbackFormat marks formats selected only when sniffing fails, see
+// parser.FallbackFormat
+type FallbackFormat = parser.FallbackFormat
+
+// DecodeContext carries the options, value parser and error report of the
+// input being decoded
+type DecodeContext = parser.DecodeContext
+
+// Options controls how input data is decoded
+type Options = parser.Options
+
+// ValueParser parses numbers with unit suffixes and localized separators
+type ValueParser = parser.ValueParser
+
+// Register makes a format available for decoding. It panics if a format with
+// the same name is already registered.
+func Register(f Format) {
+	parser.Register(f)
+}
+
+// Formats returns the registered formats, built-in formats first
+func Formats() []Format {
+	return parser.Formats()
+}
+
+// Lookup returns the registered format with the given name
+func Lookup(name string) (Format, bool) {
+	return parser.LookupFormat(name)
+}